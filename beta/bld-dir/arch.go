@@ -0,0 +1,722 @@
+package main
+
+// code to describe the beta architecture declaratively, and to build the
+// mrnes topology and experiment parameters from that description
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+)
+
+// ArchSpec is a declarative description of an architecture.  It names the networks,
+// the devices and the links between them, the EUD population and the switch tree that
+// connects it, the roles devices play when the computational patterns are mapped, and
+// the inline inspection of the traffic to and from the EUDs, if any
+type ArchSpec struct {
+	Name     string       `json:"name" yaml:"name"`
+	ArchType string       `json:"archtype" yaml:"archtype"`
+	Networks []NetSpec    `json:"networks" yaml:"networks"`
+	Devices  []DevSpec    `json:"devices" yaml:"devices"`
+	Links    []LinkSpec   `json:"links" yaml:"links"`
+	EUDs     EUDSpec      `json:"euds" yaml:"euds"`
+	Roles    RoleSpec     `json:"roles" yaml:"roles"`
+	Inspect  *InspectSpec `json:"inspect,omitempty" yaml:"inspect,omitempty"`
+
+	// set when the ArchSpec is built from the command-line flags, whose exp.yaml is written as it always has been
+	flags *flagsExp
+}
+
+// flagsExp holds the flag values the exp.yaml of an architecture described by flags has always been
+// written from: the bandwidths (Mbps) of the public and private networks, and the bandwidths of
+// the devices, in the order they are applied
+type flagsExp struct {
+	pubNetBw, pvtNetBw string
+	devBws             []string
+}
+
+// flagsExpDevs names the devices whose bandwidths flagsExp holds.  The devices of an architecture given by
+// flags that are not named here have their bandwidths written as those of an architecture file are
+var flagsExpDevs map[string]bool = map[string]bool{"pcktsrc": true, "pvtSwitch": true, "pvtRtr": true,
+	"sslSrvr": true, "pubRtr": true}
+
+// NetSpec describes a network.  Bandwidth is in Mbps, latency in seconds
+type NetSpec struct {
+	Name      string `json:"name" yaml:"name"`
+	NetScale  string `json:"netscale" yaml:"netscale"`
+	MediaType string `json:"mediatype" yaml:"mediatype"`
+	Bandwidth string `json:"bandwidth" yaml:"bandwidth"`
+	Latency   string `json:"latency" yaml:"latency"`
+}
+
+// DevSpec describes a device.  DevType is one of "host", "srvr", "eud", "switch", "router", or "inspect".
+// Model names an entry in devDesc, Bandwidth gives the Mbps of the device's interfaces, and
+// Network names the network the device is included in
+type DevSpec struct {
+	Name      string `json:"name" yaml:"name"`
+	DevType   string `json:"devtype" yaml:"devtype"`
+	Model     string `json:"model" yaml:"model"`
+	Cores     int    `json:"cores" yaml:"cores"`
+	Bandwidth string `json:"bandwidth" yaml:"bandwidth"`
+	Network   string `json:"network" yaml:"network"`
+	Trace     bool   `json:"trace" yaml:"trace"`
+}
+
+// LinkSpec describes a cable between two devices, with Network naming
+// the network the connection faces
+type LinkSpec struct {
+	Src     string `json:"src" yaml:"src"`
+	Dst     string `json:"dst" yaml:"dst"`
+	Network string `json:"network" yaml:"network"`
+}
+
+// EUDSpec describes the EUD population and the access fabric of switches that connects
+// it to the device named by Attach.  Every EUD has the Model, Cores, and Bandwidth given,
+// unless Mix describes classes of EUDs, in which case the EUDs are assigned to the classes
+// in proportion to their fractions, in an order drawn using Seed.  Fabric selects the shape of
+// the access fabric ("tree" if empty), and Spines the number of spine switches of a "leafspine" fabric
+type EUDSpec struct {
+	Count           int        `json:"count" yaml:"count"`
+	Model           string     `json:"model" yaml:"model"`
+	Cores           int        `json:"cores" yaml:"cores"`
+	Bandwidth       string     `json:"bandwidth" yaml:"bandwidth"`
+	Mix             []EUDClass `json:"mix,omitempty" yaml:"mix,omitempty"`
+	Seed            int64      `json:"seed,omitempty" yaml:"seed,omitempty"`
+	Network         string     `json:"network" yaml:"network"`
+	Attach          string     `json:"attach" yaml:"attach"`
+	SwitchPorts     int        `json:"switchports" yaml:"switchports"`
+	SwitchModel     string     `json:"switchmodel" yaml:"switchmodel"`
+	SwitchBandwidth string     `json:"switchbandwidth" yaml:"switchbandwidth"`
+	Fabric          string     `json:"fabric,omitempty" yaml:"fabric,omitempty"`
+	Spines          int        `json:"spines,omitempty" yaml:"spines,omitempty"`
+
+	// assigned holds the class of each EUD, once assigned
+	assigned []EUDClass
+}
+
+// RoleSpec names the devices to which the functions of the packet source CmpPtns are mapped.
+// Src hosts the packet generator and 'finish', Crypto hosts encryptOut and decryptRtn.
+// In place of a single device, Srcs may list several packet sources and Cryptos several
+// crypto servers, with Balance naming the policy by which EUD sessions are spread across the servers.
+// In the IPsec architecture EUDGw names the gateway doing the crypto of every EUD
+type RoleSpec struct {
+	Src     string   `json:"src,omitempty" yaml:"src,omitempty"`
+	Crypto  string   `json:"crypto,omitempty" yaml:"crypto,omitempty"`
+	Srcs    []string `json:"srcs,omitempty" yaml:"srcs,omitempty"`
+	Cryptos []string `json:"cryptos,omitempty" yaml:"cryptos,omitempty"`
+	Balance string   `json:"balance,omitempty" yaml:"balance,omitempty"`
+	EUDGw   string   `json:"eudgw,omitempty" yaml:"eudgw,omitempty"`
+}
+
+// SrcDevs returns the names of the devices hosting packet sources
+func (rs *RoleSpec) SrcDevs() []string {
+	if len(rs.Srcs) > 0 {
+		return rs.Srcs
+	}
+	return []string{rs.Src}
+}
+
+// CryptoDevs returns the names of the devices hosting the crypto functions of the packet sources
+func (rs *RoleSpec) CryptoDevs() []string {
+	if len(rs.Cryptos) > 0 {
+		return rs.Cryptos
+	}
+	return []string{rs.Crypto}
+}
+
+// ReadArchSpec deserializes an architecture description, either from the byte slice passed
+// in or, if that is empty, from the named file
+func ReadArchSpec(filename string, useYAML bool, dict []byte) (*ArchSpec, error) {
+	var err error
+
+	if len(dict) == 0 {
+		dict, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	example := ArchSpec{}
+
+	// select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(dict, &example)
+	} else {
+		err = json.Unmarshal(dict, &example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &example, nil
+}
+
+// WriteToFile stores the ArchSpec struct to the file whose name is given,
+// serialized as yaml or json depending on useYAML
+func (as *ArchSpec) WriteToFile(filename string) error {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*as)
+	} else {
+		bytes, merr = json.MarshalIndent(*as, "", "\t")
+	}
+	if merr != nil {
+		return merr
+	}
+	return os.WriteFile(filename, bytes, 0644)
+}
+
+// Validate checks that the names the ArchSpec uses to refer to networks and devices
+// are all defined, and reports every problem found
+func (as *ArchSpec) Validate() error {
+	errs := []error{}
+
+	nets := make(map[string]bool)
+	for _, ns := range as.Networks {
+		if nets[ns.Name] {
+			errs = append(errs, fmt.Errorf("network %s declared more than once", ns.Name))
+		}
+		nets[ns.Name] = true
+	}
+
+	devs := make(map[string]string)
+	for _, ds := range as.Devices {
+		if _, present := devs[ds.Name]; present {
+			errs = append(errs, fmt.Errorf("device %s declared more than once", ds.Name))
+		}
+		devs[ds.Name] = ds.DevType
+
+		switch ds.DevType {
+		case "host", "srvr", "eud", "switch", "router", "inspect":
+		default:
+			errs = append(errs, fmt.Errorf("device %s has unrecognized type %s", ds.Name, ds.DevType))
+		}
+
+		if len(ds.Model) == 0 {
+			errs = append(errs, fmt.Errorf("device %s has no model", ds.Name))
+		}
+
+		if !nets[ds.Network] {
+			errs = append(errs, fmt.Errorf("device %s references undeclared network %s", ds.Name, ds.Network))
+		}
+	}
+
+	for _, ls := range as.Links {
+		_, present0 := devs[ls.Src]
+		_, present1 := devs[ls.Dst]
+		if !present0 || !present1 {
+			errs = append(errs, fmt.Errorf("link %s -> %s references undeclared device", ls.Src, ls.Dst))
+		}
+		if !nets[ls.Network] {
+			errs = append(errs, fmt.Errorf("link %s -> %s references undeclared network %s", ls.Src, ls.Dst, ls.Network))
+		}
+	}
+
+	if as.EUDs.Count < 1 {
+		errs = append(errs, fmt.Errorf("architecture needs at least one EUD"))
+	}
+	if as.EUDs.SwitchPorts < 3 {
+		errs = append(errs, fmt.Errorf("EUD switches need at least 3 ports"))
+	}
+	if _, present := devs[as.EUDs.Attach]; !present {
+		errs = append(errs, fmt.Errorf("EUD switch tree attaches to undeclared device %s", as.EUDs.Attach))
+	}
+	if !nets[as.EUDs.Network] {
+		errs = append(errs, fmt.Errorf("EUDs reference undeclared network %s", as.EUDs.Network))
+	}
+	if len(as.EUDs.Mix) == 0 && len(as.EUDs.Model) == 0 {
+		errs = append(errs, fmt.Errorf("EUDs have no model"))
+	}
+	errs = append(errs, as.EUDs.validateMix()...)
+	errs = append(errs, as.EUDs.validateFabric()...)
+	errs = append(errs, as.validateInspect(devs)...)
+
+	if len(as.Roles.Src) > 0 && len(as.Roles.Srcs) > 0 {
+		errs = append(errs, fmt.Errorf("roles give both src and srcs"))
+	}
+	if len(as.Roles.Crypto) > 0 && len(as.Roles.Cryptos) > 0 {
+		errs = append(errs, fmt.Errorf("roles give both crypto and cryptos"))
+	}
+
+	roles := append([]string{}, as.Roles.SrcDevs()...)
+	roles = append(roles, as.Roles.CryptoDevs()...)
+	for _, role := range roles {
+		devType, present := devs[role]
+		if !present {
+			errs = append(errs, fmt.Errorf("role assigned to undeclared device %s", role))
+		} else if devType == "switch" || devType == "router" {
+			errs = append(errs, fmt.Errorf("role assigned to network device %s", role))
+		}
+	}
+
+	if _, present := balancers[as.Roles.Balance]; !present && len(as.Roles.Balance) > 0 {
+		errs = append(errs, fmt.Errorf("unrecognized balance policy %s", as.Roles.Balance))
+	}
+
+	if !archTypes[as.ArchType] {
+		errs = append(errs, fmt.Errorf("unrecognized archtype %s", as.ArchType))
+	}
+	errs = append(errs, as.validateGateway(devs)...)
+
+	return pces.ReportErrs(errs)
+}
+
+// Endpoints returns the names of the architecture's endpoint devices, its hosts, servers, and EUDs
+func (as *ArchSpec) Endpoints() map[string]bool {
+	endpts := make(map[string]bool)
+	for _, ds := range as.Devices {
+		if ds.DevType == "host" || ds.DevType == "srvr" || ds.DevType == "eud" {
+			endpts[ds.Name] = true
+		}
+	}
+	for idx := 0; idx < as.EUDs.Count; idx++ {
+		endpts[EUDName(idx)] = true
+	}
+	return endpts
+}
+
+// devsByType returns the names of the architecture's devices by device type, including
+// its EUDs and eudSwitches, the switches of its access fabric
+func devsByType(as *ArchSpec, eudSwitches []string) map[string][]string {
+	devTypes := map[string][]string{"host": []string{}, "srvr": []string{}, "eud": []string{},
+		"switch": []string{}, "router": []string{}, "inspect": []string{}}
+	for _, ds := range as.Devices {
+		devTypes[ds.DevType] = append(devTypes[ds.DevType], ds.Name)
+	}
+	for idx := 0; idx < as.EUDs.Count; idx++ {
+		devTypes["eud"] = append(devTypes["eud"], EUDName(idx))
+	}
+	devTypes["switch"] = append(devTypes["switch"], eudSwitches...)
+	return devTypes
+}
+
+// EUDName returns the name of the idx-th EUD device
+func EUDName(idx int) string {
+	return "eudDev-" + strconv.Itoa(idx)
+}
+
+// poolNames returns the names of the count devices of a pool, just base when there is one
+func poolNames(base string, count int) []string {
+	if count == 1 {
+		return []string{base}
+	}
+	names := make([]string, count)
+	for idx := 0; idx < count; idx++ {
+		names[idx] = base + "-" + strconv.Itoa(idx)
+	}
+	return names
+}
+
+// archSpecFromFlags builds the ArchSpec that the command-line flags describe,
+// the tree pcktsrc -> pvtSwitch -> pvtRtr -> (sslSrvr -> pubRtr) -> (inspector) -> eudSwitch,
+// or pcktsrc -> pvtSwitch -> pvtRtr -> pvtGw -> pubRtr -> (inspector) -> eudGw -> eudSwitch for IPsec.
+// With -srcs or -sslsrvrs greater than one, the pcktsrc and sslSrvr devices are
+// replicated, every pcktsrc attached to pvtSwitch and every sslSrvr between pvtRtr and pubRtr
+func archSpecFromFlags(cp *cmdline.CmdParser) *ArchSpec {
+	// without an architecture file every flag describing the devices must be present
+	strFlags := []string{"srcCPU", "srcCPUBw", "pubSwitch", "pvtSwitch",
+		"pvtNetBw", "pubNetBw", "pvtSwitchBw", "pubSwitchBw", "pvtRtr", "pvtRtrBw"}
+	intFlags := []string{"euds", "switchports", "srccores"}
+
+	// the EUDs are described either by a mix of classes, or by a single CPU, core count, and bandwidth
+	var eudMix []EUDClass
+	if cp.IsLoaded("eudMix") {
+		var err error
+		eudMix, err = ParseEUDMix(cp.GetVar("eudMix").(string))
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		strFlags = append(strFlags, "eudCPU", "eudCPUBw")
+		intFlags = append(intFlags, "eudcores")
+	}
+
+	archType := archTypeFromFlags(cp)
+
+	switch archType {
+	case "SSL":
+		// if we're building in an SSL server its CPU and interface bandwidth needs to be specified,
+		// as well as the router connecting it to the public network
+		strFlags = append(strFlags, "sslCPU", "sslCPUBw", "pubRtr", "pubRtrBw")
+		intFlags = append(intFlags, "sslcores")
+	case "IPsec":
+		gwStrFlags, gwIntFlags := gatewayFlags()
+		strFlags = append(strFlags, gwStrFlags...)
+		intFlags = append(intFlags, gwIntFlags...)
+	}
+
+	errs := []error{}
+	params := make(map[string]string)
+	for _, flag := range strFlags {
+		if !cp.IsLoaded(flag) {
+			errs = append(errs, fmt.Errorf("command flag %s not included on the command line", flag))
+			continue
+		}
+		params[flag] = cp.GetVar(flag).(string)
+	}
+
+	counts := make(map[string]int)
+	for _, flag := range intFlags {
+		if !cp.IsLoaded(flag) {
+			errs = append(errs, fmt.Errorf("command flag %s not included on the command line", flag))
+			continue
+		}
+		counts[flag] = cp.GetVar(flag).(int)
+	}
+
+	// the numbers of packet sources and SSL servers, and the policy spreading EUD sessions across the servers
+	srcs := 1
+	if cp.IsLoaded("srcs") {
+		srcs = cp.GetVar("srcs").(int)
+	}
+	sslSrvrs := 1
+	if cp.IsLoaded("sslsrvrs") {
+		sslSrvrs = cp.GetVar("sslsrvrs").(int)
+	}
+	if srcs < 1 || sslSrvrs < 1 {
+		errs = append(errs, fmt.Errorf("srcs and sslsrvrs must be at least 1"))
+	}
+	balance := ""
+	if cp.IsLoaded("balance") {
+		balance = cp.GetVar("balance").(string)
+	}
+
+	err := pces.ReportErrs(errs)
+	if err != nil {
+		panic(err)
+	}
+
+	srcNames := poolNames("pcktsrc", srcs)
+
+	as := new(ArchSpec)
+	as.Name = "EvaluateCrypto"
+	as.ArchType = archType
+
+	as.Networks = []NetSpec{
+		NetSpec{Name: "private", NetScale: "LAN", MediaType: "wired", Bandwidth: params["pvtNetBw"], Latency: "1e-4"},
+		NetSpec{Name: "public", NetScale: "LAN", MediaType: "wired", Bandwidth: params["pubNetBw"], Latency: "1e-4"}}
+
+	as.Devices = []DevSpec{}
+	as.Links = []LinkSpec{}
+	for _, srcName := range srcNames {
+		as.Devices = append(as.Devices,
+			DevSpec{Name: srcName, DevType: "host", Model: params["srcCPU"], Cores: counts["srccores"],
+				Bandwidth: params["srcCPUBw"], Network: "private"})
+		as.Links = append(as.Links, LinkSpec{Src: srcName, Dst: "pvtSwitch", Network: "private"})
+	}
+
+	as.Devices = append(as.Devices,
+		DevSpec{Name: "pvtSwitch", DevType: "switch", Model: params["pvtSwitch"],
+			Bandwidth: params["pvtSwitchBw"], Network: "private", Trace: true},
+		DevSpec{Name: "pvtRtr", DevType: "router", Model: params["pvtRtr"],
+			Bandwidth: params["pvtRtrBw"], Network: "private"})
+
+	as.Links = append(as.Links, LinkSpec{Src: "pvtSwitch", Dst: "pvtRtr", Network: "private"})
+
+	// default router that connects to the EUD connection tree, assumes no SSL device,
+	// in which case each packet source does its own crypto
+	bridgeRtr := "pvtRtr"
+	cryptoNames := srcNames
+
+	// if SSL is selected, include the SSL servers and a router that joins pvtNet and pubNet
+	if archType == "SSL" {
+		cryptoNames = poolNames("sslSrvr", sslSrvrs)
+		for _, sslName := range cryptoNames {
+			as.Devices = append(as.Devices,
+				DevSpec{Name: sslName, DevType: "srvr", Model: params["sslCPU"], Cores: counts["sslcores"],
+					Bandwidth: params["sslCPUBw"], Network: "private"})
+
+			as.Links = append(as.Links,
+				LinkSpec{Src: "pvtRtr", Dst: sslName, Network: "private"},
+				LinkSpec{Src: sslName, Dst: "pubRtr", Network: "public"})
+		}
+
+		as.Devices = append(as.Devices,
+			DevSpec{Name: "pubRtr", DevType: "router", Model: params["pubRtr"],
+				Bandwidth: params["pubRtrBw"], Network: "public"})
+
+		bridgeRtr = "pubRtr"
+	}
+
+	// with IPsec the private side's gateway does the crypto of every packet source
+	if archType == "IPsec" {
+		addGateways(as, params, counts)
+		cryptoNames = []string{"pvtGw"}
+		bridgeRtr = "pubRtr"
+	}
+
+	as.EUDs = EUDSpec{Count: counts["euds"], Model: params["eudCPU"], Cores: counts["eudcores"],
+		Bandwidth: params["eudCPUBw"], Network: "public", Attach: bridgeRtr,
+		SwitchPorts: counts["switchports"], SwitchModel: params["pubSwitch"], SwitchBandwidth: params["pubSwitchBw"],
+		Mix: eudMix, Seed: 1}
+
+	if cp.IsLoaded("eudMixSeed") {
+		as.EUDs.Seed = cp.GetVar("eudMixSeed").(int64)
+	}
+
+	if cp.IsLoaded("fabric") {
+		as.EUDs.Fabric = cp.GetVar("fabric").(string)
+	}
+	if cp.IsLoaded("spines") {
+		as.EUDs.Spines = cp.GetVar("spines").(int)
+	}
+
+	// the exp.yaml is written from the bandwidth flags, in the order they have always been applied
+	as.flags = &flagsExp{pubNetBw: params["pubNetBw"], pvtNetBw: params["pvtNetBw"], devBws: []string{}}
+	for _, flag := range []string{"srcCPUBw", "pubSwitchBw", "pubRtrBw", "sslCPUBw", "pvtSwitchBw", "pvtRtrBw", "eudCPUBw"} {
+		if !cp.IsLoaded(flag) || (flag == "sslCPUBw" && archType != "SSL") {
+			continue
+		}
+		as.flags.devBws = append(as.flags.devBws, cp.GetVar(flag).(string))
+	}
+
+	// an inspection device goes between the bridging router and the EUDs
+	inspectFromFlags(cp, as, bridgeRtr)
+
+	as.Roles = RoleSpec{Balance: balance}
+
+	// the EUD gateway is the last device before the EUDs, behind any inspection,
+	// so that the inspection sees the traffic of the tunnel
+	if archType == "IPsec" {
+		addEUDGateway(as, params, counts)
+	}
+	if len(srcNames) == 1 {
+		as.Roles.Src = srcNames[0]
+	} else {
+		as.Roles.Srcs = srcNames
+	}
+	if len(cryptoNames) == 1 {
+		as.Roles.Crypto = cryptoNames[0]
+	} else {
+		as.Roles.Cryptos = cryptoNames
+	}
+
+	return as
+}
+
+// buildTopo creates the mrnes topology the ArchSpec describes, recording the links between its devices.
+// It returns the topology and the names of the switches created to connect the EUDs
+func buildTopo(as *ArchSpec) (*mrnes.TopoCfgFrame, []string) {
+	tcf := mrnes.CreateTopoCfgFrame(as.Name)
+
+	nets := make(map[string]*mrnes.NetworkFrame)
+	netMedia := make(map[string]string)
+	for _, ns := range as.Networks {
+		nets[ns.Name] = mrnes.CreateNetwork(ns.Name, ns.NetScale, ns.MediaType)
+		netMedia[ns.Name] = ns.MediaType
+	}
+
+	devs := make(map[string]mrnes.TopoDev)
+	for _, ds := range as.Devices {
+		var dev mrnes.TopoDev
+		switch ds.DevType {
+		case "host":
+			dev = mrnes.CreateHost(ds.Name, ds.Model, ds.Cores)
+		case "srvr", "inspect":
+			dev = mrnes.CreateSrvr(ds.Name, ds.Model, ds.Cores)
+		case "eud":
+			dev = mrnes.CreateEUD(ds.Name, ds.Model, ds.Cores)
+		case "switch":
+			dev = mrnes.CreateSwitch(ds.Name, ds.Model)
+		case "router":
+			dev = mrnes.CreateRouter(ds.Name, ds.Model)
+		}
+		devs[ds.Name] = dev
+		nets[ds.Network].IncludeDev(dev, netMedia[ds.Network], true)
+	}
+
+	for _, ls := range as.Links {
+		connectDevs(devs[ls.Src], devs[ls.Dst], true, ls.Network)
+	}
+
+	eudNet := nets[as.EUDs.Network]
+	euds := as.EUDs.Count
+
+	// create the switches of the access fabric, and the EUDs connected to them
+	eudSwitches, access := buildFabric(&as.EUDs, devs[as.EUDs.Attach], eudNet.Name)
+
+	for jdx := 0; jdx < euds; jdx++ {
+		eudClass := as.EUDs.Class(jdx)
+		eudDev := mrnes.CreateEUD(EUDName(jdx), eudClass.Model, eudClass.Cores)
+
+		// an EUD of a class is put in a group named by the class, through which
+		// experiment parameters select it
+		if len(eudClass.Name) > 0 {
+			eudDev.AddGroup(eudClass.Name)
+		}
+		eudNet.IncludeDev(eudDev, netMedia[eudNet.Name], true)
+		connectDevs(eudDev, access[jdx], true, eudNet.Name)
+	}
+
+	switchNames := make([]string, len(eudSwitches))
+	for idx, swtch := range eudSwitches {
+		switchNames[idx] = swtch.Name
+	}
+
+	// include the networks in the topo configuration
+	for _, ns := range as.Networks {
+		tcf.AddNetwork(nets[ns.Name])
+	}
+	return tcf, switchNames
+}
+
+// writeTopo completes the topology description buildTopo built and writes it to topoFile
+func writeTopo(tcf *mrnes.TopoCfgFrame, topoFile string) {
+	// fill in any missing parts needed for the topology description
+	topoCfgerr := tcf.Consolidate()
+	if topoCfgerr != nil {
+		panic(topoCfgerr)
+	}
+
+	// turn the pointer-oriented data structures into a flat string-based
+	// version for serialization, then save to file
+	tc := tcf.Transform()
+	tc.WriteToFile(topoFile)
+}
+
+// buildExpCfg creates the experiment parameters for the ArchSpec, given the names
+// of the switches buildTopo created to connect the EUDs, and writes them to expFile
+func buildExpCfg(as *ArchSpec, eudSwitches []string, expFile string) {
+	// create the dictionary to be populated
+	expCfg := mrnes.CreateExpCfg("beta")
+	mrnes.GetExpParamDesc()
+
+	// experiment parameters are largely about architectural parameters
+	// that impact performance. Define some defaults (which can be overwritten later)
+	//
+
+	// default parameters
+	wcAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "*", AttrbValue: ""}}
+	expCfg.AddParameter("Interface", wcAttrbs, "delay", "1e-6")
+	expCfg.AddParameter("Interface", wcAttrbs, "latency", "1e-6")
+
+	// an architecture given by flags has every network given the latency 1e-4 and the bandwidth of the
+	// public network, and every interface the lesser bandwidth of the two networks
+	if as.flags != nil {
+		expCfg.AddParameter("Network", wcAttrbs, "latency", "1e-4")
+		expCfg.AddParameter("Network", wcAttrbs, "bandwidth", as.flags.pubNetBw)
+
+		pubNetBwFloat, _ := strconv.ParseFloat(as.flags.pubNetBw, 64)
+		pvtNetBwFloat, _ := strconv.ParseFloat(as.flags.pvtNetBw, 64)
+		minBw := as.flags.pubNetBw
+		if pvtNetBwFloat < pubNetBwFloat {
+			minBw = as.flags.pvtNetBw
+		}
+		expCfg.AddParameter("Interface", wcAttrbs, "bandwidth", minBw)
+	} else {
+		// every interface to have a bandwidth of the minimum of the network bandwidths
+		minBw := ""
+		minBwFloat := 0.0
+		for _, ns := range as.Networks {
+			bw, _ := strconv.ParseFloat(ns.Bandwidth, 64)
+			if len(minBw) == 0 || bw < minBwFloat {
+				minBw = ns.Bandwidth
+				minBwFloat = bw
+			}
+		}
+		expCfg.AddParameter("Interface", wcAttrbs, "bandwidth", minBw)
+	}
+
+	// every interface to have an MTU of 1500 bytes
+	expCfg.AddParameter("Interface", wcAttrbs, "MTU", "1500")
+
+	// trace on, every device
+	expCfg.AddParameter("Endpt", wcAttrbs, "trace", "true")
+	expCfg.AddParameter("Switch", wcAttrbs, "trace", "false")
+	expCfg.AddParameter("Router", wcAttrbs, "trace", "true")
+	expCfg.AddParameter("Interface", wcAttrbs, "trace", "false")
+
+	if as.flags != nil {
+		addFlagsParams(expCfg, as, eudSwitches)
+	} else {
+		addSpecParams(expCfg, as, eudSwitches)
+	}
+
+	// the interfaces of each class of EUD get the class's bandwidth
+	for _, eudClass := range as.EUDs.Mix {
+		classAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "group", AttrbValue: eudClass.Name}}
+		expCfg.AddParameter("Interface", classAttrbs, "bandwidth", eudClass.Bandwidth)
+	}
+
+	expCfg.WriteToFile(expFile)
+}
+
+// addSpecParams adds to expCfg the parameters of the networks and devices an architecture file describes
+func addSpecParams(expCfg *mrnes.ExpCfg, as *ArchSpec, eudSwitches []string) {
+	// latency and bandwidth of each network
+	for _, ns := range as.Networks {
+		nsAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: ns.Name}}
+		expCfg.AddParameter("Network", nsAttrbs, "latency", ns.Latency)
+		expCfg.AddParameter("Network", nsAttrbs, "bandwidth", ns.Bandwidth)
+	}
+
+	// parameters for individual devices.
+	for _, ds := range as.Devices {
+		if ds.Trace && ds.DevType == "switch" {
+			swAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: ds.Name}}
+			expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
+		}
+
+		if len(ds.Bandwidth) > 0 {
+			devAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "devname", AttrbValue: ds.Name}}
+			expCfg.AddParameter("Interface", devAttrbs, "bandwidth", ds.Bandwidth)
+		}
+	}
+
+	// the root of the EUD switch tree is traced, and all the switches in it
+	// get the same interface bandwidth
+	swAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: eudSwitches[0]}}
+	expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
+
+	if len(as.EUDs.SwitchBandwidth) > 0 {
+		for _, swtchName := range eudSwitches {
+			devAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "devname", AttrbValue: swtchName}}
+			expCfg.AddParameter("Interface", devAttrbs, "bandwidth", as.EUDs.SwitchBandwidth)
+		}
+	}
+
+	// interfaces for euds
+	if len(as.EUDs.Bandwidth) > 0 {
+		eudAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "group", AttrbValue: "EUD"}}
+		expCfg.AddParameter("Interface", eudAttrbs, "bandwidth", as.EUDs.Bandwidth)
+	}
+}
+
+// addFlagsParams adds to expCfg the parameters of the devices of an architecture given by flags, as they
+// have always been written.  The device bandwidths have always all been given to the interfaces of pcktsrc,
+// the attributes naming the device having been copied before each was retargeted, and so they still are,
+// so that a model built from the same flags is simulated as it always was.  Devices the flags could not
+// describe before there were architecture files, such as further packet sources and SSL servers,
+// gateways, and an inspection device, are given their bandwidths as an architecture file's are
+func addFlagsParams(expCfg *mrnes.ExpCfg, as *ArchSpec, eudSwitches []string) {
+	swAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: "pvtSwitch"}}
+	expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
+	swAttrbs = []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: eudSwitches[0]}}
+	expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
+
+	srcAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "devname", AttrbValue: "pcktsrc"}}
+	for _, bw := range as.flags.devBws {
+		expCfg.AddParameter("Interface", srcAttrbs, "bandwidth", bw)
+	}
+
+	for _, ds := range as.Devices {
+		if flagsExpDevs[ds.Name] {
+			continue
+		}
+		if ds.Trace && ds.DevType == "switch" {
+			swAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: ds.Name}}
+			expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
+		}
+		if len(ds.Bandwidth) > 0 {
+			devAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "devname", AttrbValue: ds.Name}}
+			expCfg.AddParameter("Interface", devAttrbs, "bandwidth", ds.Bandwidth)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+// code to spread the EUD sessions across the packet sources and the crypto servers.
+// A session is the traffic between a packet source and one EUD.   Sessions are sticky,
+// so the server chosen for an EUD is chosen once, when the model is built, as a load
+// balancer holding sessions to their servers would
+
+import (
+	"github.com/iti/pces"
+	"hash/fnv"
+)
+
+// balancers lists the policies by which EUD sessions are spread across the crypto servers.
+// "roundrobin" deals the EUDs to the servers in turn, "hash" chooses the server from a hash of
+// the EUD's name, and "leastloaded" gives each EUD in turn to the server whose crypto load
+// (the time to encrypt and decrypt a packet of each of its sessions, divided by its cores) is least
+// once the EUD is added
+var balancers map[string]bool = map[string]bool{"roundrobin": true, "hash": true, "leastloaded": true}
+
+// sessionGroup is the set of EUDs drawing packets from the same source, using the same crypto suite,
+// and served by the same crypto device.  Each group gets its own packet source CmpPtn
+type sessionGroup struct {
+	name   string
+	src    string
+	crypto string
+	suite  int
+	dsts   []string
+}
+
+// assignSessions returns the packet source and the crypto device serving each EUD, given the
+// crypto suite each uses.   The EUDs are dealt to the packet sources in turn.  When the crypto
+// devices are the packet sources, each source does the crypto of its own sessions, otherwise
+// the EUDs are spread across the crypto devices by the balance policy of the ArchSpec
+func assignSessions(as *ArchSpec, suites []CryptoSuite, eudSuite []int, pcktLen int,
+	fel *pces.FuncExecList) ([]string, []string) {
+
+	srcDevs := as.Roles.SrcDevs()
+	cryptoDevs := as.Roles.CryptoDevs()
+	euds := as.EUDs.Count
+
+	eudSrc := make([]string, euds)
+	eudCrypto := make([]string, euds)
+
+	for idx := 0; idx < euds; idx++ {
+		eudSrc[idx] = srcDevs[idx%len(srcDevs)]
+	}
+
+	// a source that is also a crypto device encrypts for itself
+	isCrypto := make(map[string]bool)
+	for _, cryptoDev := range cryptoDevs {
+		isCrypto[cryptoDev] = true
+	}
+	coLocated := true
+	for _, srcDev := range srcDevs {
+		coLocated = coLocated && isCrypto[srcDev]
+	}
+	if coLocated {
+		copy(eudCrypto, eudSrc)
+		return eudSrc, eudCrypto
+	}
+
+	// the crypto load on each server
+	load := make([]float64, len(cryptoDevs))
+
+	for idx := 0; idx < euds; idx++ {
+		var choice int
+		switch as.Roles.Balance {
+		case "hash":
+			hsh := fnv.New32a()
+			hsh.Write([]byte(EUDName(idx)))
+			choice = int(hsh.Sum32() % uint32(len(cryptoDevs)))
+		case "leastloaded":
+			suite := suites[eudSuite[idx]]
+			least := -1.0
+			for cdx, cryptoDev := range cryptoDevs {
+				after := load[cdx] + sessionLoad(as, cryptoDev, suite, pcktLen, fel)
+				if least < 0.0 || after < least {
+					least = after
+					choice = cdx
+				}
+			}
+			load[choice] = least
+		default:
+			choice = idx % len(cryptoDevs)
+		}
+		eudCrypto[idx] = cryptoDevs[choice]
+	}
+	return eudSrc, eudCrypto
+}
+
+// sessionLoad gives the crypto load a session using the suite puts on the named device, the time to
+// encrypt and decrypt a packet on the device's model divided by the device's cores.  A timing missing from
+// fel counts as no load, the omission being reported by checkTimingCoverage
+func sessionLoad(as *ArchSpec, devName string, suite CryptoSuite, pcktLen int, fel *pces.FuncExecList) float64 {
+	model := as.DevModel(devName)
+	cores := 1
+	for _, ds := range as.Devices {
+		if ds.Name == devName && ds.Cores > 1 {
+			cores = ds.Cores
+		}
+	}
+
+	execTime := 0.0
+	for _, cryptoOp := range []string{"encrypt", "decrypt"} {
+		for _, fed := range fel.Times[cryptoOpCode(cryptoOp, suite.Alg, suite.KeyLength)] {
+			if fed.CPUModel == model && fed.PcktLen == pcktLen {
+				execTime += fed.ExecTime
+				break
+			}
+		}
+	}
+	return execTime / float64(cores)
+}
+
+// groupSessions collects the EUDs into session groups, in the order the EUDs are numbered.   Each group
+// is named for its packet source CmpPtn, 'encryptPerf-' and the archType, followed by whatever of
+// the crypto suite, packet source, and crypto device distinguishes it from the other groups
+func groupSessions(archType string, suites []CryptoSuite, eudSuite []int, eudSrc, eudCrypto []string) ([]*sessionGroup, []int) {
+	srcs := make(map[string]bool)
+	cryptos := make(map[string]bool)
+	for idx := range eudSrc {
+		srcs[eudSrc[idx]] = true
+		if eudCrypto[idx] != eudSrc[idx] {
+			cryptos[eudCrypto[idx]] = true
+		}
+	}
+
+	groups := []*sessionGroup{}
+	byName := make(map[string]int)
+	eudGroup := make([]int, len(eudSrc))
+
+	for idx := range eudSrc {
+		name := "encryptPerf-" + archType
+		if len(suites) > 1 {
+			name += "-" + suites[eudSuite[idx]].Code()
+		}
+		if len(srcs) > 1 {
+			name += "-" + eudSrc[idx]
+		}
+		if len(cryptos) > 1 {
+			name += "-" + eudCrypto[idx]
+		}
+
+		gdx, present := byName[name]
+		if !present {
+			gdx = len(groups)
+			byName[name] = gdx
+			groups = append(groups, &sessionGroup{name: name, src: eudSrc[idx], crypto: eudCrypto[idx],
+				suite: eudSuite[idx]})
+		}
+		eudGroup[idx] = gdx
+	}
+	return groups, eudGroup
+}
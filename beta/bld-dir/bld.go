@@ -6,11 +6,10 @@ package main
 import (
 	"fmt"
 	"github.com/iti/cmdline"
+	"github.com/iti/measure"
 	"github.com/iti/mrnes"
 	"github.com/iti/pces"
-	"math"
 	"path/filepath"
-	"strconv"
 	"strings"
 )
 
@@ -36,41 +35,117 @@ func cmdlineParams() *cmdline.CmdParser {
 	cp.AddFlag(cmdline.StringFlag, "topo", false)     // name of output file used for topo templates
 	cp.AddFlag(cmdline.BoolFlag, "useJSON", false)    // use JSON rather than YAML for serialization
 
-	cp.AddFlag(cmdline.BoolFlag, "sslsrvr", true)       // if true include an SSL server, else not (different topo)
-	cp.AddFlag(cmdline.IntFlag, "euds", true)           // number of EUDs in model
-	cp.AddFlag(cmdline.IntFlag, "switchports", true)    // number of ports per switch
-	cp.AddFlag(cmdline.IntFlag, "srccores", true)       // number of cores used on srcPckt
+	cp.AddFlag(cmdline.StringFlag, "archSpec", false)     // file with declarative description of the architecture
+	cp.AddFlag(cmdline.StringFlag, "saveArchSpec", false) // file where the architecture description used is written
+
+	cp.AddFlag(cmdline.BoolFlag, "sslsrvr", false)       // if true include an SSL server, else not (different topo)
+	cp.AddFlag(cmdline.StringFlag, "archtype", false)    // architecture built: SSL, NoSSL, or IPsec, in place of sslsrvr
+	cp.AddFlag(cmdline.IntFlag, "euds", false)           // number of EUDs in model
+	cp.AddFlag(cmdline.IntFlag, "switchports", false)    // number of ports per switch
+	cp.AddFlag(cmdline.IntFlag, "srccores", false)       // number of cores used on srcPckt
 	cp.AddFlag(cmdline.IntFlag, "sslcores", false)       // number of cores used on srcPckt
-	cp.AddFlag(cmdline.IntFlag, "eudcores", true)       // number of cores used on srcPckt
-	cp.AddFlag(cmdline.StringFlag, "cryptoalg", true)   // string description which crypto algorithm is used
-	cp.AddFlag(cmdline.StringFlag, "keylength", true)   // string description which crypto algorithm is used
+	cp.AddFlag(cmdline.IntFlag, "eudcores", false)       // number of cores used on srcPckt
+	cp.AddFlag(cmdline.StringFlag, "cryptoalg", false)  // string description which crypto algorithm is used
+	cp.AddFlag(cmdline.StringFlag, "keylength", false)  // string description which crypto algorithm is used
 	cp.AddFlag(cmdline.IntFlag, "pcktlen", true)        // length of packet in data message
 	cp.AddFlag(cmdline.IntFlag, "pcktburst", true)      // number of packets in a burst
 	cp.AddFlag(cmdline.IntFlag, "eudcycles", false)      // number of times to cycle through the EUD bursts
-	cp.AddFlag(cmdline.StringFlag, "pcktMu", true)       // mean inter-arrival time  between to src process
-	cp.AddFlag(cmdline.StringFlag, "burstMu", false)      // mean inter-burst time 
-	cp.AddFlag(cmdline.StringFlag, "cycleMu", false)  // mean inter-cycle time 
-	cp.AddFlag(cmdline.StringFlag, "srcCPU", true)      // type of CPU on src device
-	cp.AddFlag(cmdline.StringFlag, "srcCPUBw", true)    // Mbs of interfaces on srcCPU
-	cp.AddFlag(cmdline.StringFlag, "eudCPU", true)      // type of CPU on eud device
-	cp.AddFlag(cmdline.StringFlag, "eudCPUBw", true)    // Mbs of interfaces on eudCPU
-	cp.AddFlag(cmdline.StringFlag, "pubSwitch", true)   // switch type in PubNet
-	cp.AddFlag(cmdline.StringFlag, "pvtSwitch", true)   // switch type in PvtNet
-	cp.AddFlag(cmdline.StringFlag, "pvtNetBw", true)    // Mbs of switch interfaces in the private network
-	cp.AddFlag(cmdline.StringFlag, "pubNetBw", true)    // Mbs of switch interfaces in the public network
-	cp.AddFlag(cmdline.StringFlag, "pvtSwitchBw", true) // Mbs of switch interfaces in the private network
-	cp.AddFlag(cmdline.StringFlag, "pubSwitchBw", true) // Mbs of switch interfaces in the public network
-	cp.AddFlag(cmdline.StringFlag, "pubRtr", true)      // type of router in PubNet
-	cp.AddFlag(cmdline.StringFlag, "pvtRtr", true)      // type of router in PvtNet
-	cp.AddFlag(cmdline.StringFlag, "pvtRtrBw", true)    // Mbs of router interfaces in the private network
-	cp.AddFlag(cmdline.StringFlag, "pubRtrBw", true)    // Mbs of router interfaces in the public network
+	cp.AddFlag(cmdline.StringFlag, "pcktMu", true)       // distribution of the inter-arrival time between packets, e.g. exp(5ms)
+	cp.AddFlag(cmdline.StringFlag, "burstMu", false)      // distribution of the inter-burst time
+	cp.AddFlag(cmdline.StringFlag, "cycleMu", false)  // distribution of the inter-cycle time
+	cp.AddFlag(cmdline.StringFlag, "srcCPU", false)      // type of CPU on src device
+	cp.AddFlag(cmdline.StringFlag, "srcCPUBw", false)    // Mbs of interfaces on srcCPU
+	cp.AddFlag(cmdline.StringFlag, "eudCPU", false)      // type of CPU on eud device
+	cp.AddFlag(cmdline.StringFlag, "eudCPUBw", false)    // Mbs of interfaces on eudCPU
+	cp.AddFlag(cmdline.StringFlag, "pubSwitch", false)   // switch type in PubNet
+	cp.AddFlag(cmdline.StringFlag, "pvtSwitch", false)   // switch type in PvtNet
+	cp.AddFlag(cmdline.StringFlag, "pvtNetBw", false)    // Mbs of switch interfaces in the private network
+	cp.AddFlag(cmdline.StringFlag, "pubNetBw", false)    // Mbs of switch interfaces in the public network
+	cp.AddFlag(cmdline.StringFlag, "pvtSwitchBw", false) // Mbs of switch interfaces in the private network
+	cp.AddFlag(cmdline.StringFlag, "pubSwitchBw", false) // Mbs of switch interfaces in the public network
+	cp.AddFlag(cmdline.StringFlag, "pubRtr", false)      // type of router in PubNet
+	cp.AddFlag(cmdline.StringFlag, "pvtRtr", false)      // type of router in PvtNet
+	cp.AddFlag(cmdline.StringFlag, "pvtRtrBw", false)    // Mbs of router interfaces in the private network
+	cp.AddFlag(cmdline.StringFlag, "pubRtrBw", false)    // Mbs of router interfaces in the public network
 	cp.AddFlag(cmdline.StringFlag, "sslCPU", false)     // CPU type for ssl device when present
 	cp.AddFlag(cmdline.StringFlag, "sslCPUBw", false)   // Mbs of interfaces on ssl when present
+	cp.AddFlag(cmdline.StringFlag, "eudMix", false)     // classes of EUD, replacing eudCPU, eudcores, and eudCPUBw
+	cp.AddFlag(cmdline.Int64Flag, "eudMixSeed", false)  // seed of the assignment of EUDs to the classes of eudMix
+	cp.AddFlag(cmdline.StringFlag, "cryptoMix", false)   // crypto suites used by the EUDs, replacing cryptoalg and keylength
+	cp.AddFlag(cmdline.Int64Flag, "cryptoMixSeed", false) // seed of the assignment of EUDs to the suites of cryptoMix
+	cp.AddFlag(cmdline.StringFlag, "cryptoDesc", false)  // file in outputLib listing the crypto suites with timings
+	cp.AddFlag(cmdline.IntFlag, "srcs", false)           // number of packet sources (1 if absent)
+	cp.AddFlag(cmdline.IntFlag, "sslsrvrs", false)       // number of SSL servers when sslsrvr is true (1 if absent)
+	cp.AddFlag(cmdline.StringFlag, "balance", false)     // policy spreading EUD sessions across SSL servers: roundrobin, leastloaded, or hash
+	cp.AddFlag(cmdline.StringFlag, "fabric", false)      // access fabric connecting the EUDs: tree, kary, star, chain, or leafspine
+	cp.AddFlag(cmdline.IntFlag, "spines", false)         // number of spine switches of a leafspine fabric (2 if absent)
+	cp.AddFlag(cmdline.StringFlag, "inspect", false)     // kind of inline inspection between the bridging router and the EUDs: firewall, ids, or dpi
+	cp.AddFlag(cmdline.IntFlag, "inspectRules", false)   // number of rules in the rule set of the inspection device (1000 if absent)
+	cp.AddFlag(cmdline.StringFlag, "inspectCPU", false)  // CPU type of the inspection device when present
+	cp.AddFlag(cmdline.StringFlag, "inspectCPUBw", false) // Mbs of interfaces on the inspection device when present
+	cp.AddFlag(cmdline.IntFlag, "inspectcores", false)   // number of cores of the inspection device when present
+	cp.AddFlag(cmdline.StringFlag, "gwCPU", false)       // CPU type of the tunnel gateways of the IPsec architecture
+	cp.AddFlag(cmdline.StringFlag, "gwCPUBw", false)     // Mbs of interfaces on the tunnel gateways
+	cp.AddFlag(cmdline.IntFlag, "gwcores", false)        // number of cores of each tunnel gateway
+	cp.AddFlag(cmdline.StringFlag, "pattern", false)     // shape of the application: cycle (the default), spread, or closed
+	cp.AddFlag(cmdline.IntFlag, "inflight", false)       // requests each EUD keeps in flight in the closed shape (1 if absent)
+	cp.AddFlag(cmdline.StringFlag, "thinkMu", false)     // distribution of the think time after a response in the closed shape (0 if absent)
+	cp.AddFlag(cmdline.StringFlag, "handshake", false)   // signature suite of a handshake establishing each spread session, e.g. rsa-2048
+	cp.AddFlag(cmdline.StringFlag, "handshakeKex", false) // key exchange suite of the handshake, e.g. ecdh-256
+	cp.AddFlag(cmdline.StringFlag, "handshakeHash", false) // transcript hash of the handshake (sha-256 if absent)
+	cp.AddFlag(cmdline.IntFlag, "msgSize", false)          // bytes of an application message, carried in frames of pcktlen bytes (pcktlen if absent)
+	cp.AddFlag(cmdline.StringFlag, "cryptoUnit", false)    // what the crypto of a message in several frames is applied to: record (the default) or message
+	cp.AddFlag(cmdline.BoolFlag, "extrapolate", false)     // derive function timings at packet lengths beyond those measured
+	cp.AddFlag(cmdline.StringFlag, "protoStack", false)    // file describing the protocol stack whose overhead is added to each packet
+	cp.AddFlag(cmdline.StringFlag, "profile", false)       // file describing a load profile varying the rate of the packet sources over time
+	cp.AddFlag(cmdline.StringFlag, "bckgrnd", false)       // file listing background flows sharing the network, written to outputLib as bckgrnd.yaml
+	cp.AddFlag(cmdline.StringFlag, "failures", false)      // file describing the failure and repair times of classes of devices, written to outputLib as failures.yaml
 	return cp
 }
 
 var useYAML bool
 
+// ptnParams holds what the builders of the CmpPtns need from the command line and the architecture
+type ptnParams struct {
+	archSpec *ArchSpec
+
+	// the crypto suites, and the index of the suite each EUD uses
+	suites   []CryptoSuite
+	eudSuite []int
+
+	// the session groups, the index of the group of each EUD, and the number of EUDs each packet source serves
+	groups   []*sessionGroup
+	eudGroup []int
+	srcEUDs  map[string]int
+
+	pcktSize, pcktBurst, eudCycles int
+
+	// the protocol stack carrying the packets
+	stack *ProtoStack
+
+	// the distributions of the times between packets, bursts, and cycles
+	pcktDist, burstDist, cycleDist Dist
+
+	// the handshake establishing each session, nil when there is none
+	handshake *handshakeSpec
+
+	// the load profile the rate of the packet sources follows, nil when the rate is fixed
+	profile *LoadProfile
+
+	// the requests each EUD keeps in flight in the closed shape, zero in the others,
+	// and the distribution of the think time after each response
+	inflight  int
+	thinkDist Dist
+
+	// the bytes of a message, the number of frames it is carried in, and whether its crypto
+	// is applied to the message as a whole rather than to each frame
+	msgSize, frames int
+	cryptoPerMsg    bool
+
+	// the number of arrival streams given out to the Funcs shaping the arrivals
+	streams int
+}
+
 // devDescMap is a description of hardware devices, read up
 // from an auxilary file
 var devDescMap map[string]mrnes.DevDesc
@@ -142,551 +217,161 @@ func main() {
 		}
 	}
 
-	// the application will build an architecture that comes with a dedicate
-	// SSL server, or another that doesn't.   The sslsrvr flag indicates which
-	hasSSL := cp.GetVar("sslsrvr").(bool)
-	archType := "SSL"
-
-	if !hasSSL {
-		archType = "NoSSL"
-	} else {
-		// if we're building in an SSL server its CPU and interface bandwidth needs to be specified
-		if !cp.IsLoaded("sslCPU") {
-			panic(fmt.Errorf("must specify CPU for SSL server"))
-		}
-		if !cp.IsLoaded("sslCPUBw") {
-			panic(fmt.Errorf("must specify bandwidth for SSL server"))
+	// the architecture is either described by the file named by -archSpec, or
+	// by the command line flags that describe the individual devices and bandwidths
+	var archSpec *ArchSpec
+	if cp.IsLoaded("archSpec") {
+		archSpecFile := cp.GetVar("archSpec").(string)
+		var emptyBytes []byte
+		archSpec, err = ReadArchSpec(archSpecFile, !strings.HasSuffix(archSpecFile, ".json"), emptyBytes)
+		if err != nil {
+			panic(err)
 		}
+	} else {
+		archSpec = archSpecFromFlags(cp)
 	}
 
+	verr := archSpec.Validate()
+	if verr != nil {
+		panic(verr)
+	}
+
+	archType := archSpec.ArchType
+
 	// read in parameters describing packet behavior
 	pcktSize := cp.GetVar("pcktlen").(int)
 	pcktBurst := cp.GetVar("pcktburst").(int)
-	keyLength := cp.GetVar("keylength").(string)
 
-	// read the inter-arrival means as strings in order to
-	// determine distribution type.  Floating point (with ".")
-	// means
-	pcktMuStr := cp.GetVar("pcktMu").(string)
-
-	burstMuStr := pcktMuStr
-	if cp.IsLoaded("burstMu") {	
-		burstMuStr = cp.GetVar("burstMu").(string)
+	// the times between packets, bursts, and cycles are each drawn from a distribution
+	// described on the command line, e.g. exp(5ms).  burstMu and cycleMu default to pcktMu,
+	// and the think time of the closed shape to nothing
+	muStrs := map[string]string{"pcktMu": cp.GetVar("pcktMu").(string), "thinkMu": "0"}
+	if cp.IsLoaded("thinkMu") {
+		muStrs["thinkMu"] = cp.GetVar("thinkMu").(string)
 	}
-
-	cycleMuStr := pcktMuStr
-	if cp.IsLoaded("cycleMu") {	
-		cycleMuStr = cp.GetVar("cycleMu").(string)
+	for _, flag := range []string{"burstMu", "cycleMu"} {
+		muStrs[flag] = muStrs["pcktMu"]
+		if cp.IsLoaded(flag) {
+			muStrs[flag] = cp.GetVar(flag).(string)
+		}
 	}
 
-	pcktMu, _  := strconv.ParseFloat(pcktMuStr, 64)
-	burstMu, _ := strconv.ParseFloat(burstMuStr, 64)
-	cycleMu, _ := strconv.ParseFloat(cycleMuStr, 64)
-
-	// mu values are in milliseconds, so scale to be in seconds
-	pcktMu /= 1000.0
-	burstMu /= 1000.0
-	cycleMu /= 1000.0
-
-	pcktMuDist := "const"
-	burstMuDist := "const"
-	cycleMuDist := "const"
-
-	if strings.Contains(pcktMuStr,"e") {
-		pcktMuDist = "exp"
-	} 
-
-	if strings.Contains(burstMuStr,"e") {
-		burstMuDist = "exp"
-	} 
-
-	if strings.Contains(cycleMuStr,"e") {
-		cycleMuDist = "exp"
-	} 
+	dists := make(map[string]Dist)
+	derrs := []error{}
+	for _, flag := range []string{"pcktMu", "burstMu", "cycleMu", "thinkMu"} {
+		dist, derr := ParseDist(muStrs[flag])
+		if derr != nil {
+			derrs = append(derrs, fmt.Errorf("flag %s: %s", flag, derr.Error()))
+		}
+		dists[flag] = dist
+	}
+	err = pces.ReportErrs(derrs)
+	if err != nil {
+		panic(err)
+	}
 
 	eudCycles := int(1)
 	if cp.IsLoaded("eudcycles") {
 		eudCycles = cp.GetVar("eudcycles").(int)
 	}
 
-	// euds is the number of external user devices in the architecture
-	euds := cp.GetVar("euds").(int)
-
-	// switchports is the number of ports in the switches used to build
-	// a tree of switches from the PubNet switch to the EUDs
-	switchports := cp.GetVar("switchports").(int)
-	srccores := cp.GetVar("srccores").(int)
-	eudcores := cp.GetVar("eudcores").(int)
-	sslcores := int(1)
-	if archType == "SSL" {	
-		sslcores = cp.GetVar("sslcores").(int)
-	}
-	
-	// cryptoalg indicates which of several crypto algorithms
-	// have performance profiles we can use
-	cryptoAlg := cp.GetVar("cryptoalg").(string)
-	cryptoAlg = strings.ToLower(cryptoAlg)
-
-	// the base computational pattern is the one where packets are generated and
-	// to which they return.  The 'type' is a string, here effectively used as a name
-	// also which appends the architectural selection to the string 'encryptPerf'
-	cmpPtnType := "encryptPerf-" + archType
-
-	// create a computational pattern data structure
-	encryptPerf := pces.CreateCompPattern(cmpPtnType)
-
-	// gather up performance parameters for the networks to be modeled
-	srcCPUType := cp.GetVar("srcCPU").(string)
-	srcCPUBw := cp.GetVar("srcCPUBw").(string)
-	pvtSwitchType := cp.GetVar("pvtSwitch").(string)
-	pubSwitchType := cp.GetVar("pubSwitch").(string)
-	pvtNetBw := cp.GetVar("pvtNetBw").(string)
-	pubNetBw := cp.GetVar("pubNetBw").(string)
-	pvtSwitchBw := cp.GetVar("pvtSwitchBw").(string)
-	pubSwitchBw := cp.GetVar("pubSwitchBw").(string)
-	pvtRtrType := cp.GetVar("pvtRtr").(string)
-	pubRtrType := cp.GetVar("pubRtr").(string)
-	pvtRtrBw := cp.GetVar("pvtRtrBw").(string)
-	pubRtrBw := cp.GetVar("pubRtrBw").(string)
-	sslCPUType := cp.GetVar("sslCPU").(string)
-	sslCPUBw := cp.GetVar("sslCPUBw").(string)
-	eudCPUType := cp.GetVar("eudCPU").(string)
-	eudCPUBw := cp.GetVar("eudCPUBw").(string)
-
-	// assume that the packets fit tightly into an IP/TCP ethernet frame,
-	// one per frame.
-	msgLen := pcktSize + 36
-
-	// each EUD will have its own instance of a computational pattern,
-	// a chain from "decryptOut" -> "eudProcess" -> "encryptRtn" 
-	// The last function in the chain exists to highlight that the outboound message
-	// changes the computational pattern from the EUD's to another, in this case
-	// that holding the packet generating server
-
-	// eudCmpPtn will be a template that all the EUD CmpPtns will follow,
-	// and be lightly customized after being copied from the template
-	eudCmpPtn := pces.CreateCompPattern("eudCmpPtn")
-
-	// create the EUD computation pattern functions
-	// The first parameter identifies the name of a Class the function belongs to,
-	// and the second a name for this instance of the function.   There are Class-specific
-	// methods in the simulator used to model the execution of these functions
-	decryptOutFunc := pces.CreateFunc("processPckt", "decryptOut")
-	processFunc := pces.CreateFunc("processPckt", "eudProcess")
-	encryptRtnFunc := pces.CreateFunc("processPckt", "encryptRtn")
-
-	// include the functions in the EUD CmpPtn template
-	eudCmpPtn.AddFunc(decryptOutFunc)
-	eudCmpPtn.AddFunc(processFunc)
-	eudCmpPtn.AddFunc(encryptRtnFunc)
-
-	// create a CmpPtn that models a single process which cycles through target EUDs, shooting
-	// a burst of packets at each.  The pattern is comprised of the chain
-	//    burstSrc -> encryptOut 
-	// and also (separately) decryptRtn -> finish
-	//  'finish' calls out points where movement of message ends and performance measurements are taken
-	srcFunc := pces.CreateFunc("cycleDst", "cycleDst")
-	encryptOutFunc := pces.CreateFunc("processPckt", "encryptOut")
-	decryptRtnFunc := pces.CreateFunc("processPckt", "decryptRtn")
-
-	finishFunc := pces.CreateFunc("finish", "finish")
-
-	// add the functions to the packet generation CmpPtn
-	encryptPerf.AddFunc(srcFunc)
-	encryptPerf.AddFunc(encryptOutFunc)
-	encryptPerf.AddFunc(decryptRtnFunc)
-	encryptPerf.AddFunc(finishFunc)
-
-	// The CmpPtn functions (and TBD edges) define CmpPtn topology.
-	// For each CmpPtn we also define a dictionary that has data and structures
-	// specific to the individual components of the CmpPtn, in the output file
-	// called cpInit.yaml. When we define edges for the CmpPtn we'll also specify
-	// message 'types' (really just a label), and so we plunk these into a cpInit
-	// structure before declaring the edges, so that we can better error check
-	// the inclusion of edges
-
-	// epCPInit is a template for the cpInit structures of the EUD CmpPtn
-	// The first (here empty) argument is a name, the second a type.  The
-	// block we are defining here is a template, each instance will get its own
-	// name
-	epCPInit := pces.CreateCPInitList("", "eudCmpPtn", true)
-
-	// describe the types, packet sizes, and frame lengths of the messages
-	// that pass between functions in the EUD CmpPtn
-	epCPInit.AddMsg(pces.CreateCompPatternMsg("plaintext", true))
-	epCPInit.AddMsg(pces.CreateCompPatternMsg("encryptext", true))
-
-	// create the CP init data structure for the packet source CmpPtn and add the message types it sees
-	epCPSrcInit := pces.CreateCPInitList(encryptPerf.Name, cmpPtnType, true)
-	epCPSrcInit.AddMsg(pces.CreateCompPatternMsg("initiate", true))
-	epCPSrcInit.AddMsg(pces.CreateCompPatternMsg("plaintext", true))
-	epCPSrcInit.AddMsg(pces.CreateCompPatternMsg("finishtext", true))
-	epCPSrcInit.AddMsg(pces.CreateCompPatternMsg("encryptext", true))
-
-	// data connections between functions are described as directed 'edges'
-	// Each call to AddEdge below is specific to a CmpPtn,
-	// and gives the names of the source and destination functions,
-	// the 'type' label of the message that is carried, and a name of a method
-	// at the recipient to be called on receipt of such a message from that source.
-	// The method code must be defined for the class of the destination function, and
-	// indicates particular methods to be invoked in the processing of this message
-	eudCmpPtn.AddEdge(decryptOutFunc.Label, processFunc.Label, "plaintext", "processOp", &epCPInit.Msgs)
-	eudCmpPtn.AddEdge(processFunc.Label, encryptRtnFunc.Label, "plaintext", "encryptOp", &epCPInit.Msgs)
-
-	// each of the CmpPtn's functions gets a cfg dictionary whose structure is defined
-	// by the function's class. Here we create and populate those structures, which
-	// are serialized for storage to file
-
-	// createProcessPcktCfg is a function defined in this file that creates a 'processPckt' class
-	// cfg dictionary given required parameters.  It returns a string that results from serialization
-	// The function whose cfg is created here models the decryption of a packet as it arrives
-	// at the EUD.  The cfg of a processPckt class function includes a code for the particular
-	// operation it models, here, a code like 'decrypt-aes' that indicates the operation and encryption
-	// algorithm.   There is no particular grammer or limitations on what these codes are,
-	// but the simulator will assume that certain table entries exist that match them.
-	// We will later check this validity as it depends also on the mapping of functions to processors
-	// that has not yet been specified.
-
-	decryptOutStr := createCryptoPcktCfg("decrypt", cryptoAlg, keyLength, "plaintext", false)
-	epCPInit.AddCfg(eudCmpPtn, decryptOutFunc, decryptOutStr)
-
-	// the 'processFunc' function in an EUD CmpPtn models the computational delay of doing something
-	// with the decrypted packet, before encrypting a response
-	rtd := map[string]string{"processOp":"plaintext"}
-	tcd := map[string]string{"processOp":"processEUD"}
-	tlb := map[string]string{"processOp":"finish"}
-	tcp := map[string]string{"processOp": encryptPerf.Name}
-
-	processStr := createProcessPcktCfg(rtd, tcd, tcp, tlb, false)
-
-	epCPInit.AddCfg(eudCmpPtn, processFunc, processStr)
-
-	// the 'encryptRtn' function in an EUD CmpPtn models the delay of encrypting
-	// a response to the message sent to the EUD
-	encryptRtnStr := createCryptoPcktCfg("encrypt", cryptoAlg, keyLength, "encryptext", false)
-	epCPInit.AddCfg(eudCmpPtn, encryptRtnFunc, encryptRtnStr)
-
-	// The overall model creates a CmpPtn for each EUD, named
-	// "eudCmpPtn-x" for x between 0 and the number of EUDs specified (minus one).
-	//  Structures eudCmpPtn
-	// and epCPInit are templates for these.  For each EUD we make copies and then
-	// modify slightly as needed to specialize for the specific EUD
-
-	// create dictionaries for all the CmpPtns and all their cpInit auxilary structures
-	cpDict := pces.CreateCompPatternDict("beta")
-	cpInitDict := pces.CreateCPInitListDict("beta")
-
-	// we'll glue on index strings to tailor a name for each EUD CmpPtn
-	eudCPBaseName := "eudCmpPtn"
-
-	dstList := make([]string, euds)
-	// make a unique CmpPtn instance for every eud
-	for idx := 0; idx < euds; idx++ {
-
-		eudIdx := strconv.Itoa(idx)
-
-		// create a copy from the template
-		cpyCP := eudCmpPtn.DeepCopy()
-
-		// give it a unique name
-		cpyCP.SetName(eudCPBaseName + "-" + strconv.Itoa(idx))
-		dstList[idx] = eudCPBaseName + "-" + strconv.Itoa(idx)
-
-		// put in the external edge back to the encryptPerf CmpPtn, and
-		// an external edge from encryptOut to the EUD.  Note that a different method (AddExtEdge)
-		// is used to specify the cross-CmpPtn connections
-		cpyCP.AddExtEdge(cpyCP.Name, encryptPerf.Name, encryptRtnFunc.Label, decryptRtnFunc.Label,
-			"encryptext", "decryptOp", &epCPInit.Msgs, &epCPSrcInit.Msgs)
-		encryptPerf.AddExtEdge(encryptPerf.Name, cpyCP.Name, encryptOutFunc.Label, decryptOutFunc.Label,
-			"encryptext", "decryptOp", &epCPSrcInit.Msgs, &epCPInit.Msgs)
-
-		// save the EUD CmpPtn in the output dictionary
-		cpDict.AddCompPattern(cpyCP)
-
-		// create a copy of CPInit
-		//cpyCPInitList := new(pces.CPInitList)
-		cpyCPInitList := epCPInit.DeepCopy()
-
-		// give it a unique name
-		cpyCPInitList.Name = cpyCPInitList.CPType + "-" + eudIdx
-
-		// save it in the dictionary
-		cpInitDict.AddCPInitList(cpyCPInitList)
-	}
-
-	// add edges to the packet source CmpPtn
-	encryptPerf.AddEdge(srcFunc.Label, srcFunc.Label, "initiate", "generateOp", &epCPSrcInit.Msgs)
-	encryptPerf.AddEdge(srcFunc.Label, encryptOutFunc.Label, "plaintext", "encryptOp", &epCPSrcInit.Msgs)
-	encryptPerf.AddEdge(decryptRtnFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPSrcInit.Msgs)
-	encryptPerf.AddEdge(srcFunc.Label, finishFunc.Label, "finishtext", "finishOp", &epCPSrcInit.Msgs)
-
-	// put in cfg parameters for srcFunc node.
-	// Function type is 'cycleDst', which is tailored for this source.
-	srcCfg := pces.ClassCreateCycleDstCfg()
-
-	// create the routing and timing code maps
-	rtd = map[string]string{"generateOp":"plaintext", "completeOp": "finishtext"}
-	tcd = map[string]string{"generateOp":"generateOp", "completeOp": "completeOp"}
-
-	// build out the cfg dictionary for the srcFunc
-	srcCfg.Populate(dstList, pcktMuDist, pcktMu, burstMuDist, burstMu, pcktBurst, 
-		cycleMuDist, cycleMu, eudCycles, 
-		msgLen, pcktSize, rtd, tcd, false) 
-
-	// serialize srcFunc's cfg and add it to cpCPSrcInit
-	serialSrcCfg, err0 := srcCfg.Serialize(useYAML)
-	if err0 != nil {
-		panic(err0)
-	}
-	epCPSrcInit.AddCfg(encryptPerf, srcFunc, serialSrcCfg)
-
-	// put in parameters for encryptOutFunc
-	encryptOutStr := createCryptoPcktCfg("encrypt", cryptoAlg, keyLength, "encryptext", archType=="SSL")
-	epCPSrcInit.AddCfg(encryptPerf, encryptOutFunc, encryptOutStr)
-
-	// put in parameters for decryptRtnFunc
-	decryptRtnStr := createCryptoPcktCfg("decrypt", cryptoAlg, keyLength, "finishtext", archType=="SSL")
-	epCPSrcInit.AddCfg(encryptPerf, decryptRtnFunc, decryptRtnStr)
-
-	// make a minimalistic cfg for finish
-	finishStr := createFinishCfg()
-	epCPSrcInit.AddCfg(encryptPerf, finishFunc, finishStr)
-
-	cpDict.AddCompPattern(encryptPerf)
-	cpInitDict.AddCPInitList(epCPSrcInit)
-
-	// write the CmpPtn stuff out
-	cpDict.WriteToFile(fullpathmap["cp"])
-	cpInitDict.WriteToFile(fullpathmap["cpInit"])
-
-	// build a topology named with two networks, "Private" and "Public"
-	tcf := mrnes.CreateTopoCfgFrame("EvaluateCrypto")
-
-	// the two networks
-	pvtNet := mrnes.CreateNetwork("private", "LAN", "wired")
-	pubNet := mrnes.CreateNetwork("public", "LAN", "wired")
-
-	// create a source node in pvtnet.
-	var srcNode *mrnes.EndptFrame
-
-	srcNode = mrnes.CreateHost("pcktsrc", srcCPUType, srccores)
-
-	// create a switch and connect the pcktsrc to it
-	pvtSwitch := mrnes.CreateSwitch("pvtSwitch", pvtSwitchType)
-	mrnes.ConnectDevs(srcNode, pvtSwitch, true, pvtNet.Name)
-	pvtNet.IncludeDev(pvtSwitch, "wired", true)
-
-	// create a router and connect the switch to it
-	pvtRtr := mrnes.CreateRouter("pvtRtr", pvtRtrType)
-	mrnes.ConnectDevs(pvtSwitch, pvtRtr, true, pvtNet.Name)
-	pvtNet.IncludeDev(pvtRtr, "wired", true)
-
-	// default router that connects to the EUD connection tree, assumes no SSL device
-	bridgeRtr := pvtRtr
-
-	// if SSL is selected, create a router that joins pvtNet and pubNet
-	if archType == "SSL" {
-		sslSrvr := mrnes.CreateSrvr("sslSrvr", sslCPUType, sslcores)
-		mrnes.ConnectDevs(pvtRtr, sslSrvr, true, pvtNet.Name)
-		pvtNet.IncludeDev(sslSrvr, "wired", true)
-
-		// change bridgeRtr that connects to SSL server
-		bridgeRtr = mrnes.CreateRouter("pubRtr", pubRtrType)
-		pubNet.IncludeDev(bridgeRtr, "wired", true)
-
-		mrnes.ConnectDevs(sslSrvr, bridgeRtr, true, pubNet.Name)
+	// the application either has a packet source cycle through the EUDs, or
+	// gives every EUD a session of its own, or several closed-loop sessions
+	ptnMode := "cycle"
+	if cp.IsLoaded("pattern") {
+		ptnMode = cp.GetVar("pattern").(string)
 	}
-
-	// how many switches for direct connects to euds are needed?
-	baseSwitches, excess := math.Modf(float64(euds) / float64(switchports-1))
-	if excess > 0.0 {
-		baseSwitches += 1
+	if ptnMode != "cycle" && ptnMode != "spread" && ptnMode != "closed" {
+		panic(fmt.Errorf("pattern %s is not cycle, spread, or closed", ptnMode))
 	}
 
-	// create the switches
-	eudSwitches := make([]*mrnes.SwitchFrame, 0)
-	eudSwitches = append(eudSwitches, mrnes.CreateSwitch("eudSwitch-0", pubSwitchType))
-
-	// connect eudSwitches[0] to bridgeRtr
-	mrnes.ConnectDevs(bridgeRtr, eudSwitches[0], true, pubNet.Name)
-	availablePorts := switchports - 1
-
-	expandSwitchIdx := 0
-
-	// so long as the unassigned ports on the switches in the switch tree don't accomodate all euds
-	for availablePorts < euds {
-
-		// make the switch a parent of up to switchports-1 descendent switches
-		children := make([]*mrnes.SwitchFrame, 0)
-		jdx := 0
-
-		// create another if still needed and have not overflowed the paraent's capacity
-		for jdx < switchports-1 && availablePorts < euds {
-			nswtch := mrnes.CreateSwitch("eudswitch-"+strconv.Itoa(len(eudSwitches)+jdx), pubSwitchType)
-			mrnes.ConnectDevs(nswtch, eudSwitches[expandSwitchIdx], true, pubNet.Name)
-			children = append(children, nswtch)
-
-			// availablePorts increases by the free ports of the new switch, less the parent port
-			// used to connect to it
-			availablePorts += (switchports - 2)
-			jdx += 1
+	// in the closed shape every EUD keeps inflight requests outstanding, each
+	// issued a think time after the response to the last
+	inflight := 0
+	if ptnMode == "closed" {
+		inflight = 1
+		if cp.IsLoaded("inflight") {
+			inflight = cp.GetVar("inflight").(int)
 		}
-		eudSwitches = append(eudSwitches, children...)
-		// move to the next unparented switch
-		expandSwitchIdx += 1
-	}
-	// create the EUDs and connect to the switches
-	eudDevs := make([]*mrnes.EndptFrame, euds)
-	assignTo := len(eudSwitches) - 1
-	assignedThisSwitch := 0
-
-	for jdx := 0; jdx < euds; jdx++ {
-		eudDevs[jdx] = mrnes.CreateEUD("eudDev-"+strconv.Itoa(jdx), eudCPUType, eudcores)
-		pubNet.IncludeDev(eudDevs[jdx], "wired", true)
-		mrnes.ConnectDevs(eudDevs[jdx], eudSwitches[assignTo], true, pubNet.Name)
-		assignedThisSwitch += 1
-		if assignedThisSwitch == switchports-1 {
-			assignedThisSwitch = 0
-			assignTo -= 1
+		if inflight < 1 {
+			panic(fmt.Errorf("inflight %d is not positive", inflight))
 		}
 	}
 
-	// include the networks in the topo configuration
-	tcf.AddNetwork(pubNet)
-	tcf.AddNetwork(pvtNet)
-
-	// fill in any missing parts needed for the topology description
-	topoCfgerr := tcf.Consolidate()
-	if topoCfgerr != nil {
-		panic(topoCfgerr)
+	// a handshake establishes a session before its data packets are sent, and so
+	// needs the sessions of the spread or closed shape
+	handshake := handshakeFromFlags(cp)
+	if handshake != nil && ptnMode == "cycle" {
+		panic(fmt.Errorf("handshake needs -pattern spread or closed"))
 	}
 
-	// turn the pointer-oriented data structures into a flat string-based
-	// version for serialization, then save to file
-	tc := tcf.Transform()
-
-	tc.WriteToFile(fullpathmap["topo"])
-
-	// create the dictionary to be populated
-	expCfg := mrnes.CreateExpCfg("beta")
-	mrnes.GetExpParamDesc()
-
-	// experiment parameters are largely about architectural parameters
-	// that impact performance. Define some defaults (which can be overwritten later)
-	//
-
-	// default parameters
-	wcAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "*", AttrbValue: ""}}
-	expCfg.AddParameter("Interface", wcAttrbs, "delay", "1e-6")
-	expCfg.AddParameter("Interface", wcAttrbs, "latency", "1e-6")
-
-	// every network to have a latency of 1e-5
-	expCfg.AddParameter("Network", wcAttrbs, "latency", "1e-4")
-
-	// every network to have a bandwidth of 1000 (Mbits)
-	expCfg.AddParameter("Network", wcAttrbs, "bandwidth", pubNetBw)
-
-	// every interface to have a bandwidth of minimum pubNetBw and pvtNetBw
-	pubNetBwFloat, _ := strconv.ParseFloat(pubNetBw, 64)
-	pvtNetBwFloat, _ := strconv.ParseFloat(pvtNetBw, 64)
-	minBw := pubNetBw
-	if pvtNetBwFloat < pubNetBwFloat {
-		minBw = pvtNetBw
+	// a message larger than a frame is carried in as many frames of pcktlen bytes as it needs,
+	// the last one padded
+	msgSize := pcktSize
+	if cp.IsLoaded("msgSize") {
+		msgSize = cp.GetVar("msgSize").(int)
 	}
-	expCfg.AddParameter("Interface", wcAttrbs, "bandwidth", minBw)
-
-	// every interface to have an MTU of 1500 bytes
-	expCfg.AddParameter("Interface", wcAttrbs, "MTU", "1500")
-
-	// trace on, every device
-	expCfg.AddParameter("Endpt", wcAttrbs, "trace", "true")
-	expCfg.AddParameter("Switch", wcAttrbs, "trace", "false")
-	expCfg.AddParameter("Router", wcAttrbs, "trace", "true")
-	expCfg.AddParameter("Interface", wcAttrbs, "trace", "false")
-
-	// endptAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "group", AttrbValue: "EUD"}}
-	// expCfg.AddParameter("Endpt", endptAttrbs, "trace", "false")
-
-	swAttrbs := []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: "pvtSwitch"}}
-	expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
-	swAttrbs = []mrnes.AttrbStruct{mrnes.AttrbStruct{AttrbName: "name", AttrbValue: "eudSwitch-0"}}
-	expCfg.AddParameter("Switch", swAttrbs, "trace", "true")
-
-	// parameters for individual devices.
-	// interface for pcktsrc
-	asv := mrnes.AttrbStruct{AttrbName: "devname", AttrbValue: "pcktsrc"}
-	as := []mrnes.AttrbStruct{asv}
-	expCfg.AddParameter("Interface", as, "bandwidth", srcCPUBw)
-
-	// interfaces for pubSwitch
-	asv.AttrbValue = "pubSwitch"
-	expCfg.AddParameter("Interface", as, "bandwidth", pubSwitchBw)
-
-	// interfaces for pubRtr
-	asv.AttrbValue = "pubRtr"
-	expCfg.AddParameter("Interface", as, "bandwidth", pubRtrBw)
-
-	// interfaces for sslSrvr (when present)
-	if archType == "SSL" {
-		asv.AttrbValue = "sslSrvr"
-		expCfg.AddParameter("Interface", as, "bandwidth", sslCPUBw)
+	if msgSize < 1 || pcktSize < 1 {
+		panic(fmt.Errorf("msgSize and pcktlen must be positive"))
 	}
+	frames := ceilDiv(msgSize, pcktSize)
 
-	// interfaces for pvtSwitch
-	asv.AttrbValue = "pvtSwitch"
-	expCfg.AddParameter("Interface", as, "bandwidth", pvtSwitchBw)
-
-	// interfaces for pvtRtr
-	asv.AttrbValue = "pvtRtr"
-	expCfg.AddParameter("Interface", as, "bandwidth", pvtRtrBw)
-
-	// interfaces for euds
-	asv.AttrbName = "group"
-	asv.AttrbValue = "EUD"
-	expCfg.AddParameter("Interface", as, "bandwidth", eudCPUBw)
-
-	expCfg.WriteToFile(fullpathmap["exp"])
+	cryptoUnit := "record"
+	if cp.IsLoaded("cryptoUnit") {
+		cryptoUnit = cp.GetVar("cryptoUnit").(string)
+	}
+	if !cryptoUnits[cryptoUnit] {
+		panic(fmt.Errorf("cryptoUnit %s is neither record nor message", cryptoUnit))
+	}
 
-	// create a dictionary to hold the mappings the set of CompPatterns to the architecture
-	cmpMapDict := pces.CreateCompPatternMapDict("Maps")
+	// crypto applied to whole messages needs the sessions of the spread or closed shape, in which
+	// the frames of a message stay within the CmpPtn of its session
+	cryptoPerMsg := frames > 1 && cryptoUnit == "message"
+	if cryptoPerMsg && ptnMode == "cycle" {
+		panic(fmt.Errorf("cryptoUnit message needs -pattern spread or closed"))
+	}
+	checkGatewayCrypto(archSpec, cryptoPerMsg, handshake)
 
-	// map the functions of encryptPerf
-	cmpMap := pces.CreateCompPatternMap(encryptPerf.Name)
+	// euds is the number of external user devices in the architecture
+	euds := archSpec.EUDs.Count
 
-	cmpMap.AddMapping(srcFunc.Label, "pcktsrc", false)
-	cmpMap.AddMapping(finishFunc.Label, "pcktsrc", false)
+	// cryptoalg indicates which of several crypto algorithms
+	// have performance profiles we can use.  Alternatively cryptoMix gives a mix of
+	// algorithms and key lengths, each used by a share of the EUDs
+	var suites []CryptoSuite
+	if cp.IsLoaded("cryptoMix") {
+		suites, err = ParseCryptoMix(cp.GetVar("cryptoMix").(string))
+		if err != nil {
+			panic(err)
+		}
 
-	if archType != "SSL" {
-		cmpMap.AddMapping(encryptOutFunc.Label, "pcktsrc", false)
-		cmpMap.AddMapping(decryptRtnFunc.Label, "pcktsrc", false)
+		// the inventory of suites with timings is written by db/cnvrtDesc.go
+		cryptoDescName := "cryptoDesc.yaml"
+		if cp.IsLoaded("cryptoDesc") {
+			cryptoDescName = cp.GetVar("cryptoDesc").(string)
+		}
+		ierr := checkCryptoInventory(suites, filepath.Join(outputLib, cryptoDescName))
+		if ierr != nil {
+			panic(ierr)
+		}
 	} else {
-		cmpMap.AddMapping(encryptOutFunc.Label, "sslSrvr", false)
-		cmpMap.AddMapping(decryptRtnFunc.Label, "sslSrvr", false)
-	}
-	cmpMapDict.AddCompPatternMap(cmpMap, false)
-
-	for ptnName := range cpDict.Patterns {
-		cmpMap := pces.CreateCompPatternMap(ptnName)
-		if strings.Contains(ptnName, "encryptPerf") {
-			continue
+		if !cp.IsLoaded("cryptoalg") || !cp.IsLoaded("keylength") {
+			panic(fmt.Errorf("must specify cryptoalg and keylength when no cryptoMix is given"))
 		}
+		cryptoAlg := cp.GetVar("cryptoalg").(string)
+		keyLength := cp.GetVar("keylength").(string)
+		suites = []CryptoSuite{CryptoSuite{Alg: strings.ToLower(cryptoAlg), KeyLength: keyLength, Fraction: 1.0}}
+	}
 
-		// the CmpPtn name codes the idx of the EUD
-		splitName := strings.Split(ptnName, "-")
-		eudIdx := splitName[len(splitName)-1]
-
-		eudDevName := "eudDev-" + eudIdx
-
-		cmpMap.AddMapping(decryptOutFunc.Label, eudDevName, false)
-		cmpMap.AddMapping(processFunc.Label, eudDevName, false)
-		cmpMap.AddMapping(encryptRtnFunc.Label, eudDevName, false)
-
-		cmpMapDict.AddCompPatternMap(cmpMap, false)
+	cryptoMixSeed := int64(1)
+	if cp.IsLoaded("cryptoMixSeed") {
+		cryptoMixSeed = cp.GetVar("cryptoMixSeed").(int64)
 	}
 
-	cmpMapDict.WriteToFile(fullpathmap["map"])
+	// eudSuite gives the index of the suite each EUD uses
+	eudSuite := assignSuites(suites, euds, cryptoMixSeed)
 
-	// bundle up all the function timing models and write them to funcExec.yaml
+	// bundle up all the function timing models
 	pattern := filepath.Join(funcXDir,"*.yaml")
 	funcXFiles, err := filepath.Glob(pattern)
 
@@ -708,11 +393,15 @@ func main() {
 		}
 	}
 
-	// write the combined list to the directory the simulation will read
-	felFile := filepath.Join(outputLib,"funcExec.yaml")
-	fel.WriteToFile(felFile)
+	// the handshake's and the inspection's timings are not among those beta ships
+	if handshake != nil {
+		handshake.checkTimings(fel)
+	}
+	if archSpec.Inspect != nil {
+		archSpec.Inspect.checkTimings(fel)
+	}
 
-	// bundle up all the device timing models and write them to devExec.yaml
+	// bundle up all the device timing models
 	pattern = filepath.Join(devXDir,"*.yaml")
 	devXFiles, err := filepath.Glob(pattern)
 
@@ -735,10 +424,198 @@ func main() {
 		}
 	}
 
-	// write the combined list to the directory the simulation will read
+	// spread the EUD sessions across the packet sources and the crypto devices.  The EUDs
+	// drawing packets from the same source, using the same suite, and served by the same crypto
+	// device form a group, and each group gets its own packet source CmpPtn
+	eudSrc, eudCrypto := assignSessions(archSpec, suites, eudSuite, pcktSize, fel)
+	groups, eudGroup := groupSessions(archType, suites, eudSuite, eudSrc, eudCrypto)
+
+	// srcEUDs counts the EUDs each packet source serves
+	srcEUDs := make(map[string]int)
+	for _, srcDev := range eudSrc {
+		srcEUDs[srcDev] += 1
+	}
+
+	// the bytes a packet puts on the wire follow from the protocol stack carrying it, by default
+	// 36 bytes more than the packet, in a single frame
+	stack := legacyStack()
+	if cp.IsLoaded("protoStack") {
+		stackFile := cp.GetVar("protoStack").(string)
+		var emptyBytes []byte
+		stack, err = ReadProtoStack(stackFile, !strings.HasSuffix(stackFile, ".json"), emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+	}
+	perr := stack.Validate()
+	if perr != nil {
+		panic(perr)
+	}
+
+	// the rate of the packet sources is fixed unless a load profile is given
+	var profile *LoadProfile
+	if cp.IsLoaded("profile") {
+		profileFile := cp.GetVar("profile").(string)
+		var emptyBytes []byte
+		profile, err = ReadLoadProfile(profileFile, !strings.HasSuffix(profileFile, ".json"), emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+		perr = profile.Validate()
+		if perr != nil {
+			panic(perr)
+		}
+
+		// the rate of a closed loop follows from the number of requests in flight and
+		// the think time, rather than being set by the source
+		if ptnMode == "closed" {
+			panic(fmt.Errorf("profile needs -pattern cycle or spread"))
+		}
+	}
+
+	// background flows run between endpoints of the architecture
+	var bckgrnd *measure.BckgrndFlowList
+	if cp.IsLoaded("bckgrnd") {
+		bckgrndFile := cp.GetVar("bckgrnd").(string)
+		var emptyBytes []byte
+		bckgrnd, err = measure.ReadBckgrndFlowList(bckgrndFile, !strings.HasSuffix(bckgrndFile, ".json"), emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+		perr = bckgrnd.Validate()
+		if perr != nil {
+			panic(perr)
+		}
+		berrs := []error{}
+		endpts := archSpec.Endpoints()
+		for _, bfd := range bckgrnd.Flows {
+			for _, devName := range []string{bfd.Src, bfd.Dst} {
+				if !endpts[devName] {
+					berrs = append(berrs, fmt.Errorf("background flow %s names %s, which is not an endpoint of the architecture",
+						bfd.Name, devName))
+				}
+			}
+		}
+		perr = pces.ReportErrs(berrs)
+		if perr != nil {
+			panic(perr)
+		}
+	}
+
+	// the failure model names devices by type, which are known once the topology is built
+	var failures *measure.FailureModel
+	if cp.IsLoaded("failures") {
+		failuresFile := cp.GetVar("failures").(string)
+		var emptyBytes []byte
+		failures, err = measure.ReadFailureModel(failuresFile, !strings.HasSuffix(failuresFile, ".json"), emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+		perr = failures.Validate()
+		if perr != nil {
+			panic(perr)
+		}
+	}
+
+	// create dictionaries for all the CmpPtns, all their cpInit auxilary structures,
+	// and the mappings of the CmpPtns to the architecture
+	cpDict := pces.CreateCompPatternDict("beta")
+	cpInitDict := pces.CreateCPInitListDict("beta")
+	cmpMapDict := pces.CreateCompPatternMapDict("Maps")
+
+	// build the topology the architecture describes, whose links give the routes the
+	// measure Funcs note for their round trips
+	tcf, eudSwitches := buildTopo(archSpec)
+
+	pp := &ptnParams{archSpec: archSpec, suites: suites, eudSuite: eudSuite, groups: groups, eudGroup: eudGroup,
+		srcEUDs: srcEUDs, stack: stack, pcktSize: pcktSize, pcktBurst: pcktBurst, eudCycles: eudCycles,
+		pcktDist: dists["pcktMu"], burstDist: dists["burstMu"], cycleDist: dists["cycleMu"], handshake: handshake,
+		profile: profile, inflight: inflight, thinkDist: dists["thinkMu"], msgSize: msgSize, frames: frames, cryptoPerMsg: cryptoPerMsg}
+
+	// build the CmpPtns in the shape -pattern selects, noting every function that is timed,
+	// with the device it is mapped to
+	var timingUses []timingUse
+	if ptnMode == "spread" || ptnMode == "closed" {
+		timingUses = buildSpreadPtns(pp, cpDict, cpInitDict, cmpMapDict)
+	} else {
+		timingUses = buildCyclePtns(pp, cpDict, cpInitDict, cmpMapDict)
+	}
+
+	// functions handling messages larger than a frame are timed at the message size, which
+	// the timing tables may not hold, and so is derived from the lengths they do hold,
+	// beyond those lengths only when -extrapolate allows it
+	derr := deriveTimings(archSpec, timingUses, fel, cp.IsLoaded("extrapolate") && cp.GetVar("extrapolate").(bool))
+	if derr != nil {
+		panic(derr)
+	}
+
+	// before anything is written, make sure the timing tables cover every
+	// operation the model will ask the simulator to time
+	cerr := checkTimingCoverage(archSpec, timingUses, pcktSize, fel, del)
+	if cerr != nil {
+		panic(cerr)
+	}
+
+	// save the description used, so that an architecture given by flags
+	// can be edited and reused
+	if cp.IsLoaded("saveArchSpec") {
+		serr := archSpec.WriteToFile(cp.GetVar("saveArchSpec").(string))
+		if serr != nil {
+			panic(serr)
+		}
+	}
+
+	// write the CmpPtn stuff out
+	cpDict.WriteToFile(fullpathmap["cp"])
+	cpInitDict.WriteToFile(fullpathmap["cpInit"])
+
+	// write out the topology the architecture describes
+	writeTopo(tcf, fullpathmap["topo"])
+
+	// experiment parameters are largely about architectural parameters
+	// that impact performance, and so also come from the architecture
+	buildExpCfg(archSpec, eudSwitches, fullpathmap["exp"])
+
+	cmpMapDict.WriteToFile(fullpathmap["map"])
+
+	// write the combined timing lists to the directory the simulation will read
+	felFile := filepath.Join(outputLib,"funcExec.yaml")
+	fel.WriteToFile(felFile)
+
 	delFile := filepath.Join(outputLib,"devExec.yaml")
 	del.WriteToFile(delFile)
 
+	// the failures are drawn by the simulator, which reads the devices of each class from outputLib
+	if failures != nil {
+		ferr := failures.Resolve(devsByType(archSpec, eudSwitches))
+		if ferr == nil {
+			ferr = failures.Validate()
+		}
+		if ferr != nil {
+			panic(ferr)
+		}
+		failuresFile := filepath.Join(outputLib, "failures.yaml")
+		if !useYAML {
+			failuresFile = filepath.Join(outputLib, "failures.json")
+		}
+		ferr = failures.WriteToFile(failuresFile, useYAML)
+		if ferr != nil {
+			panic(ferr)
+		}
+	}
+
+	// the background flows are set up by the simulator, which reads them from outputLib
+	if bckgrnd != nil {
+		bckgrndFile := filepath.Join(outputLib, "bckgrnd.yaml")
+		if !useYAML {
+			bckgrndFile = filepath.Join(outputLib, "bckgrnd.json")
+		}
+		berr := bckgrnd.WriteToFile(bckgrndFile, useYAML)
+		if berr != nil {
+			panic(berr)
+		}
+	}
+
 	// we don't have shared cfg in this model but need to create an empty file
 	scgl := pces.CreateSharedCfgGroupList(true) 
 	scgl.WriteToFile(fullpathmap["srdCfg"])
@@ -774,10 +651,15 @@ func createProcessPcktCfg(rt, tc, tcp, tlb map[string]string, accl bool) string
 	return serialCfg
 }
 
+// cryptoOpCode gives the timing code of a crypto operation, e.g. 'decrypt-aes-256'
+func cryptoOpCode(cryptoOp, cryptoAlg, keyLength string) string {
+	cryptoVec := []string{cryptoOp, cryptoAlg, keyLength}
+	return strings.Join(cryptoVec,"-")
+}
+
 // def createCryptoPckt("decrypt", cryptoAlg, keyLength, false)
 func createCryptoPcktCfg(cryptoOp, cryptoAlg, keyLength, msgType string, accl bool) string {
-	cryptoVec := []string{cryptoOp, cryptoAlg, keyLength}
-	opCode := strings.Join(cryptoVec,"-")
+	opCode := cryptoOpCode(cryptoOp, cryptoAlg, keyLength)
 	rtd := map[string]string{"encryptOp": msgType, "decryptOp": msgType}
 	tcd := map[string]string{"encryptOp":opCode, "decryptOp": opCode}
 	empty := make(map[string]string)
@@ -0,0 +1,63 @@
+package main
+
+// code to build the 'closed' shape of the beta application, a closed-loop workload.  It is the
+// spread shape with -inflight sessions for every EUD rather than one, each sending a request only
+// once the response to its last has returned and a think time has passed, so that every EUD
+// keeps at most -inflight requests outstanding and the rate follows from the population
+
+import (
+	"github.com/iti/measure"
+	"math"
+	"strconv"
+)
+
+// spreadSession is a session CmpPtn of the spread or closed shape, the index of the
+// EUD it serves, and the suffix its name takes after the name of its session group
+type spreadSession struct {
+	eud    int
+	suffix string
+}
+
+// closedLoop is true in the closed shape
+func (pp *ptnParams) closedLoop() bool {
+	return pp.inflight > 0
+}
+
+// sessions lists the session CmpPtns to build, one per EUD in the spread shape, named by
+// the EUD's index, and -inflight per EUD in the closed shape, named by the EUD's index and their own
+func (pp *ptnParams) sessions() []spreadSession {
+	sessions := []spreadSession{}
+	for idx := 0; idx < pp.archSpec.EUDs.Count; idx++ {
+		if !pp.closedLoop() {
+			sessions = append(sessions, spreadSession{eud: idx, suffix: strconv.Itoa(idx)})
+			continue
+		}
+		for slot := 0; slot < pp.inflight; slot++ {
+			sessions = append(sessions, spreadSession{eud: idx, suffix: strconv.Itoa(idx) + "-" + strconv.Itoa(slot)})
+		}
+	}
+	return sessions
+}
+
+// requests gives the number of packets the src of a session sends, -pcktburst in the spread shape.  In the
+// closed shape a user keeps issuing requests for the whole run, which -stop ends long before the count does
+func (pp *ptnParams) requests() int {
+	if pp.closedLoop() {
+		return math.MaxInt32
+	}
+	return pp.pcktBurst
+}
+
+// createThinkCfg creates and serializes the cfg of a think Func, which holds what it is given
+// for a think time and then passes it to the Func labeled tgtLabel, with method code tgtMC
+func createThinkCfg(pp *ptnParams, tgtLabel, tgtMC string) string {
+	cfg := measure.CreateThinkCfg(pp.thinkDist.Name, pp.thinkDist.Params)
+	cfg.AddRoute("thinkOp", "finishtext", tgtLabel, tgtMC)
+	cfg.Stream = pp.arrivalStream()
+
+	serialCfg, err := cfg.Serialize(useYAML)
+	if err != nil {
+		panic(err)
+	}
+	return serialCfg
+}
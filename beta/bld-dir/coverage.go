@@ -0,0 +1,114 @@
+package main
+
+// code to check, before any of the model is written, that the timing tables hold an entry for
+// every operation the model will ask the simulator to time.   The simulator otherwise discovers
+// a missing entry only when it panics, or quietly charges no time for the operation
+
+import (
+	"fmt"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"sort"
+	"strings"
+)
+
+// timingUse describes a function whose execution the simulator will time: the timing code
+// its cfg names, the device the function is mapped to, and the packet length it is timed at,
+// zero meaning -pcktlen
+type timingUse struct {
+	code    string
+	dev     string
+	pcktLen int
+}
+
+// DevModel returns the model of the named device, which may be one of the EUDs.
+// The empty string is returned for a device the ArchSpec does not describe
+func (as *ArchSpec) DevModel(devName string) string {
+	for _, ds := range as.Devices {
+		if ds.Name == devName {
+			return ds.Model
+		}
+	}
+	for idx := 0; idx < as.EUDs.Count; idx++ {
+		if EUDName(idx) == devName {
+			return as.EUDs.Class(idx).Model
+		}
+	}
+	return ""
+}
+
+// devOps gives the device operation mrnes times on each type of network device
+var devOps map[string]string = map[string]string{"switch": "switch", "router": "route"}
+
+// checkTimingCoverage checks that fel holds a timing for every (timing code, CPU model, packet length)
+// combination that uses calls for, with the CPU model being that of the device a function is mapped to
+// and the packet length pcktLen unless the use gives another,
+// and that del holds a timing for the device operation of every switch and router the ArchSpec
+// describes.  Every missing combination is reported
+func checkTimingCoverage(as *ArchSpec, uses []timingUse, pcktLen int, fel *pces.FuncExecList, del *mrnes.DevExecList) error {
+	errs := []error{}
+
+	// note the devices that need each missing function timing, so that the timing is reported once
+	missing := make(map[string][]string)
+	missingOrder := []string{}
+
+	for _, use := range uses {
+		model := as.DevModel(use.dev)
+		useLen := pcktLen
+		if use.pcktLen > 0 {
+			useLen = use.pcktLen
+		}
+		found := false
+		for _, fed := range fel.Times[use.code] {
+			if fed.CPUModel == model && fed.PcktLen == useLen {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		key := fmt.Sprintf("timing code %s on CPU model %s with packet length %d", use.code, model, useLen)
+		if _, present := missing[key]; !present {
+			missingOrder = append(missingOrder, key)
+		}
+		missing[key] = append(missing[key], use.dev)
+	}
+
+	for _, key := range missingOrder {
+		devs := missing[key]
+		sort.Strings(devs)
+		usedBy := strings.Join(devs, ", ")
+		if len(devs) > 3 {
+			usedBy = fmt.Sprintf("%s, and %d other devices", strings.Join(devs[:3], ", "), len(devs)-3)
+		}
+		errs = append(errs, fmt.Errorf("no function timing for %s (used by %s)", key, usedBy))
+	}
+
+	// the switches of the EUD switch tree all have the model the EUDSpec gives
+	netDevs := []DevSpec{}
+	for _, ds := range as.Devices {
+		if _, present := devOps[ds.DevType]; present {
+			netDevs = append(netDevs, ds)
+		}
+	}
+	netDevs = append(netDevs, DevSpec{Name: "eudSwitch-0", DevType: "switch", Model: as.EUDs.SwitchModel})
+
+	for _, ds := range netDevs {
+		devOp := devOps[ds.DevType]
+		found := false
+		for _, ded := range del.Times[devOp] {
+			if ded.Model == ds.Model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("no device timing for operation %s on model %s (used by %s)",
+				devOp, ds.Model, ds.Name))
+		}
+	}
+
+	return pces.ReportErrs(errs)
+}
@@ -0,0 +1,111 @@
+package main
+
+// code to describe the crypto suites (algorithm and key length) used to protect the traffic
+// between the packet source and the EUDs, when the EUDs use a mix of them, e.g. while
+// a population migrates from a legacy algorithm
+
+import (
+	"fmt"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CryptoSuite is a crypto algorithm and key length, and the fraction of the EUDs using it
+type CryptoSuite struct {
+	Alg       string
+	KeyLength string
+	Fraction  float64
+}
+
+// Code names the suite as it appears in timing codes, e.g. 'aes-256'
+func (cs CryptoSuite) Code() string {
+	return cs.Alg + "-" + cs.KeyLength
+}
+
+// ParseCryptoSuite transforms a description alg-keylength, e.g. "aes-256", into a suite.
+// The key length follows the last '-', as an algorithm name may itself hold one
+func ParseCryptoSuite(code string) (CryptoSuite, error) {
+	code = strings.TrimSpace(code)
+	dash := strings.LastIndex(code, "-")
+	if dash < 1 || dash == len(code)-1 {
+		return CryptoSuite{}, fmt.Errorf("crypto suite %s is not of the form alg-keylength", code)
+	}
+	return CryptoSuite{Alg: strings.ToLower(code[:dash]), KeyLength: code[dash+1:]}, nil
+}
+
+// ParseCryptoMix transforms the description of a crypto mix given on the command line into a list of suites.
+// The suites are separated by commas, and each gives alg-keylength:fraction, e.g. "aes-256:0.7,3des-512:0.3"
+func ParseCryptoMix(mixStr string) ([]CryptoSuite, error) {
+	suites := []CryptoSuite{}
+	names := make(map[string]bool)
+	for _, suiteStr := range strings.Split(mixStr, ",") {
+		fields := strings.Split(strings.TrimSpace(suiteStr), ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("crypto suite %s is not of the form alg-keylength:fraction", suiteStr)
+		}
+		suite, err := ParseCryptoSuite(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		suite.Fraction, err = strconv.ParseFloat(fields[1], 64)
+		if err != nil || suite.Fraction <= 0.0 {
+			return nil, fmt.Errorf("crypto suite %s has fraction %s that is not a positive number", fields[0], fields[1])
+		}
+		if names[fields[0]] {
+			return nil, fmt.Errorf("crypto suite %s appears more than once", fields[0])
+		}
+		names[fields[0]] = true
+
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
+
+// cryptoInventory is the description, written by db/cnvrtDesc.go, of the crypto algorithms
+// and key lengths that have timings
+type cryptoInventory struct {
+	Algs []struct {
+		Name   string `yaml:"name"`
+		KeyLen []int  `yaml:"keylen"`
+	} `yaml:"algs"`
+}
+
+// checkCryptoInventory checks that every suite is described in the named crypto inventory file
+func checkCryptoInventory(suites []CryptoSuite, filename string) error {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	inv := cryptoInventory{}
+	err = yaml.Unmarshal(dict, &inv)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, alg := range inv.Algs {
+		for _, keyLen := range alg.KeyLen {
+			known[strings.ToLower(alg.Name)+"-"+strconv.Itoa(keyLen)] = true
+		}
+	}
+
+	errs := []error{}
+	for _, suite := range suites {
+		if !known[suite.Code()] {
+			errs = append(errs, fmt.Errorf("crypto suite %s is not described in %s", suite.Code(), filename))
+		}
+	}
+	return pces.ReportErrs(errs)
+}
+
+// assignSuites returns the index of the suite each of the euds EUDs uses
+func assignSuites(suites []CryptoSuite, euds int, seed int64) []int {
+	fractions := make([]float64, len(suites))
+	for idx, suite := range suites {
+		fractions[idx] = suite.Fraction
+	}
+	return apportion(fractions, euds, seed)
+}
@@ -0,0 +1,463 @@
+package main
+
+// code to build the 'cycle' shape of the beta application, in which the packet source of each
+// session group cycles through the group's EUDs, shooting a burst of packets at each, and every
+// EUD has a CmpPtn of its own that decrypts, processes, and encrypts a response
+
+import (
+	"github.com/iti/measure"
+	"github.com/iti/pces"
+	"strconv"
+)
+
+// buildCyclePtns adds the CmpPtns of the cycle shape, their cfgs, and their mappings to the dictionaries,
+// returning every function that is timed, with the device it is mapped to
+func buildCyclePtns(pp *ptnParams, cpDict *pces.CompPatternDict, cpInitDict *pces.CPInitListDict,
+	cmpMapDict *pces.CompPatternMapDict) []timingUse {
+
+	archSpec := pp.archSpec
+	suites := pp.suites
+	eudSuite := pp.eudSuite
+	groups := pp.groups
+	eudGroup := pp.eudGroup
+	euds := archSpec.EUDs.Count
+
+	// each EUD will have its own instance of a computational pattern,
+	// a chain from "decryptOut" -> "eudProcess" -> "encryptRtn"
+	// The last function in the chain exists to highlight that the outboound message
+	// changes the computational pattern from the EUD's to another, in this case
+	// that holding the packet generating server
+
+	// eudCmpPtn will be a template that all the EUD CmpPtns will follow,
+	// and be lightly customized after being copied from the template
+	eudCmpPtn := pces.CreateCompPattern("eudCmpPtn")
+
+	// create the EUD computation pattern functions
+	// The first parameter identifies the name of a Class the function belongs to,
+	// and the second a name for this instance of the function.   There are Class-specific
+	// methods in the simulator used to model the execution of these functions
+	decryptOutFunc := pces.CreateFunc("processPckt", "decryptOut")
+	processFunc := pces.CreateFunc("processPckt", "eudProcess")
+	encryptRtnFunc := pces.CreateFunc("processPckt", "encryptRtn")
+
+	// 'eudMark' marks the packet as having visited this EUD's CmpPtn, so that
+	// the RTT samples gathered by encryptPerf's 'measure' record the destination
+	eudMarkFunc := pces.CreateFunc("measure", "eudMark")
+
+	// include the functions in the EUD CmpPtn template
+	eudCmpPtn.AddFunc(decryptOutFunc)
+	eudCmpPtn.AddFunc(eudMarkFunc)
+	eudCmpPtn.AddFunc(processFunc)
+	eudCmpPtn.AddFunc(encryptRtnFunc)
+
+	// when a message is carried in several frames, 'reassembleOut' puts the decrypted frames
+	// back together before the message is processed, and 'fragmentRtn' splits the response
+	// into the frames that are encrypted
+	reassembleOutFunc := pces.CreateFunc("frame", "reassembleOut")
+	fragmentRtnFunc := pces.CreateFunc("frame", "fragmentRtn")
+	if pp.framed() {
+		eudCmpPtn.AddFunc(reassembleOutFunc)
+		eudCmpPtn.AddFunc(fragmentRtnFunc)
+	}
+
+	// The CmpPtn functions (and TBD edges) define CmpPtn topology.
+	// For each CmpPtn we also define a dictionary that has data and structures
+	// specific to the individual components of the CmpPtn, in the output file
+	// called cpInit.yaml. When we define edges for the CmpPtn we'll also specify
+	// message 'types' (really just a label), and so we plunk these into a cpInit
+	// structure before declaring the edges, so that we can better error check
+	// the inclusion of edges
+
+	// epCPInit is a template for the cpInit structures of the EUD CmpPtn
+	// The first (here empty) argument is a name, the second a type.  The
+	// block we are defining here is a template, each instance will get its own
+	// name
+	epCPInit := pces.CreateCPInitList("", "eudCmpPtn", true)
+
+	// describe the types, packet sizes, and frame lengths of the messages
+	// that pass between functions in the EUD CmpPtn
+	epCPInit.AddMsg(pces.CreateCompPatternMsg("plaintext", true))
+	epCPInit.AddMsg(pces.CreateCompPatternMsg("encryptext", true))
+
+	// the base computational patterns are the ones where packets are generated and
+	// to which they return, one for each session group.  The 'type' is a string, here
+	// effectively used as a name also which appends the architectural selection to the string
+	// 'encryptPerf', and whatever else distinguishes the group
+	srcInits := make([]*pces.CPInitList, len(groups))
+	for gdx, group := range groups {
+		// create the CP init data structure for the packet source CmpPtn and add the message types it sees
+		srcInits[gdx] = pces.CreateCPInitList(group.name, group.name, true)
+		srcInits[gdx].AddMsg(pces.CreateCompPatternMsg("initiate", true))
+		srcInits[gdx].AddMsg(pces.CreateCompPatternMsg("plaintext", true))
+		srcInits[gdx].AddMsg(pces.CreateCompPatternMsg("finishtext", true))
+		srcInits[gdx].AddMsg(pces.CreateCompPatternMsg("encryptext", true))
+	}
+
+	// data connections between functions are described as directed 'edges'
+	// Each call to AddEdge below is specific to a CmpPtn,
+	// and gives the names of the source and destination functions,
+	// the 'type' label of the message that is carried, and a name of a method
+	// at the recipient to be called on receipt of such a message from that source.
+	// The method code must be defined for the class of the destination function, and
+	// indicates particular methods to be invoked in the processing of this message
+	if pp.framed() {
+		eudCmpPtn.AddEdge(decryptOutFunc.Label, reassembleOutFunc.Label, "plaintext", "reassembleOp", &epCPInit.Msgs)
+		eudCmpPtn.AddEdge(reassembleOutFunc.Label, eudMarkFunc.Label, "plaintext", "markOp", &epCPInit.Msgs)
+	} else {
+		eudCmpPtn.AddEdge(decryptOutFunc.Label, eudMarkFunc.Label, "plaintext", "markOp", &epCPInit.Msgs)
+	}
+	eudCmpPtn.AddEdge(eudMarkFunc.Label, processFunc.Label, "plaintext", "processOp", &epCPInit.Msgs)
+	if pp.framed() {
+		eudCmpPtn.AddEdge(processFunc.Label, fragmentRtnFunc.Label, "plaintext", "fragmentOp", &epCPInit.Msgs)
+		eudCmpPtn.AddEdge(fragmentRtnFunc.Label, encryptRtnFunc.Label, "plaintext", "encryptOp", &epCPInit.Msgs)
+	} else {
+		eudCmpPtn.AddEdge(processFunc.Label, encryptRtnFunc.Label, "plaintext", "encryptOp", &epCPInit.Msgs)
+	}
+
+	// each of the CmpPtn's functions gets a cfg dictionary whose structure is defined
+	// by the function's class. Here we create and populate those structures, which
+	// are serialized for storage to file.  The cfgs that depend on the crypto suite
+	// or the class of the EUD are filled in as each EUD's copy of the template is made
+
+	// eudMark passes the decrypted packet on to eudProcess
+	eudMarkCfg := measure.CreateMeasureCfg("")
+	eudMarkCfg.AddRoute("markOp", "plaintext", processFunc.Label, "processOp")
+
+	// The overall model creates a CmpPtn for each EUD, named
+	// "eudCmpPtn-x" for x between 0 and the number of EUDs specified (minus one).
+	//  Structures eudCmpPtn
+	// and epCPInit are templates for these.  For each EUD we make copies and then
+	// modify slightly as needed to specialize for the specific EUD
+
+	// we'll glue on index strings to tailor a name for each EUD CmpPtn
+	eudCPBaseName := "eudCmpPtn"
+
+	// make a unique CmpPtn instance for every eud
+	for idx := 0; idx < euds; idx++ {
+
+		eudIdx := strconv.Itoa(idx)
+		gdx := eudGroup[idx]
+		suite := suites[eudSuite[idx]]
+
+		// create a copy from the template
+		cpyCP := eudCmpPtn.DeepCopy()
+
+		// give it a unique name
+		cpyCP.SetName(eudCPBaseName + "-" + strconv.Itoa(idx))
+		groups[gdx].dsts = append(groups[gdx].dsts, cpyCP.Name)
+
+		// put in the external edge back to the packet source CmpPtn of the EUD's group,
+		// through its inspection of the response when the traffic is inspected.
+		// Note that a different method (AddExtEdge) is used to specify the cross-CmpPtn connections
+		if pp.inspected() {
+			cpyCP.AddExtEdge(cpyCP.Name, groups[gdx].name, encryptRtnFunc.Label, "inspectRtn",
+				"encryptext", "inspectOp", &epCPInit.Msgs, &srcInits[gdx].Msgs)
+		} else {
+			cpyCP.AddExtEdge(cpyCP.Name, groups[gdx].name, encryptRtnFunc.Label, "decryptRtn",
+				"encryptext", "decryptOp", &epCPInit.Msgs, &srcInits[gdx].Msgs)
+		}
+
+		// save the EUD CmpPtn in the output dictionary
+		cpDict.AddCompPattern(cpyCP)
+
+		// create a copy of CPInit
+		//cpyCPInitList := new(pces.CPInitList)
+		cpyCPInitList := epCPInit.DeepCopy()
+
+		// give it a unique name
+		cpyCPInitList.Name = cpyCPInitList.CPType + "-" + eudIdx
+
+		// createCryptoPcktCfg is a function defined in this file that creates a 'processPckt' class
+		// cfg dictionary given required parameters.  It returns a string that results from serialization
+		// The function whose cfg is created here models the decryption of a packet as it arrives
+		// at the EUD.  The cfg of a processPckt class function includes a code for the particular
+		// operation it models, here, a code like 'decrypt-aes' that indicates the operation and encryption
+		// algorithm.   There is no particular grammer or limitations on what these codes are,
+		// but the simulator will assume that certain table entries exist that match them.
+		// We check this validity before anything is written, as it depends also on the mapping of
+		// functions to processors
+		decryptOutStr := createCryptoPcktCfg("decrypt", suite.Alg, suite.KeyLength, "plaintext", archSpec.acclCrypto(true))
+		cpyCPInitList.AddCfg(cpyCP, decryptOutFunc, decryptOutStr)
+
+		// when the EUDs are of several classes, eudMark records the class of this one,
+		// and it narrows the route of the packet to this EUD's
+		eudMarkCfg.Class = archSpec.EUDs.Class(idx).Name
+		eudMarkCfg.Path = pp.roundTripDevs(groups[gdx], idx)
+		eudMarkStr, merr := eudMarkCfg.Serialize(useYAML)
+		if merr != nil {
+			panic(merr)
+		}
+		cpyCPInitList.AddCfg(cpyCP, eudMarkFunc, eudMarkStr)
+
+		// the 'processFunc' function in an EUD CmpPtn models the computational delay of doing something
+		// with the decrypted packet, before encrypting a response
+		rtd := map[string]string{"processOp": "plaintext"}
+		tcd := map[string]string{"processOp": "processEUD"}
+		tlb := map[string]string{"processOp": "finish"}
+		tcp := map[string]string{"processOp": groups[gdx].name}
+
+		processStr := createProcessPcktCfg(rtd, tcd, tcp, tlb, false)
+		cpyCPInitList.AddCfg(cpyCP, processFunc, processStr)
+
+		// the 'encryptRtn' function in an EUD CmpPtn models the delay of encrypting
+		// a response to the message sent to the EUD
+		encryptRtnStr := createCryptoPcktCfg("encrypt", suite.Alg, suite.KeyLength, "encryptext", archSpec.acclCrypto(true))
+		cpyCPInitList.AddCfg(cpyCP, encryptRtnFunc, encryptRtnStr)
+
+		// the frames' lengths depend on the suite
+		if pp.framed() {
+			reassembleOutStr := createFrameCfg(pp, suite, "reassembleOp", "plaintext", eudMarkFunc.Label, "markOp")
+			cpyCPInitList.AddCfg(cpyCP, reassembleOutFunc, reassembleOutStr)
+			fragmentRtnStr := createFrameCfg(pp, suite, "fragmentOp", "plaintext", encryptRtnFunc.Label, "encryptOp")
+			cpyCPInitList.AddCfg(cpyCP, fragmentRtnFunc, fragmentRtnStr)
+		}
+
+		// save it in the dictionary
+		cpInitDict.AddCPInitList(cpyCPInitList)
+	}
+
+	// every function that is timed, with the device it is mapped to
+	timingUses := []timingUse{}
+
+	// create a CmpPtn for each session group that models a single process which cycles through the
+	// group's EUDs, shooting a burst of packets at each.  The pattern is comprised of the chain
+	//    burstSrc -> pace -> encryptOut
+	// and also (separately) decryptRtn -> finish
+	//  'finish' calls out points where movement of message ends and performance measurements are taken.
+	// 'measure' sits between cycleDst and encryptOut, and between cycleDst and finish, noting when
+	// each packet leaves and returns, to gather the RTT samples reported through -results.
+	// Because the measurement group is named by the CmpPtn, the samples of each group are reported separately
+	for gdx, group := range groups {
+		dstList := group.dsts
+		suite := suites[group.suite]
+
+		// create a computational pattern data structure
+		encryptPerf := pces.CreateCompPattern(group.name)
+		epCPSrcInit := srcInits[gdx]
+
+		srcFunc := pces.CreateFunc("cycleDst", "cycleDst")
+		encryptOutFunc := pces.CreateFunc("processPckt", "encryptOut")
+		decryptRtnFunc := pces.CreateFunc("processPckt", "decryptRtn")
+
+		finishFunc := pces.CreateFunc("finish", "finish")
+		measureFunc := pces.CreateFunc("measure", "measure")
+
+		// a message carried in several frames is split by 'fragmentOut' before it is encrypted,
+		// and put back together by 'reassembleRtn' once the frames of the response are decrypted
+		fragmentOutFunc := pces.CreateFunc("frame", "fragmentOut")
+		reassembleRtnFunc := pces.CreateFunc("frame", "reassembleRtn")
+
+		// 'pace' spaces out the packets cycleDst generates, drawing the times between packets, bursts,
+		// and cycles from their distributions
+		paceFunc := pces.CreateFunc("pace", "pace")
+
+		// under a load profile 'profile' thins the packets cycleDst generates to the rate of the moment,
+		// returning those it drops to cycleDst unmeasured
+		profileFunc := pces.CreateFunc("profile", "profile")
+
+		// when the traffic is inspected 'inspectOut' inspects the encrypted packets on their way to
+		// the EUDs, and 'inspectRtn' the responses on their way back, both on the inspection device
+		inspectOutFunc := pces.CreateFunc("processPckt", "inspectOut")
+		inspectRtnFunc := pces.CreateFunc("processPckt", "inspectRtn")
+
+		// add the functions to the packet generation CmpPtn
+		encryptPerf.AddFunc(srcFunc)
+		encryptPerf.AddFunc(paceFunc)
+		encryptPerf.AddFunc(encryptOutFunc)
+		encryptPerf.AddFunc(decryptRtnFunc)
+		encryptPerf.AddFunc(finishFunc)
+		encryptPerf.AddFunc(measureFunc)
+		if pp.framed() {
+			encryptPerf.AddFunc(fragmentOutFunc)
+			encryptPerf.AddFunc(reassembleRtnFunc)
+		}
+		if pp.profiled() {
+			encryptPerf.AddFunc(profileFunc)
+		}
+		if pp.inspected() {
+			encryptPerf.AddFunc(inspectOutFunc)
+			encryptPerf.AddFunc(inspectRtnFunc)
+		}
+
+		// an external edge from encryptOut (or inspectOut) to each of the suite's EUDs
+		sendFunc := encryptOutFunc
+		if pp.inspected() {
+			sendFunc = inspectOutFunc
+			encryptPerf.AddEdge(encryptOutFunc.Label, inspectOutFunc.Label, "encryptext", "inspectOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(inspectRtnFunc.Label, decryptRtnFunc.Label, "encryptext", "decryptOp", &epCPSrcInit.Msgs)
+		}
+		for _, dstName := range dstList {
+			encryptPerf.AddExtEdge(encryptPerf.Name, dstName, sendFunc.Label, decryptOutFunc.Label,
+				"encryptext", "decryptOp", &epCPSrcInit.Msgs, &epCPInit.Msgs)
+		}
+
+		// add edges to the packet source CmpPtn
+		encryptPerf.AddEdge(srcFunc.Label, srcFunc.Label, "initiate", "generateOp", &epCPSrcInit.Msgs)
+		encryptPerf.AddEdge(srcFunc.Label, paceFunc.Label, "plaintext", "paceOp", &epCPSrcInit.Msgs)
+		if pp.profiled() {
+			encryptPerf.AddEdge(paceFunc.Label, profileFunc.Label, "plaintext", "thinOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(profileFunc.Label, measureFunc.Label, "plaintext", "startOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(profileFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPSrcInit.Msgs)
+		} else {
+			encryptPerf.AddEdge(paceFunc.Label, measureFunc.Label, "plaintext", "startOp", &epCPSrcInit.Msgs)
+		}
+		if pp.framed() {
+			encryptPerf.AddEdge(measureFunc.Label, fragmentOutFunc.Label, "plaintext", "fragmentOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(fragmentOutFunc.Label, encryptOutFunc.Label, "plaintext", "encryptOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(decryptRtnFunc.Label, reassembleRtnFunc.Label, "finishtext", "reassembleOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(reassembleRtnFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPSrcInit.Msgs)
+		} else {
+			encryptPerf.AddEdge(measureFunc.Label, encryptOutFunc.Label, "plaintext", "encryptOp", &epCPSrcInit.Msgs)
+			encryptPerf.AddEdge(decryptRtnFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPSrcInit.Msgs)
+		}
+		encryptPerf.AddEdge(srcFunc.Label, measureFunc.Label, "finishtext", "endOp", &epCPSrcInit.Msgs)
+		encryptPerf.AddEdge(measureFunc.Label, finishFunc.Label, "finishtext", "finishOp", &epCPSrcInit.Msgs)
+		encryptPerf.AddEdge(measureFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPSrcInit.Msgs)
+
+		// put in cfg parameters for srcFunc node.
+		// Function type is 'cycleDst', which is tailored for this source.
+		srcCfg := pces.ClassCreateCycleDstCfg()
+
+		// create the routing and timing code maps
+		rtd := map[string]string{"generateOp": "plaintext", "completeOp": "finishtext"}
+		tcd := map[string]string{"generateOp": "generateOp", "completeOp": "completeOp"}
+
+		// cycleDst generates its packets without delay, and pace spaces them out
+		msgLen, pcktLen := pp.srcLens(suite)
+		srcCfg.Populate(dstList, "const", 0.0, "const", 0.0, pp.pcktBurst,
+			"const", 0.0, pp.eudCycles,
+			msgLen, pcktLen, rtd, tcd, false)
+
+		// serialize srcFunc's cfg and add it to cpCPSrcInit
+		serialSrcCfg, err0 := srcCfg.Serialize(useYAML)
+		if err0 != nil {
+			panic(err0)
+		}
+		epCPSrcInit.AddCfg(encryptPerf, srcFunc, serialSrcCfg)
+
+		// put in parameters for encryptOutFunc
+		encryptOutStr := createCryptoPcktCfg("encrypt", suite.Alg, suite.KeyLength, "encryptext", archSpec.acclCrypto(false))
+		epCPSrcInit.AddCfg(encryptPerf, encryptOutFunc, encryptOutStr)
+
+		// put in parameters for decryptRtnFunc
+		decryptRtnStr := createCryptoPcktCfg("decrypt", suite.Alg, suite.KeyLength, "finishtext", archSpec.acclCrypto(false))
+		epCPSrcInit.AddCfg(encryptPerf, decryptRtnFunc, decryptRtnStr)
+
+		// make a minimalistic cfg for finish
+		finishStr := createFinishCfg()
+		epCPSrcInit.AddCfg(encryptPerf, finishFunc, finishStr)
+
+		// the inspection Funcs pass the encrypted packets on, out to the EUD and back
+		if pp.inspected() {
+			epCPSrcInit.AddCfg(encryptPerf, inspectOutFunc, createInspectCfg(pp, "encryptext"))
+			epCPSrcInit.AddCfg(encryptPerf, inspectRtnFunc, createInspectCfg(pp, "encryptext"))
+		}
+
+		// measure passes outbound packets on to encryptOut (or fragmentOut) and returning ones on to finish,
+		// gathering samples in a group named by the CmpPtn
+		measureCfg := measure.CreateMeasureCfg(encryptPerf.Name)
+		if pp.framed() {
+			measureCfg.AddRoute("startOp", "plaintext", fragmentOutFunc.Label, "fragmentOp")
+
+			fragmentOutStr := createFrameCfg(pp, suite, "fragmentOp", "plaintext", encryptOutFunc.Label, "encryptOp")
+			epCPSrcInit.AddCfg(encryptPerf, fragmentOutFunc, fragmentOutStr)
+			reassembleRtnStr := createFrameCfg(pp, suite, "reassembleOp", "finishtext", srcFunc.Label, "completeOp")
+			epCPSrcInit.AddCfg(encryptPerf, reassembleRtnFunc, reassembleRtnStr)
+		} else {
+			measureCfg.AddRoute("startOp", "plaintext", encryptOutFunc.Label, "encryptOp")
+		}
+		measureCfg.AddRoute("endOp", "finishtext", finishFunc.Label, "finishOp")
+
+		// until eudMark narrows it, the route of a packet is that to any EUD of the group, and a packet
+		// lost to an outage on it is returned to cycleDst
+		groupDevs := make(map[string]bool)
+		for idx := 0; idx < euds; idx++ {
+			if eudGroup[idx] != gdx {
+				continue
+			}
+			for _, devName := range pp.roundTripDevs(group, idx) {
+				groupDevs[devName] = true
+			}
+		}
+		measureCfg.Path = sortedDevs(groupDevs)
+		measureCfg.SetLost("finishtext", srcFunc.Label, "completeOp")
+		measureStr, merr := measureCfg.Serialize(useYAML)
+		if merr != nil {
+			panic(merr)
+		}
+		epCPSrcInit.AddCfg(encryptPerf, measureFunc, measureStr)
+
+		// the CmpPtns of a packet source run side by side, so the time between the bursts of each is stretched
+		// by the inverse of its share of the source's EUDs, keeping the rate the source sends bursts at burstMu
+		srcBurstDist := pp.burstDist.Scale(float64(pp.srcEUDs[group.src]) / float64(len(dstList)))
+
+		// pace passes on the packets, kept or thinned by profile, to measure
+		paceDists := []Dist{pp.pcktDist, srcBurstDist, pp.cycleDist}
+		if pp.profiled() {
+			paceStr := createPaceCfg(pp, paceDists, len(dstList), profileFunc.Label, "thinOp")
+			epCPSrcInit.AddCfg(encryptPerf, paceFunc, paceStr)
+			profileStr := createProfileCfg(pp, measureFunc.Label, "startOp", srcFunc.Label)
+			epCPSrcInit.AddCfg(encryptPerf, profileFunc, profileStr)
+		} else {
+			paceStr := createPaceCfg(pp, paceDists, len(dstList), measureFunc.Label, "startOp")
+			epCPSrcInit.AddCfg(encryptPerf, paceFunc, paceStr)
+		}
+
+		cpDict.AddCompPattern(encryptPerf)
+		cpInitDict.AddCPInitList(epCPSrcInit)
+
+		// map the functions of the packet source.
+		// The session group names the devices hosting the packet source and the crypto functions
+		cmpMap := pces.CreateCompPatternMap(encryptPerf.Name)
+		cmpMap.AddMapping(srcFunc.Label, group.src, false)
+		cmpMap.AddMapping(paceFunc.Label, group.src, false)
+		cmpMap.AddMapping(finishFunc.Label, group.src, false)
+		cmpMap.AddMapping(measureFunc.Label, group.src, false)
+		cmpMap.AddMapping(encryptOutFunc.Label, group.crypto, false)
+		cmpMap.AddMapping(decryptRtnFunc.Label, group.crypto, false)
+		if pp.inspected() {
+			cmpMap.AddMapping(inspectOutFunc.Label, archSpec.Inspect.Dev, false)
+			cmpMap.AddMapping(inspectRtnFunc.Label, archSpec.Inspect.Dev, false)
+			timingUses = append(timingUses, timingUse{code: archSpec.Inspect.OpCode(), dev: archSpec.Inspect.Dev})
+		}
+		if pp.framed() {
+			cmpMap.AddMapping(fragmentOutFunc.Label, group.src, false)
+			cmpMap.AddMapping(reassembleRtnFunc.Label, group.src, false)
+		}
+		if pp.profiled() {
+			cmpMap.AddMapping(profileFunc.Label, group.src, false)
+		}
+		cmpMapDict.AddCompPatternMap(cmpMap, false)
+
+		timingUses = append(timingUses,
+			timingUse{code: "generateOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: "completeOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: "finishOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: cryptoOpCode("encrypt", suite.Alg, suite.KeyLength), dev: group.crypto},
+			timingUse{code: cryptoOpCode("decrypt", suite.Alg, suite.KeyLength), dev: group.crypto})
+	}
+
+	// map the functions of each EUD CmpPtn to its EUD, but for its crypto when a gateway does it
+	for idx := 0; idx < euds; idx++ {
+		suite := suites[eudSuite[idx]]
+		eudDevName := EUDName(idx)
+		eudCryptoDev := archSpec.eudCryptoDev(idx)
+
+		cmpMap := pces.CreateCompPatternMap(eudCPBaseName + "-" + strconv.Itoa(idx))
+		cmpMap.AddMapping(decryptOutFunc.Label, eudCryptoDev, false)
+		cmpMap.AddMapping(eudMarkFunc.Label, eudDevName, false)
+		cmpMap.AddMapping(processFunc.Label, eudDevName, false)
+		cmpMap.AddMapping(encryptRtnFunc.Label, eudCryptoDev, false)
+		if pp.framed() {
+			cmpMap.AddMapping(reassembleOutFunc.Label, eudDevName, false)
+			cmpMap.AddMapping(fragmentRtnFunc.Label, eudDevName, false)
+		}
+		cmpMapDict.AddCompPatternMap(cmpMap, false)
+
+		timingUses = append(timingUses,
+			timingUse{code: cryptoOpCode("decrypt", suite.Alg, suite.KeyLength), dev: eudCryptoDev},
+			timingUse{code: "processEUD", dev: eudDevName, pcktLen: pp.msgPcktLen()},
+			timingUse{code: cryptoOpCode("encrypt", suite.Alg, suite.KeyLength), dev: eudCryptoDev})
+	}
+	return timingUses
+}
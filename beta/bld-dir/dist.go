@@ -0,0 +1,243 @@
+package main
+
+// code to describe the distributions of the times between packets, between bursts, and between cycles.
+// A distribution is given on the command line as name(args), e.g. exp(5ms), uniform(1ms,3ms),
+// lognormal(0.5,1.2), pareto(1.5,2ms), or empirical(gaps.csv).   A bare number is a constant time
+// in milliseconds.   Times may carry a unit, s, ms, us, or ns, and are in milliseconds without one
+
+import (
+	"fmt"
+	"github.com/iti/measure"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dist is a distribution of times.  Params hold the times it is described by, in seconds:
+// the mean of const and exp, the least and greatest times of uniform, and the times drawn from
+// by empirical.  lognormal has the mean and standard deviation of the log of the time in seconds,
+// and pareto the shape alpha and the scale xm (in seconds)
+type Dist struct {
+	Name   string
+	Params []float64
+}
+
+// timeUnits gives the number of seconds in each unit of time
+var timeUnits map[string]float64 = map[string]float64{"s": 1.0, "ms": 1e-3, "us": 1e-6, "ns": 1e-9}
+
+// parseTime transforms a time, e.g. "5ms" or "0.2", into seconds.  A time without a unit is in milliseconds
+func parseTime(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+	scale := timeUnits["ms"]
+
+	// "s" is checked last, as it ends the other units
+	for _, unit := range []string{"ms", "us", "ns", "s"} {
+		if strings.HasSuffix(str, unit) {
+			scale = timeUnits[unit]
+			str = strings.TrimSpace(strings.TrimSuffix(str, unit))
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0.0, fmt.Errorf("%s is not a time", str)
+	}
+	return value * scale, nil
+}
+
+// ParseDist transforms the description of a distribution given on the command line into a Dist
+func ParseDist(desc string) (Dist, error) {
+	desc = strings.TrimSpace(desc)
+
+	// a bare number is a constant
+	open := strings.Index(desc, "(")
+	if open < 0 {
+		value, err := parseTime(desc)
+		if err != nil {
+			return Dist{}, fmt.Errorf("distribution %s is neither a number nor of the form name(args)", desc)
+		}
+		return Dist{Name: "const", Params: []float64{value}}.check(desc)
+	}
+	if !strings.HasSuffix(desc, ")") {
+		return Dist{}, fmt.Errorf("distribution %s is not of the form name(args)", desc)
+	}
+
+	name := strings.ToLower(strings.TrimSpace(desc[:open]))
+	args := strings.Split(desc[open+1:len(desc)-1], ",")
+
+	// the empirical distribution draws from the times in a file
+	if name == "empirical" {
+		if len(args) != 1 {
+			return Dist{}, fmt.Errorf("distribution %s names more than one file", desc)
+		}
+		values, err := readEmpirical(strings.TrimSpace(args[0]))
+		if err != nil {
+			return Dist{}, err
+		}
+		return Dist{Name: name, Params: values}.check(desc)
+	}
+
+	// the arguments of each distribution, and which of them are times.  The others are plain numbers
+	argTimes := map[string][]bool{"const": []bool{true}, "exp": []bool{true}, "uniform": []bool{true, true},
+		"lognormal": []bool{false, false}, "pareto": []bool{false, true}}
+
+	isTime, present := argTimes[name]
+	if !present {
+		return Dist{}, fmt.Errorf("distribution %s is not const, exp, uniform, lognormal, pareto, or empirical", desc)
+	}
+	if len(args) != len(isTime) {
+		return Dist{}, fmt.Errorf("distribution %s needs %d arguments", desc, len(isTime))
+	}
+
+	params := make([]float64, len(args))
+	for adx, arg := range args {
+		var err error
+		if isTime[adx] {
+			params[adx], err = parseTime(arg)
+		} else {
+			params[adx], err = strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		}
+		if err != nil {
+			return Dist{}, fmt.Errorf("distribution %s has argument %s that is not a number", desc, arg)
+		}
+	}
+
+	// the log of a time in milliseconds is made the log of the time in seconds
+	if name == "lognormal" {
+		params[0] += math.Log(timeUnits["ms"])
+	}
+	return Dist{Name: name, Params: params}.check(desc)
+}
+
+// readEmpirical reads the times of an empirical distribution from a csv file, separated by commas
+// and newlines.   Empty lines and lines starting with '#' are skipped, as is a first line holding no times
+func readEmpirical(filename string) ([]float64, error) {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []float64{}
+	for ldx, line := range strings.Split(string(dict), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Split(line, ",") {
+			if len(strings.TrimSpace(field)) == 0 {
+				continue
+			}
+			value, terr := parseTime(field)
+			if terr != nil {
+				if ldx == 0 {
+					break
+				}
+				return nil, fmt.Errorf("line %d of %s: %s", ldx+1, filename, terr.Error())
+			}
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empirical distribution file %s holds no times", filename)
+	}
+	return values, nil
+}
+
+// check returns the distribution, or an error if its parameters are out of range.  desc is
+// the description it was parsed from
+func (d Dist) check(desc string) (Dist, error) {
+	bad := false
+	switch d.Name {
+	case "const", "exp":
+		bad = d.Params[0] < 0.0
+	case "uniform":
+		bad = d.Params[0] < 0.0 || d.Params[1] < d.Params[0]
+	case "lognormal":
+		bad = d.Params[1] < 0.0
+	case "pareto":
+		// the mean is infinite unless the shape exceeds 1
+		bad = d.Params[0] <= 1.0 || d.Params[1] <= 0.0
+	case "empirical":
+		for _, value := range d.Params {
+			bad = bad || value < 0.0
+		}
+	}
+	if bad {
+		return Dist{}, fmt.Errorf("distribution %s has parameters out of range", desc)
+	}
+	return d, nil
+}
+
+// Mean gives the mean of the distribution, in seconds
+func (d Dist) Mean() float64 {
+	switch d.Name {
+	case "uniform":
+		return (d.Params[0] + d.Params[1]) / 2.0
+	case "lognormal":
+		return math.Exp(d.Params[0] + d.Params[1]*d.Params[1]/2.0)
+	case "pareto":
+		return d.Params[0] * d.Params[1] / (d.Params[0] - 1.0)
+	case "empirical":
+		sum := 0.0
+		for _, value := range d.Params {
+			sum += value
+		}
+		return sum / float64(len(d.Params))
+	}
+	return d.Params[0]
+}
+
+// Scale returns the distribution of the times of d multiplied by factor
+func (d Dist) Scale(factor float64) Dist {
+	scaled := Dist{Name: d.Name, Params: make([]float64, len(d.Params))}
+	copy(scaled.Params, d.Params)
+
+	switch d.Name {
+	case "lognormal":
+		scaled.Params[0] += math.Log(factor)
+	case "pareto":
+		scaled.Params[1] *= factor
+	default:
+		for pdx := range scaled.Params {
+			scaled.Params[pdx] *= factor
+		}
+	}
+	return scaled
+}
+
+// String gives the full description of the distribution, its times in seconds, e.g. 'uniform(0.001,0.003)'
+func (d Dist) String() string {
+	params := make([]string, len(d.Params))
+	for pdx, param := range d.Params {
+		params[pdx] = strconv.FormatFloat(param, 'g', -1, 64)
+	}
+	return d.Name + "(" + strings.Join(params, ",") + ")"
+}
+
+// arrivalStream gives out the next arrival stream, for a Func shaping the arrivals.  The Funcs are
+// built in the same order whatever the architecture, so each gets the same stream in every architecture
+func (pp *ptnParams) arrivalStream() int {
+	pp.streams += 1
+	return pp.streams
+}
+
+// createPaceCfg creates and serializes the cfg of a pace Func, which spaces out the packets a source
+// generates without delay, drawing the times between packets, between bursts, and between cycles from
+// dists, shortened under a load profile.  A cycle has bursts bursts, of -pcktburst packets each.
+// The Func passes the packets to the Func labeled tgtLabel, with method code tgtMC
+func createPaceCfg(pp *ptnParams, dists []Dist, bursts int, tgtLabel, tgtMC string) string {
+	cfg := measure.CreatePaceCfg(pp.pcktBurst, bursts)
+	cfg.Stream = pp.arrivalStream()
+	for _, dist := range dists {
+		genDist := pp.genDist(dist)
+		cfg.AddGap(genDist.Name, genDist.Params)
+	}
+	cfg.AddRoute("paceOp", "plaintext", tgtLabel, tgtMC)
+
+	serialCfg, err := cfg.Serialize(useYAML)
+	if err != nil {
+		panic(err)
+	}
+	return serialCfg
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// closeTo is true when got is within a relative 1e-9 of want
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1.0, math.Abs(want))
+}
+
+// TestParseDist checks the parsing of distributions, their times made seconds, and the rejection of bad ones
+func TestParseDist(t *testing.T) {
+	tests := []struct {
+		desc   string
+		name   string
+		params []float64
+		bad    bool
+	}{
+		{"5", "const", []float64{0.005}, false},
+		{" 2.5 ", "const", []float64{0.0025}, false},
+		{"5ms", "const", []float64{0.005}, false},
+		{"1s", "const", []float64{1.0}, false},
+		{"exp(5ms)", "exp", []float64{0.005}, false},
+		{"exp(5)", "exp", []float64{0.005}, false},
+		{"EXP(250us)", "exp", []float64{0.00025}, false},
+		{"exp(100ns)", "exp", []float64{1e-7}, false},
+		{"uniform(1ms, 3ms)", "uniform", []float64{0.001, 0.003}, false},
+		{"uniform(1,2s)", "uniform", []float64{0.001, 2.0}, false},
+
+		// the log of a time in milliseconds is made the log of one in seconds
+		{"lognormal(0.5,1.2)", "lognormal", []float64{0.5 + math.Log(1e-3), 1.2}, false},
+		{"pareto(1.5,2ms)", "pareto", []float64{1.5, 0.002}, false},
+		{"pareto(1.5,2)", "pareto", []float64{1.5, 0.002}, false},
+
+		{"", "", nil, true},
+		{"fast", "", nil, true},
+		{"exp(5ms", "", nil, true},
+		{"exp(5min)", "", nil, true},
+		{"normal(5,1)", "", nil, true},
+		{"exp(1,2)", "", nil, true},
+		{"uniform(3,1)", "", nil, true},
+		{"exp(-1)", "", nil, true},
+		{"lognormal(0.5,-1)", "", nil, true},
+		{"pareto(1,2)", "", nil, true},
+		{"pareto(1.5,0)", "", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParseDist(test.desc)
+		if test.bad {
+			if err == nil {
+				t.Errorf("ParseDist(%q) = %v, want an error", test.desc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDist(%q) gives error %v", test.desc, err)
+			continue
+		}
+		if got.Name != test.name || len(got.Params) != len(test.params) {
+			t.Errorf("ParseDist(%q) = %v, want %s%v", test.desc, got, test.name, test.params)
+			continue
+		}
+		for pdx := range got.Params {
+			if !closeTo(got.Params[pdx], test.params[pdx]) {
+				t.Errorf("ParseDist(%q) = %v, want %s%v", test.desc, got, test.name, test.params)
+				break
+			}
+		}
+	}
+}
+
+// TestParseEmpirical checks that an empirical distribution reads its times from a file, skipping
+// a header line and comments
+func TestParseEmpirical(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "gaps.csv")
+	err := os.WriteFile(filename, []byte("gap\n# measured\n1, 2ms\n\n500us\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseDist("empirical(" + filename + ")")
+	if err != nil {
+		t.Fatalf("ParseDist of an empirical distribution gives error %v", err)
+	}
+	want := []float64{0.001, 0.002, 0.0005}
+	if got.Name != "empirical" || len(got.Params) != len(want) {
+		t.Fatalf("ParseDist of an empirical distribution = %v, want empirical%v", got, want)
+	}
+	for pdx := range want {
+		if !closeTo(got.Params[pdx], want[pdx]) {
+			t.Errorf("ParseDist of an empirical distribution = %v, want empirical%v", got, want)
+			break
+		}
+	}
+}
+
+// TestScale checks that scaling a distribution scales its parameters as its kind calls for,
+// and so scales its mean
+func TestScale(t *testing.T) {
+	tests := []struct {
+		dist   Dist
+		factor float64
+		params []float64
+	}{
+		{Dist{Name: "const", Params: []float64{0.005}}, 0.5, []float64{0.0025}},
+		{Dist{Name: "exp", Params: []float64{0.005}}, 2.0, []float64{0.01}},
+		{Dist{Name: "uniform", Params: []float64{0.001, 0.003}}, 0.5, []float64{0.0005, 0.0015}},
+		{Dist{Name: "empirical", Params: []float64{0.001, 0.002, 0.0005}}, 4.0, []float64{0.004, 0.008, 0.002}},
+
+		// the log of the time moves by the log of the factor, and its deviation is unchanged
+		{Dist{Name: "lognormal", Params: []float64{-6.0, 1.2}}, 0.25, []float64{-6.0 + math.Log(0.25), 1.2}},
+
+		// the scale is scaled and the shape unchanged
+		{Dist{Name: "pareto", Params: []float64{1.5, 0.002}}, 0.25, []float64{1.5, 0.0005}},
+	}
+	for _, test := range tests {
+		got := test.dist.Scale(test.factor)
+		if got.Name != test.dist.Name || len(got.Params) != len(test.params) {
+			t.Errorf("%v scaled by %g = %v, want %s%v", test.dist, test.factor, got, test.dist.Name, test.params)
+			continue
+		}
+		for pdx := range got.Params {
+			if !closeTo(got.Params[pdx], test.params[pdx]) {
+				t.Errorf("%v scaled by %g = %v, want %s%v", test.dist, test.factor, got, test.dist.Name, test.params)
+				break
+			}
+		}
+		if !closeTo(got.Mean(), test.factor*test.dist.Mean()) {
+			t.Errorf("%v scaled by %g has mean %g, want %g", test.dist, test.factor, got.Mean(), test.factor*test.dist.Mean())
+		}
+	}
+
+	// scaling leaves the distribution scaled unchanged
+	dist := Dist{Name: "uniform", Params: []float64{0.001, 0.003}}
+	dist.Scale(2.0)
+	if dist.Params[0] != 0.001 || dist.Params[1] != 0.003 {
+		t.Errorf("scaling changed the distribution scaled to %v", dist)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+// code to describe a population of EUDs drawn from several classes of device, e.g. laptops,
+// thin clients, and embedded devices, and to assign the EUDs of the architecture to the classes
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EUDClass describes a class of EUD: the fraction of the EUDs in the class, and the
+// CPU model, number of cores, and interface bandwidth (Mbps) of every EUD in it.
+// The class name is also the name of the group the class's EUDs belong to in the topology
+type EUDClass struct {
+	Name      string  `json:"name" yaml:"name"`
+	Fraction  float64 `json:"fraction" yaml:"fraction"`
+	Model     string  `json:"model" yaml:"model"`
+	Cores     int     `json:"cores" yaml:"cores"`
+	Bandwidth string  `json:"bandwidth" yaml:"bandwidth"`
+}
+
+// ParseEUDMix transforms the description of an EUD mix given on the command line into a list of classes.
+// The classes are separated by commas, and each gives name:fraction:model:cores:bandwidth, e.g.
+// "laptop:0.6:Intel-i3-4130:2:100,embedded:0.4:ARM-Denver-2:1:10"
+func ParseEUDMix(mixStr string) ([]EUDClass, error) {
+	mix := []EUDClass{}
+	for _, classStr := range strings.Split(mixStr, ",") {
+		fields := strings.Split(strings.TrimSpace(classStr), ":")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("EUD class %s is not of the form name:fraction:model:cores:bandwidth", classStr)
+		}
+		fraction, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("EUD class %s has fraction %s that is not a number", fields[0], fields[1])
+		}
+		cores, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("EUD class %s has cores %s that is not an integer", fields[0], fields[3])
+		}
+		mix = append(mix, EUDClass{Name: fields[0], Fraction: fraction, Model: fields[2], Cores: cores,
+			Bandwidth: fields[4]})
+	}
+	return mix, nil
+}
+
+// validateMix checks the classes of the EUD mix, returning every problem found
+func (es *EUDSpec) validateMix() []error {
+	errs := []error{}
+	names := make(map[string]bool)
+	for _, eudClass := range es.Mix {
+		if len(eudClass.Name) == 0 {
+			errs = append(errs, fmt.Errorf("EUD class has no name"))
+		}
+		if names[eudClass.Name] {
+			errs = append(errs, fmt.Errorf("EUD class %s declared more than once", eudClass.Name))
+		}
+		names[eudClass.Name] = true
+
+		// 'EUD' is the group every EUD belongs to
+		if eudClass.Name == "EUD" {
+			errs = append(errs, fmt.Errorf("EUD class cannot be named EUD"))
+		}
+		if eudClass.Fraction <= 0.0 {
+			errs = append(errs, fmt.Errorf("EUD class %s has fraction %g that is not positive", eudClass.Name, eudClass.Fraction))
+		}
+		if len(eudClass.Model) == 0 {
+			errs = append(errs, fmt.Errorf("EUD class %s has no model", eudClass.Name))
+		}
+		if eudClass.Cores < 1 {
+			errs = append(errs, fmt.Errorf("EUD class %s needs at least one core", eudClass.Name))
+		}
+		if _, err := strconv.ParseFloat(eudClass.Bandwidth, 64); err != nil {
+			errs = append(errs, fmt.Errorf("EUD class %s has bandwidth %s that is not a number", eudClass.Name, eudClass.Bandwidth))
+		}
+	}
+	return errs
+}
+
+// Class returns the class of the idx-th EUD.  Without a mix every EUD belongs to
+// an unnamed class with the model, cores, and bandwidth the EUDSpec gives
+func (es *EUDSpec) Class(idx int) EUDClass {
+	if len(es.Mix) == 0 {
+		return EUDClass{Fraction: 1.0, Model: es.Model, Cores: es.Cores, Bandwidth: es.Bandwidth}
+	}
+	if es.assigned == nil {
+		es.assigned = es.assignClasses()
+	}
+	return es.assigned[idx]
+}
+
+// assignClasses assigns the classes of the mix to the EUDs in proportion to their fractions,
+// in an order drawn using the seed of the EUDSpec, so that the classes are spread through the switch tree
+func (es *EUDSpec) assignClasses() []EUDClass {
+	fractions := make([]float64, len(es.Mix))
+	for idx, eudClass := range es.Mix {
+		fractions[idx] = eudClass.Fraction
+	}
+
+	classes := make([]EUDClass, es.Count)
+	for idx, cdx := range apportion(fractions, es.Count, es.Seed) {
+		classes[idx] = es.Mix[cdx]
+	}
+	return classes
+}
+
+// apportion divides count items among classes in proportion to their fractions, and returns the
+// index of the class each item is assigned to.   The fractions are normalized, and the shares
+// rounded by largest remainder so that they add up to count.   The order in which the classes are
+// assigned to the items is shuffled using seed, so that the same seed gives the same assignment
+func apportion(fractions []float64, count int, seed int64) []int {
+	total := 0.0
+	for _, fraction := range fractions {
+		total += fraction
+	}
+
+	shares := make([]int, len(fractions))
+	remainders := make([]float64, len(fractions))
+	assigned := 0
+	for idx, fraction := range fractions {
+		exact := float64(count) * fraction / total
+		shares[idx] = int(math.Floor(exact))
+		remainders[idx] = exact - float64(shares[idx])
+		assigned += shares[idx]
+	}
+
+	// the items left over go to the classes with the largest remainders, the earlier class winning ties
+	order := make([]int, len(fractions))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+	for jdx := 0; assigned < count; jdx++ {
+		shares[order[jdx%len(order)]] += 1
+		assigned += 1
+	}
+
+	classIdx := []int{}
+	for idx := range fractions {
+		for jdx := 0; jdx < shares[idx]; jdx++ {
+			classIdx = append(classIdx, idx)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(classIdx), func(i, j int) { classIdx[i], classIdx[j] = classIdx[j], classIdx[i] })
+	return classIdx
+}
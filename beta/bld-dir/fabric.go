@@ -0,0 +1,272 @@
+package main
+
+// code to build the access fabric, the switches that connect the EUDs to the device named
+// by the EUDSpec's Attach.   Sites cable their EUDs differently, and the number of switches
+// a packet crosses to reach an EUD shows up in the RTT
+
+import (
+	"fmt"
+	"github.com/iti/mrnes"
+	"math"
+	"strconv"
+)
+
+// fabrics lists the access fabrics that can be built.
+//   - "tree" grows a tree breadth first from eudSwitch-0, filling the switches created last with EUDs
+//   - "kary" is a balanced tree of the least depth whose switches have switchports-1 children,
+//     with the EUDs spread evenly across the leaves
+//   - "star" is a layer of access switches holding the EUDs, each cabled to one of a layer of
+//     aggregation switches cabled to the attach device
+//   - "chain" is a daisy chain of switches, each cabled to the next and holding as many EUDs as its ports allow
+//   - "leafspine" is a layer of leaf switches holding the EUDs, each cabled to every one of the spine switches,
+//     which are cabled to the attach device
+var fabrics map[string]bool = map[string]bool{"tree": true, "kary": true, "star": true, "chain": true, "leafspine": true}
+
+// defaultSpines is the number of spine switches of a leaf/spine fabric when the EUDSpec does not give it
+const defaultSpines = 2
+
+// spines returns the number of spine switches of a leaf/spine fabric
+func (es *EUDSpec) spines() int {
+	if es.Spines > 0 {
+		return es.Spines
+	}
+	return defaultSpines
+}
+
+// validateFabric checks that the access fabric is recognized, and that its switches have
+// the ports it needs, returning every problem found
+func (es *EUDSpec) validateFabric() []error {
+	errs := []error{}
+	if _, present := fabrics[es.Fabric]; !present && len(es.Fabric) > 0 {
+		errs = append(errs, fmt.Errorf("unrecognized access fabric %s", es.Fabric))
+	}
+
+	if es.Fabric == "leafspine" && es.SwitchPorts >= 3 {
+		spines := es.spines()
+		if spines >= es.SwitchPorts {
+			errs = append(errs, fmt.Errorf("leaf switches with %d ports cannot reach %d spines and hold EUDs",
+				es.SwitchPorts, spines))
+		} else if leaves := ceilDiv(es.Count, es.SwitchPorts-spines); leaves > es.SwitchPorts-1 {
+			errs = append(errs, fmt.Errorf("%d EUDs need %d leaf switches, more than spine switches with %d ports can reach",
+				es.Count, leaves, es.SwitchPorts))
+		}
+	}
+	return errs
+}
+
+// ceilDiv returns the least integer not less than num/den
+func ceilDiv(num, den int) int {
+	return (num + den - 1) / den
+}
+
+// eudSwitchName returns the name of the idx-th switch of the access fabric
+func eudSwitchName(idx int) string {
+	return "eudSwitch-" + strconv.Itoa(idx)
+}
+
+// spread returns the index of the bin the idx-th of count items falls in, when the items are
+// laid in order into bins so that no two bins hold numbers differing by more than one
+func spread(idx, count, bins int) int {
+	return idx * bins / count
+}
+
+// buildFabric creates the switches of the access fabric the EUDSpec selects, cabling them to one another
+// and to attach.  It returns the switches, the first being the root of the fabric, and the switch
+// each EUD is to be cabled to
+func buildFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	switch es.Fabric {
+	case "kary":
+		return karyFabric(es, attach, netName)
+	case "star":
+		return starFabric(es, attach, netName)
+	case "chain":
+		return chainFabric(es, attach, netName)
+	case "leafspine":
+		return leafSpineFabric(es, attach, netName)
+	}
+	return treeFabric(es, attach, netName)
+}
+
+// treeFabric builds the breadth-first tree the beta model has always used
+func treeFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	switchports := es.SwitchPorts
+	euds := es.Count
+
+	// how many switches for direct connects to euds are needed?
+	baseSwitches, excess := math.Modf(float64(euds) / float64(switchports-1))
+	if excess > 0.0 {
+		baseSwitches += 1
+	}
+
+	// create the switches
+	eudSwitches := make([]*mrnes.SwitchFrame, 0)
+	eudSwitches = append(eudSwitches, mrnes.CreateSwitch(eudSwitchName(0), es.SwitchModel))
+
+	// connect eudSwitches[0] to the device the tree attaches to
+	connectDevs(attach, eudSwitches[0], true, netName)
+	availablePorts := switchports - 1
+
+	expandSwitchIdx := 0
+
+	// so long as the unassigned ports on the switches in the switch tree don't accomodate all euds
+	for availablePorts < euds {
+
+		// make the switch a parent of up to switchports-1 descendent switches
+		children := make([]*mrnes.SwitchFrame, 0)
+		jdx := 0
+
+		// create another if still needed and have not overflowed the paraent's capacity
+		for jdx < switchports-1 && availablePorts < euds {
+			nswtch := mrnes.CreateSwitch(eudSwitchName(len(eudSwitches)+jdx), es.SwitchModel)
+			connectDevs(nswtch, eudSwitches[expandSwitchIdx], true, netName)
+			children = append(children, nswtch)
+
+			// availablePorts increases by the free ports of the new switch, less the parent port
+			// used to connect to it
+			availablePorts += (switchports - 2)
+			jdx += 1
+		}
+		eudSwitches = append(eudSwitches, children...)
+		// move to the next unparented switch
+		expandSwitchIdx += 1
+	}
+
+	// the EUDs are given to the switches from the last created
+	access := make([]*mrnes.SwitchFrame, euds)
+	assignTo := len(eudSwitches) - 1
+	assignedThisSwitch := 0
+
+	for jdx := 0; jdx < euds; jdx++ {
+		access[jdx] = eudSwitches[assignTo]
+		assignedThisSwitch += 1
+		if assignedThisSwitch == switchports-1 {
+			assignedThisSwitch = 0
+			assignTo -= 1
+		}
+	}
+	return eudSwitches, access
+}
+
+// karyFabric builds a balanced tree of the least depth.  The number of switches at each level is
+// found from the leaves up, and the switches of a level are spread evenly across the level above
+func karyFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	fanout := es.SwitchPorts - 1
+
+	// levelSize[0] is the number of leaves, the last entry the root
+	levelSize := []int{ceilDiv(es.Count, fanout)}
+	for levelSize[len(levelSize)-1] > 1 {
+		levelSize = append(levelSize, ceilDiv(levelSize[len(levelSize)-1], fanout))
+	}
+
+	eudSwitches := make([]*mrnes.SwitchFrame, 0)
+	var above []*mrnes.SwitchFrame
+	for ldx := len(levelSize) - 1; ldx >= 0; ldx-- {
+		level := make([]*mrnes.SwitchFrame, levelSize[ldx])
+		for sdx := range level {
+			level[sdx] = mrnes.CreateSwitch(eudSwitchName(len(eudSwitches)+sdx), es.SwitchModel)
+			if above == nil {
+				connectDevs(attach, level[sdx], true, netName)
+			} else {
+				connectDevs(level[sdx], above[spread(sdx, len(level), len(above))], true, netName)
+			}
+		}
+		eudSwitches = append(eudSwitches, level...)
+		above = level
+	}
+
+	// above now holds the leaves
+	access := make([]*mrnes.SwitchFrame, es.Count)
+	for jdx := range access {
+		access[jdx] = above[spread(jdx, es.Count, len(above))]
+	}
+	return eudSwitches, access
+}
+
+// starFabric builds a layer of access switches, each holding up to switchports-1 EUDs, below a layer
+// of aggregation switches, each reaching up to switchports-1 access switches
+func starFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	accessSwitches := ceilDiv(es.Count, es.SwitchPorts-1)
+	aggSwitches := ceilDiv(accessSwitches, es.SwitchPorts-1)
+
+	eudSwitches := make([]*mrnes.SwitchFrame, 0)
+	for adx := 0; adx < aggSwitches; adx++ {
+		agg := mrnes.CreateSwitch(eudSwitchName(adx), es.SwitchModel)
+		connectDevs(attach, agg, true, netName)
+		eudSwitches = append(eudSwitches, agg)
+	}
+
+	leaves := make([]*mrnes.SwitchFrame, accessSwitches)
+	for sdx := range leaves {
+		leaves[sdx] = mrnes.CreateSwitch(eudSwitchName(aggSwitches+sdx), es.SwitchModel)
+		connectDevs(leaves[sdx], eudSwitches[spread(sdx, accessSwitches, aggSwitches)], true, netName)
+	}
+	eudSwitches = append(eudSwitches, leaves...)
+
+	access := make([]*mrnes.SwitchFrame, es.Count)
+	for jdx := range access {
+		access[jdx] = leaves[spread(jdx, es.Count, accessSwitches)]
+	}
+	return eudSwitches, access
+}
+
+// chainFabric builds a daisy chain of switches.  Each switch but the last gives a port to the switch
+// before it and one to the switch after it, and the rest to EUDs, which fill the chain from its head
+func chainFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	perSwitch := es.SwitchPorts - 2
+
+	// the last switch has no switch after it, and so has a port more for an EUD
+	chainLen := 1
+	if es.Count > perSwitch+1 {
+		chainLen = ceilDiv(es.Count-1, perSwitch)
+	}
+
+	eudSwitches := make([]*mrnes.SwitchFrame, chainLen)
+	for sdx := range eudSwitches {
+		eudSwitches[sdx] = mrnes.CreateSwitch(eudSwitchName(sdx), es.SwitchModel)
+		if sdx == 0 {
+			connectDevs(attach, eudSwitches[sdx], true, netName)
+		} else {
+			connectDevs(eudSwitches[sdx], eudSwitches[sdx-1], true, netName)
+		}
+	}
+
+	access := make([]*mrnes.SwitchFrame, es.Count)
+	for jdx := range access {
+		sdx := jdx / perSwitch
+		if sdx >= chainLen {
+			sdx = chainLen - 1
+		}
+		access[jdx] = eudSwitches[sdx]
+	}
+	return eudSwitches, access
+}
+
+// leafSpineFabric builds a layer of spine switches cabled to the attach device, and a layer of
+// leaf switches holding the EUDs, every leaf cabled to every spine.  A leaf gives a port to
+// each spine, and the rest to EUDs
+func leafSpineFabric(es *EUDSpec, attach mrnes.TopoDev, netName string) ([]*mrnes.SwitchFrame, []*mrnes.SwitchFrame) {
+	spines := es.spines()
+	leafCount := ceilDiv(es.Count, es.SwitchPorts-spines)
+
+	eudSwitches := make([]*mrnes.SwitchFrame, 0)
+	for sdx := 0; sdx < spines; sdx++ {
+		spine := mrnes.CreateSwitch(eudSwitchName(sdx), es.SwitchModel)
+		connectDevs(attach, spine, true, netName)
+		eudSwitches = append(eudSwitches, spine)
+	}
+
+	leaves := make([]*mrnes.SwitchFrame, leafCount)
+	for ldx := range leaves {
+		leaves[ldx] = mrnes.CreateSwitch(eudSwitchName(spines+ldx), es.SwitchModel)
+		for sdx := 0; sdx < spines; sdx++ {
+			connectDevs(leaves[ldx], eudSwitches[sdx], true, netName)
+		}
+	}
+	eudSwitches = append(eudSwitches, leaves...)
+
+	access := make([]*mrnes.SwitchFrame, es.Count)
+	for jdx := range access {
+		access[jdx] = leaves[spread(jdx, es.Count, leafCount)]
+	}
+	return eudSwitches, access
+}
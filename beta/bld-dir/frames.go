@@ -0,0 +1,150 @@
+package main
+
+// code to carry application messages larger than a frame.  A message of -msgSize bytes is split into
+// frames of -pcktlen bytes by a frame Func on the device sending it, the frames cross the network
+// independently, and a frame Func on the device receiving them reassembles the message before it is
+// processed.  The crypto is applied either to each frame, as TLS applies it to each record, or to
+// the message as a whole
+
+import (
+	"fmt"
+	"github.com/iti/measure"
+	"github.com/iti/pces"
+	"sort"
+)
+
+// cryptoUnits lists the units the crypto can be applied to.  "record" encrypts and decrypts every
+// frame separately, "message" encrypts the message before it is split and decrypts it once reassembled
+var cryptoUnits map[string]bool = map[string]bool{"record": true, "message": true}
+
+// framed is true when a message is carried in more than one frame
+func (pp *ptnParams) framed() bool {
+	return pp.frames > 1
+}
+
+// srcLens gives the message and packet lengths of the messages a packet source of sessions
+// using the suite generates
+func (pp *ptnParams) srcLens(suite CryptoSuite) (int, int) {
+	if !pp.framed() {
+		return pp.frameLen(suite), pp.pcktSize
+	}
+	return pp.frames * pp.frameLen(suite), pp.msgSize
+}
+
+// msgPcktLen gives the packet length the functions handling whole messages are timed at,
+// zero meaning -pcktlen
+func (pp *ptnParams) msgPcktLen() int {
+	if !pp.framed() {
+		return 0
+	}
+	return pp.msgSize
+}
+
+// cryptoPcktLen gives the packet length the crypto functions are timed at, zero meaning -pcktlen
+func (pp *ptnParams) cryptoPcktLen() int {
+	if !pp.cryptoPerMsg {
+		return 0
+	}
+	return pp.msgPcktLen()
+}
+
+// createFrameCfg creates and serializes the cfg of a frame Func of a session using the suite, which passes what
+// it is given with method code methodCode on to the Func labeled tgtLabel, as messages of type msgType with method code tgtMC
+func createFrameCfg(pp *ptnParams, suite CryptoSuite, methodCode, msgType, tgtLabel, tgtMC string) string {
+	msgLen, pcktLen := pp.srcLens(suite)
+	cfg := measure.CreateFrameCfg(pp.frames, pp.pcktSize, pp.frameLen(suite), pcktLen, msgLen)
+	cfg.AddRoute(methodCode, msgType, tgtLabel, tgtMC)
+
+	serialCfg, err := cfg.Serialize(useYAML)
+	if err != nil {
+		panic(err)
+	}
+	return serialCfg
+}
+
+// deriveTimings adds to fel a timing for every function timed at a packet length that has no
+// measurement, derived from the measurements of the same timing code on the same CPU model.
+// The time is taken as linear in the packet length, along the line through the nearest lengths measured
+// either side of it, or beyond the lengths measured, the two nearest it, or in proportion to the length
+// when only one is measured.  Every timing derived
+// is printed, as it is written to funcExec.yaml with those measured.  A length outside the range measured
+// is refused unless extrapolate is set, as the line may not hold far from the measurements.
+// A function with no measurement at all is left for checkTimingCoverage to report
+func deriveTimings(as *ArchSpec, uses []timingUse, fel *pces.FuncExecList, extrapolate bool) error {
+	errs := []error{}
+	for _, use := range uses {
+		if use.pcktLen == 0 {
+			continue
+		}
+		model := as.DevModel(use.dev)
+
+		measured := []pces.FuncExecDesc{}
+		found := false
+		for _, fed := range fel.Times[use.code] {
+			if fed.CPUModel != model {
+				continue
+			}
+			if fed.PcktLen == use.pcktLen {
+				found = true
+				break
+			}
+			measured = append(measured, fed)
+		}
+		if found || len(measured) == 0 {
+			continue
+		}
+
+		// order the measurements by their distance from the length wanted
+		sort.Slice(measured, func(i, j int) bool {
+			return absInt(measured[i].PcktLen-use.pcktLen) < absInt(measured[j].PcktLen-use.pcktLen)
+		})
+
+		// the length wanted lies within the range measured when some measured length is below it and some above
+		below, above := false, false
+		for _, fed := range measured {
+			below = below || fed.PcktLen < use.pcktLen
+			above = above || fed.PcktLen > use.pcktLen
+		}
+		if !(below && above) && !extrapolate {
+			errs = append(errs, fmt.Errorf("no function timing for %s on model %s at packet length %d, outside the lengths measured (-extrapolate derives it)",
+				use.code, model, use.pcktLen))
+			continue
+		}
+
+		// within the range, the line is that through the nearest lengths measured either side of it
+		near := measured[0]
+		var other *pces.FuncExecDesc
+		for fdx := 1; fdx < len(measured); fdx++ {
+			if measured[fdx].PcktLen == near.PcktLen {
+				continue
+			}
+			if !(below && above) || (measured[fdx].PcktLen < use.pcktLen) != (near.PcktLen < use.pcktLen) {
+				other = &measured[fdx]
+				break
+			}
+		}
+		execTime := near.ExecTime * float64(use.pcktLen) / float64(near.PcktLen)
+		if other != nil {
+			slope := (other.ExecTime - near.ExecTime) / float64(other.PcktLen-near.PcktLen)
+			execTime = near.ExecTime + slope*float64(use.pcktLen-near.PcktLen)
+		}
+		if execTime < 0.0 {
+			execTime = 0.0
+		}
+		how := "interpolated"
+		if !(below && above) {
+			how = "extrapolated"
+		}
+		fmt.Printf("timing of %s on model %s at packet length %d %s as %g seconds\n", use.code, model, use.pcktLen, how, execTime)
+		fel.AddTiming(use.code, near.Param, model, use.pcktLen, execTime)
+	}
+	return pces.ReportErrs(errs)
+}
+
+// absInt returns the absolute value of an integer
+func absInt(val int) int {
+	if val < 0 {
+		return -val
+	}
+	return val
+}
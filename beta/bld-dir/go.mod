@@ -1,11 +1,15 @@
-module main
+module bld
+
+replace github.com/iti/measure => ../measure
 
 go 1.22.7
 
 require (
 	github.com/iti/cmdline v0.1.1
+	github.com/iti/measure v0.0.0-00010101000000-000000000000
 	github.com/iti/mrnes v0.0.13
 	github.com/iti/pces v0.0.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,5 +19,4 @@ require (
 	github.com/iti/rngstream v0.2.2 // indirect
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
 	gonum.org/v1/gonum v0.15.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
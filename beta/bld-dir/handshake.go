@@ -0,0 +1,199 @@
+package main
+
+// code to prepend a TLS-style session establishment to each EUD session.   Before the first data
+// packet of a session is sent, the crypto device signs its key share and hashes the transcript,
+// the EUD verifies the signature, computes the shared key, and hashes the transcript, and the crypto
+// device computes the shared key and hashes the transcript once more.   Each step is a processPckt Func
+// with a timing code of its own, e.g. 'sign-rsa-2048', 'verify-rsa-2048', 'keyex-ecdh-256', and 'hash-sha-256'
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/measure"
+	"github.com/iti/pces"
+)
+
+// handshakeSpec gives the suites of the handshake's signature, key exchange, and transcript hash.
+// A key exchange with no algorithm is left out of the handshake
+type handshakeSpec struct {
+	sig  CryptoSuite
+	kex  CryptoSuite
+	hash CryptoSuite
+}
+
+// handshakeFromFlags returns the handshake the command line describes, or nil when -handshake is not given
+func handshakeFromFlags(cp *cmdline.CmdParser) *handshakeSpec {
+	if !cp.IsLoaded("handshake") {
+		return nil
+	}
+
+	codes := map[string]string{"handshake": cp.GetVar("handshake").(string), "handshakeHash": "sha-256"}
+	for _, flag := range []string{"handshakeKex", "handshakeHash"} {
+		if cp.IsLoaded(flag) {
+			codes[flag] = cp.GetVar(flag).(string)
+		}
+	}
+
+	hs := new(handshakeSpec)
+	errs := []error{}
+	for flag, code := range codes {
+		suite, err := ParseCryptoSuite(code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flag %s: %s", flag, err.Error()))
+			continue
+		}
+		switch flag {
+		case "handshake":
+			hs.sig = suite
+		case "handshakeKex":
+			hs.kex = suite
+		case "handshakeHash":
+			hs.hash = suite
+		}
+	}
+	err := pces.ReportErrs(errs)
+	if err != nil {
+		panic(err)
+	}
+	return hs
+}
+
+// handshakeStep is one step of the handshake, the crypto operation and suite whose timing code it
+// is charged, whether it runs on the EUD or on the crypto device, and the type of the message it passes on
+type handshakeStep struct {
+	label   string
+	op      string
+	suite   CryptoSuite
+	atEUD   bool
+	msgType string
+}
+
+// steps lists the steps of the handshake in the order they are taken
+func (hs *handshakeSpec) steps() []handshakeStep {
+	// the server's hello goes out to the EUD, the EUD's key share comes back, and the server finishes
+	steps := []handshakeStep{
+		handshakeStep{label: "srvSign", op: "sign", suite: hs.sig, msgType: "hello"},
+		handshakeStep{label: "srvHash", op: "hash", suite: hs.hash, msgType: "hello"},
+		handshakeStep{label: "eudVerify", op: "verify", suite: hs.sig, atEUD: true, msgType: "keyshare"}}
+
+	if len(hs.kex.Alg) > 0 {
+		steps = append(steps, handshakeStep{label: "eudKex", op: "keyex", suite: hs.kex, atEUD: true, msgType: "keyshare"})
+	}
+	steps = append(steps, handshakeStep{label: "eudHash", op: "hash", suite: hs.hash, atEUD: true, msgType: "keyshare"})
+
+	if len(hs.kex.Alg) > 0 {
+		steps = append(steps, handshakeStep{label: "srvKex", op: "keyex", suite: hs.kex, msgType: "finished"})
+	}
+	steps = append(steps, handshakeStep{label: "srvFinish", op: "hash", suite: hs.hash, msgType: "finished"})
+	return steps
+}
+
+// checkTimings checks that the timing tables hold some timing for the code of every step of the
+// handshake.  beta ships no timings of asymmetric crypto, so these come from tables of the user's own,
+// and a handshake without them is refused before anything is built, naming every code missing.
+// Whether the tables cover the models of the devices running the steps is left to checkTimingCoverage
+func (hs *handshakeSpec) checkTimings(fel *pces.FuncExecList) {
+	errs := []error{}
+	reported := make(map[string]bool)
+	for _, step := range hs.steps() {
+		opCode := cryptoOpCode(step.op, step.suite.Alg, step.suite.KeyLength)
+		if len(fel.Times[opCode]) > 0 || reported[opCode] {
+			continue
+		}
+		reported[opCode] = true
+		errs = append(errs, fmt.Errorf("handshake step %s needs timing code %s, which no table under db/timing/funcExec holds",
+			step.label, opCode))
+	}
+	err := pces.ReportErrs(errs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// addHandshake prepends the handshake to a session CmpPtn whose data source is srcFunc.   A connSrc Func
+// 'hsSrc' starts the handshake and, when it completes, a measure Func 'hsMeasure' notes its RTT
+// in the measurement group named by group followed by '-handshake', and starts srcFunc.  The handshake
+// Funcs on the source side are mapped to the group's devices, those of the EUD to eudDevName.  Every
+// function that is timed is returned, with the device it is mapped to
+func (hs *handshakeSpec) addHandshake(pp *ptnParams, encryptPerf *pces.CompPattern, epCPInit *pces.CPInitList,
+	srcFunc *pces.Func, group *sessionGroup, eudDevName string, cmpMap *pces.CompPatternMap) []timingUse {
+
+	for _, msgType := range []string{"hsinit", "hello", "keyshare", "finished", "established"} {
+		epCPInit.AddMsg(pces.CreateCompPatternMsg(msgType, true))
+	}
+
+	hsSrcFunc := pces.CreateFunc("connSrc", "hsSrc")
+	hsMeasureFunc := pces.CreateFunc("measure", "hsMeasure")
+	encryptPerf.AddFunc(hsSrcFunc)
+	encryptPerf.AddFunc(hsMeasureFunc)
+
+	steps := hs.steps()
+	stepFuncs := make([]*pces.Func, len(steps))
+	for sdx, step := range steps {
+		stepFuncs[sdx] = pces.CreateFunc("processPckt", step.label)
+		encryptPerf.AddFunc(stepFuncs[sdx])
+	}
+
+	// hsSrc starts a single handshake, which passes through the steps in order and returns
+	encryptPerf.AddEdge(hsSrcFunc.Label, hsSrcFunc.Label, "initiate", "generateOp", &epCPInit.Msgs)
+	encryptPerf.AddEdge(hsSrcFunc.Label, hsMeasureFunc.Label, "hsinit", "startOp", &epCPInit.Msgs)
+	encryptPerf.AddEdge(hsMeasureFunc.Label, stepFuncs[0].Label, "hsinit", "processOp", &epCPInit.Msgs)
+	for sdx := 1; sdx < len(steps); sdx++ {
+		encryptPerf.AddEdge(stepFuncs[sdx-1].Label, stepFuncs[sdx].Label, steps[sdx-1].msgType,
+			"processOp", &epCPInit.Msgs)
+	}
+	encryptPerf.AddEdge(stepFuncs[len(steps)-1].Label, hsSrcFunc.Label, "finished", "completeOp", &epCPInit.Msgs)
+
+	// once established, the session's data source is started
+	encryptPerf.AddEdge(hsSrcFunc.Label, hsMeasureFunc.Label, "established", "endOp", &epCPInit.Msgs)
+	encryptPerf.AddEdge(hsMeasureFunc.Label, srcFunc.Label, "initiate", "generateOp", &epCPInit.Msgs)
+
+	// the messages of the handshake are not encrypted
+	hsMsgLen, _ := pp.stack.WireLen(pp.pcktSize, CryptoSuite{}, false)
+
+	hsSrcCfg := pces.ClassCreateConnSrcCfg()
+	rtd := map[string]string{"generateOp": "hsinit", "completeOp": "established"}
+	tcd := map[string]string{"generateOp": "generateOp", "completeOp": "completeOp"}
+	hsSrcCfg.Populate(0.0, 1, "const", "hsinit", hsMsgLen, pp.pcktSize, rtd, tcd, false)
+	serialCfg, err := hsSrcCfg.Serialize(useYAML)
+	if err != nil {
+		panic(err)
+	}
+	epCPInit.AddCfg(encryptPerf, hsSrcFunc, serialCfg)
+
+	hsMeasureCfg := measure.CreateMeasureCfg(group.name + "-handshake")
+	hsMeasureCfg.AddRoute("startOp", "hsinit", stepFuncs[0].Label, "processOp")
+	hsMeasureCfg.AddRoute("endOp", "initiate", srcFunc.Label, "generateOp")
+	hsMeasureStr, merr := hsMeasureCfg.Serialize(useYAML)
+	if merr != nil {
+		panic(merr)
+	}
+	epCPInit.AddCfg(encryptPerf, hsMeasureFunc, hsMeasureStr)
+
+	cmpMap.AddMapping(hsSrcFunc.Label, group.src, false)
+	cmpMap.AddMapping(hsMeasureFunc.Label, group.src, false)
+
+	timingUses := []timingUse{
+		timingUse{code: "generateOp", dev: group.src},
+		timingUse{code: "completeOp", dev: group.src}}
+
+	for sdx, step := range steps {
+		dev := group.crypto
+		if step.atEUD {
+			dev = eudDevName
+		}
+
+		opCode := cryptoOpCode(step.op, step.suite.Alg, step.suite.KeyLength)
+		rtd := map[string]string{"processOp": step.msgType}
+		tcd := map[string]string{"processOp": opCode}
+		empty := make(map[string]string)
+
+		// the steps on a dedicated crypto device are accelerated, as its bulk crypto is
+		accl := pp.archSpec.acclCrypto(step.atEUD)
+		epCPInit.AddCfg(encryptPerf, stepFuncs[sdx], createProcessPcktCfg(rtd, tcd, empty, empty, accl))
+
+		cmpMap.AddMapping(stepFuncs[sdx].Label, dev, false)
+		timingUses = append(timingUses, timingUse{code: opCode, dev: dev})
+	}
+	return timingUses
+}
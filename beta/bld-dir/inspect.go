@@ -0,0 +1,126 @@
+package main
+
+// code to put an inline inspection device, a firewall, IDS, or DPI proxy, between the packet sources
+// and the EUDs.  The device is of type "inspect", with cores of its own, and sits between the router
+// bridging to the EUDs and the root of their access fabric.  Every packet crossing to an EUD, and every
+// response crossing back, is inspected by a processPckt Func mapped to it, timed by a code naming the
+// kind of inspection and the size of its rule set, e.g. 'inspect-firewall-1000'.  mrnes times a switch or
+// router by its model alone, so the timings of inspection, which depend on the packet length, are held
+// with the function timings rather than in a devExec table.  beta ships none, as it has no measurements
+// of an inspection product; they come from a table of the user's own under db/timing/funcExec
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/pces"
+	"strconv"
+)
+
+// inspectKinds lists the kinds of inspection an inspection device can do
+var inspectKinds map[string]bool = map[string]bool{"firewall": true, "ids": true, "dpi": true}
+
+// defaultInspectRules is the size of the rule set of an inspection device when -inspectRules is absent
+const defaultInspectRules = 1000
+
+// InspectSpec describes the inline inspection of the traffic between the packet sources and the EUDs.
+// Dev names the device of type "inspect" that inspects every packet crossing it, Kind is the kind
+// of inspection, "firewall", "ids", or "dpi", and Rules is the number of rules in its rule set
+type InspectSpec struct {
+	Dev   string `json:"dev" yaml:"dev"`
+	Kind  string `json:"kind" yaml:"kind"`
+	Rules int    `json:"rules" yaml:"rules"`
+}
+
+// OpCode gives the timing code of the inspection, e.g. 'inspect-firewall-1000'
+func (is *InspectSpec) OpCode() string {
+	return "inspect-" + is.Kind + "-" + strconv.Itoa(is.Rules)
+}
+
+// validateInspect checks that the inspection is of a known kind, with rules, done by a device of
+// type "inspect", and that every such device inspects, returning every problem found.  devs gives
+// the type of every device declared
+func (as *ArchSpec) validateInspect(devs map[string]string) []error {
+	errs := []error{}
+	inspector := ""
+	if as.Inspect != nil {
+		inspector = as.Inspect.Dev
+		if !inspectKinds[as.Inspect.Kind] {
+			errs = append(errs, fmt.Errorf("unrecognized inspection kind %s", as.Inspect.Kind))
+		}
+		if as.Inspect.Rules < 1 {
+			errs = append(errs, fmt.Errorf("inspection has %d rules", as.Inspect.Rules))
+		}
+		if devType, present := devs[inspector]; !present || devType != "inspect" {
+			errs = append(errs, fmt.Errorf("inspection assigned to %s, which is not a declared device of type inspect", inspector))
+		}
+	}
+	for devName, devType := range devs {
+		if devType == "inspect" && devName != inspector {
+			errs = append(errs, fmt.Errorf("device %s of type inspect does no inspection", devName))
+		}
+	}
+	return errs
+}
+
+// inspectFromFlags adds to the ArchSpec the inspection device -inspect describes, between the router
+// bridgeRtr and the access fabric of the EUDs.  Nothing is added when -inspect is absent
+func inspectFromFlags(cp *cmdline.CmdParser, as *ArchSpec, bridgeRtr string) {
+	if !cp.IsLoaded("inspect") {
+		return
+	}
+
+	errs := []error{}
+	params := make(map[string]string)
+	for _, flag := range []string{"inspectCPU", "inspectCPUBw"} {
+		if !cp.IsLoaded(flag) {
+			errs = append(errs, fmt.Errorf("command flag %s not included on the command line", flag))
+			continue
+		}
+		params[flag] = cp.GetVar(flag).(string)
+	}
+	cores := 0
+	if !cp.IsLoaded("inspectcores") {
+		errs = append(errs, fmt.Errorf("command flag inspectcores not included on the command line"))
+	} else {
+		cores = cp.GetVar("inspectcores").(int)
+	}
+	err := pces.ReportErrs(errs)
+	if err != nil {
+		panic(err)
+	}
+
+	rules := defaultInspectRules
+	if cp.IsLoaded("inspectRules") {
+		rules = cp.GetVar("inspectRules").(int)
+	}
+
+	as.Devices = append(as.Devices,
+		DevSpec{Name: "inspector", DevType: "inspect", Model: params["inspectCPU"], Cores: cores,
+			Bandwidth: params["inspectCPUBw"], Network: as.EUDs.Network})
+	as.Links = append(as.Links, LinkSpec{Src: bridgeRtr, Dst: "inspector", Network: as.EUDs.Network})
+	as.EUDs.Attach = "inspector"
+	as.Inspect = &InspectSpec{Dev: "inspector", Kind: cp.GetVar("inspect").(string), Rules: rules}
+}
+
+// checkTimings checks that the timing tables hold some timing for the code of the inspection, which
+// comes from a table of the user's own, refusing the inspection before anything is built when they
+// do not.  Whether they cover the inspector's CPU model is left to checkTimingCoverage
+func (is *InspectSpec) checkTimings(fel *pces.FuncExecList) {
+	if len(fel.Times[is.OpCode()]) == 0 {
+		panic(fmt.Errorf("inspection needs timing code %s, which no table under db/timing/funcExec holds", is.OpCode()))
+	}
+}
+
+// inspected is true when the traffic between the packet sources and the EUDs is inspected
+func (pp *ptnParams) inspected() bool {
+	return pp.archSpec.Inspect != nil
+}
+
+// createInspectCfg creates and serializes the cfg of an inspection Func, which charges the time of
+// the inspection to what it is given with method code 'inspectOp', and passes it on as type msgType
+func createInspectCfg(pp *ptnParams, msgType string) string {
+	rtd := map[string]string{"inspectOp": msgType}
+	tcd := map[string]string{"inspectOp": pp.archSpec.Inspect.OpCode()}
+	empty := make(map[string]string)
+	return createProcessPcktCfg(rtd, tcd, empty, empty, false)
+}
@@ -0,0 +1,126 @@
+package main
+
+// code to build the IPsec architecture, in which the application's traffic is protected not by the
+// packet sources and EUDs themselves, or by an SSL server, but by a pair of tunnel gateways.  The gateway
+// on the private side encrypts (ESP) what the packet sources send and decrypts the responses, and the
+// gateway at the point where the EUDs aggregate decrypts for the EUDs and encrypts their responses.
+// Between the gateways and the endpoints the traffic is plaintext.  The computational patterns are those
+// of the other architectures, with the crypto Funcs of the EUD side mapped to the EUD gateway
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/pces"
+)
+
+// archTypes lists the architectures the ArchSpec may describe
+var archTypes map[string]bool = map[string]bool{"SSL": true, "NoSSL": true, "IPsec": true}
+
+// validateGateway checks that the IPsec architecture names an EUD gateway, a declared device that is
+// not a switch or router, and that no other architecture names one, returning every problem found.
+// devs gives the type of every device declared
+func (as *ArchSpec) validateGateway(devs map[string]string) []error {
+	errs := []error{}
+	if as.ArchType != "IPsec" {
+		if len(as.Roles.EUDGw) > 0 {
+			errs = append(errs, fmt.Errorf("roles give an EUD gateway to archtype %s", as.ArchType))
+		}
+		return errs
+	}
+	devType, present := devs[as.Roles.EUDGw]
+	switch {
+	case len(as.Roles.EUDGw) == 0:
+		errs = append(errs, fmt.Errorf("archtype IPsec needs roles to give an EUD gateway"))
+	case !present:
+		errs = append(errs, fmt.Errorf("EUD gateway role assigned to undeclared device %s", as.Roles.EUDGw))
+	case devType == "switch" || devType == "router":
+		errs = append(errs, fmt.Errorf("EUD gateway role assigned to network device %s", as.Roles.EUDGw))
+	}
+	return errs
+}
+
+// eudCryptoDev names the device that decrypts what is sent to the idx-th EUD and encrypts its
+// responses, the EUD itself unless an EUD gateway does so for every EUD
+func (as *ArchSpec) eudCryptoDev(idx int) string {
+	if as.ArchType == "IPsec" {
+		return as.Roles.EUDGw
+	}
+	return EUDName(idx)
+}
+
+// acclCrypto is true when the crypto of the source side, or of the EUD side when atEUD is set,
+// is done by a dedicated crypto device, an SSL server or a tunnel gateway, whose crypto is accelerated
+func (as *ArchSpec) acclCrypto(atEUD bool) bool {
+	return as.ArchType == "IPsec" || (as.ArchType == "SSL" && !atEUD)
+}
+
+// gatewayFlags gives the string and int flags that describe the tunnel gateways
+func gatewayFlags() ([]string, []string) {
+	return []string{"gwCPU", "gwCPUBw", "pubRtr", "pubRtrBw"}, []string{"gwcores"}
+}
+
+// addGateways adds to the ArchSpec the tunnel gateways of the IPsec architecture, pvtGw between
+// pvtRtr and pubRtr and eudGw between the device the EUDs attach to and their access fabric, and the
+// router pubRtr joining them.  params and counts hold the flags describing the devices
+func addGateways(as *ArchSpec, params map[string]string, counts map[string]int) {
+	as.Devices = append(as.Devices,
+		DevSpec{Name: "pvtGw", DevType: "srvr", Model: params["gwCPU"], Cores: counts["gwcores"],
+			Bandwidth: params["gwCPUBw"], Network: "private"},
+		DevSpec{Name: "pubRtr", DevType: "router", Model: params["pubRtr"],
+			Bandwidth: params["pubRtrBw"], Network: "public"})
+	as.Links = append(as.Links,
+		LinkSpec{Src: "pvtRtr", Dst: "pvtGw", Network: "private"},
+		LinkSpec{Src: "pvtGw", Dst: "pubRtr", Network: "public"})
+}
+
+// addEUDGateway puts the EUD gateway eudGw between the device the EUDs attach to and their access
+// fabric, so that it is the last device every packet to an EUD crosses before the fabric
+func addEUDGateway(as *ArchSpec, params map[string]string, counts map[string]int) {
+	as.Devices = append(as.Devices,
+		DevSpec{Name: "eudGw", DevType: "srvr", Model: params["gwCPU"], Cores: counts["gwcores"],
+			Bandwidth: params["gwCPUBw"], Network: as.EUDs.Network})
+	as.Links = append(as.Links, LinkSpec{Src: as.EUDs.Attach, Dst: "eudGw", Network: as.EUDs.Network})
+	as.EUDs.Attach = "eudGw"
+	as.Roles.EUDGw = "eudGw"
+}
+
+// archTypeFromFlags gives the architecture -archtype names, or failing that the one -sslsrvr selects
+func archTypeFromFlags(cp *cmdline.CmdParser) string {
+	if cp.IsLoaded("archtype") {
+		archType := cp.GetVar("archtype").(string)
+		if !archTypes[archType] {
+			panic(fmt.Errorf("archtype %s is not SSL, NoSSL, or IPsec", archType))
+		}
+		return archType
+	}
+
+	// the application will build an architecture that comes with a dedicate
+	// SSL server, or another that doesn't.   The sslsrvr flag indicates which
+	if !cp.IsLoaded("sslsrvr") {
+		panic(fmt.Errorf("must specify sslsrvr or archtype when no architecture file is given"))
+	}
+	if cp.GetVar("sslsrvr").(bool) {
+		return "SSL"
+	}
+	return "NoSSL"
+}
+
+// checkGatewayCrypto checks that what the IPsec architecture is asked to model fits it.  ESP encrypts
+// each packet, so the crypto may not be applied to a message as a whole, and the tunnel between the
+// gateways is established once rather than by a handshake for every session
+func checkGatewayCrypto(as *ArchSpec, cryptoPerMsg bool, handshake *handshakeSpec) {
+	if as.ArchType != "IPsec" {
+		return
+	}
+	errs := []error{}
+	if cryptoPerMsg {
+		errs = append(errs, fmt.Errorf("cryptoUnit message does not apply to archtype IPsec, which encrypts each packet"))
+	}
+	if handshake != nil {
+		errs = append(errs, fmt.Errorf("handshake does not apply to archtype IPsec, whose tunnel is established before the run"))
+	}
+	err := pces.ReportErrs(errs)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+// code to vary the rate of the packet sources over virtual time.  A load profile is a schedule of
+// rate multipliers, each applying from the time its step starts, optionally repeated every period.
+// The sources generate packets at the profile's peak multiplier, and a profile Func thins them to
+// the multiplier of the moment, so that ramps, diurnal peaks, and flash crowds can be described
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/measure"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// ProfileStep is one step of a load profile: the time it starts at, e.g. "30s", and the
+// multiplier of the rate the -pcktMu, -burstMu, and -cycleMu distributions describe
+type ProfileStep struct {
+	At   string  `json:"at" yaml:"at"`
+	Rate float64 `json:"rate" yaml:"rate"`
+}
+
+// LoadProfile is a load profile, its steps listed in the order they start.  Period, when given, is
+// the time after which the profile repeats.  Linear moves the rate linearly from each step to the next,
+// rather than holding it until the next.  Times carry a unit, and are in milliseconds without one
+type LoadProfile struct {
+	Name   string        `json:"name" yaml:"name"`
+	Period string        `json:"period,omitempty" yaml:"period,omitempty"`
+	Linear bool          `json:"linear,omitempty" yaml:"linear,omitempty"`
+	Steps  []ProfileStep `json:"steps" yaml:"steps"`
+}
+
+// ReadLoadProfile deserializes a byte slice holding a representation of a LoadProfile struct.
+// If the input argument of dict (those bytes) is empty, the file whose name is given is read
+// to acquire them.  A deserialized representation is returned, or an error if one is generated
+// from a failed file read or deserialization
+func ReadLoadProfile(filename string, useYAML bool, dict []byte) (*LoadProfile, error) {
+	var err error
+
+	if len(dict) == 0 {
+		dict, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	example := LoadProfile{}
+
+	// select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(dict, &example)
+	} else {
+		err = json.Unmarshal(dict, &example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &example, nil
+}
+
+// schedule gives the start times of the profile's steps and the period in seconds, and the
+// rate multipliers of the steps, returning every problem found with them
+func (lp *LoadProfile) schedule() ([]float64, []float64, float64, error) {
+	errs := []error{}
+	if len(lp.Steps) == 0 {
+		errs = append(errs, fmt.Errorf("load profile %s has no steps", lp.Name))
+	}
+
+	times := make([]float64, len(lp.Steps))
+	rates := make([]float64, len(lp.Steps))
+	for sdx, step := range lp.Steps {
+		var terr error
+		times[sdx], terr = parseTime(step.At)
+		if terr != nil {
+			errs = append(errs, fmt.Errorf("step %d of load profile %s: %s", sdx, lp.Name, terr.Error()))
+		} else if times[sdx] < 0.0 || (sdx > 0 && times[sdx] <= times[sdx-1]) {
+			errs = append(errs, fmt.Errorf("step %d of load profile %s does not start after the step before it", sdx, lp.Name))
+		}
+		if step.Rate < 0.0 {
+			errs = append(errs, fmt.Errorf("step %d of load profile %s has a negative rate", sdx, lp.Name))
+		}
+		rates[sdx] = step.Rate
+	}
+	if len(lp.Steps) > 0 && lp.Peak() == 0.0 {
+		errs = append(errs, fmt.Errorf("load profile %s never has a positive rate", lp.Name))
+	}
+
+	period := 0.0
+	if len(lp.Period) > 0 {
+		var terr error
+		period, terr = parseTime(lp.Period)
+		if terr != nil {
+			errs = append(errs, fmt.Errorf("period of load profile %s: %s", lp.Name, terr.Error()))
+		} else if len(times) > 0 && period <= times[len(times)-1] {
+			errs = append(errs, fmt.Errorf("load profile %s has steps starting after its period", lp.Name))
+		}
+	}
+	return times, rates, period, pces.ReportErrs(errs)
+}
+
+// Peak gives the greatest rate multiplier of the profile, which the packet sources generate at
+func (lp *LoadProfile) Peak() float64 {
+	peak := 0.0
+	for _, step := range lp.Steps {
+		peak = max(peak, step.Rate)
+	}
+	return peak
+}
+
+// Validate checks the profile's steps and period, returning every problem found
+func (lp *LoadProfile) Validate() error {
+	_, _, _, err := lp.schedule()
+	return err
+}
+
+// profiled is true when the rate of the packet sources follows a load profile
+func (pp *ptnParams) profiled() bool {
+	return pp.profile != nil
+}
+
+// genDist gives the distribution of the times between what the sources generate that dist
+// describes, shortened by the profile's peak so that the sources generate at the peak rate
+func (pp *ptnParams) genDist(dist Dist) Dist {
+	if !pp.profiled() {
+		return dist
+	}
+	return dist.Scale(1.0 / pp.profile.Peak())
+}
+
+// createProfileCfg creates and serializes the cfg of a profile Func, which passes the packets it
+// keeps to the Func labeled tgtLabel with method code tgtMC, and returns those it thins to srcLabel,
+// with method code completeOp, as though they had completed
+func createProfileCfg(pp *ptnParams, tgtLabel, tgtMC, srcLabel string) string {
+	// the profile was validated when it was read
+	times, rates, period, _ := pp.profile.schedule()
+	cfg := measure.CreateProfileCfg(times, rates, pp.profile.Linear, period)
+	cfg.AddRoute("thinOp", "plaintext", tgtLabel, tgtMC)
+	cfg.AddThinned("finishtext", srcLabel, "completeOp")
+	cfg.Stream = pp.arrivalStream()
+
+	serialCfg, err := cfg.Serialize(useYAML)
+	if err != nil {
+		panic(err)
+	}
+	return serialCfg
+}
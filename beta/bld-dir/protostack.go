@@ -0,0 +1,157 @@
+package main
+
+// code to describe the protocol stack that carries the application's packets, and to compute from it
+// the number of bytes a packet puts on the wire.   A packet of the application is a record of the
+// stack's record layers (e.g. TLS), which add their headers, their trailers, a MAC, and padding to the
+// cipher's block size when the record is encrypted.  The record is then carried in as many segments as
+// the MTU calls for, each with the headers and trailers of the segment layers (e.g. TCP, IP, Ethernet)
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// ProtoLayer is one layer of the protocol stack.  Header and Trailer are the bytes the layer puts
+// before and after what it carries.  A record layer is applied once to each packet of the application,
+// and a segment layer to each segment the record is carried in.  Encrypted layers are present only on
+// encrypted records, to which they add MAC bytes, padding what they carry (with the trailer) to the cipher's
+// block size when Pad is set.  A link layer is a segment layer whose bytes the MTU does not count
+type ProtoLayer struct {
+	Name      string `json:"name" yaml:"name"`
+	Header    int    `json:"header" yaml:"header"`
+	Trailer   int    `json:"trailer,omitempty" yaml:"trailer,omitempty"`
+	MAC       int    `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Pad       bool   `json:"pad,omitempty" yaml:"pad,omitempty"`
+	Record    bool   `json:"record,omitempty" yaml:"record,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty" yaml:"encrypted,omitempty"`
+	Link      bool   `json:"link,omitempty" yaml:"link,omitempty"`
+}
+
+// ProtoStack is the protocol stack, its layers listed from the top down.  MTU bounds the bytes
+// of a segment, link layers aside, zero meaning records are never split.  BlockSizes gives the
+// block size in bytes of each crypto algorithm, an algorithm not listed not being padded
+type ProtoStack struct {
+	Name       string         `json:"name" yaml:"name"`
+	MTU        int            `json:"mtu" yaml:"mtu"`
+	Layers     []ProtoLayer   `json:"layers" yaml:"layers"`
+	BlockSizes map[string]int `json:"blocksizes" yaml:"blocksizes"`
+}
+
+// legacyStack is the stack used when none is given, the 36 bytes the beta model has always
+// added to a packet, in a single segment
+func legacyStack() *ProtoStack {
+	return &ProtoStack{Name: "legacy", Layers: []ProtoLayer{ProtoLayer{Name: "legacy", Header: 36}}}
+}
+
+// ReadProtoStack deserializes a byte slice holding a representation of a ProtoStack struct.
+// If the input argument of dict (those bytes) is empty, the file whose name is given is read
+// to acquire them.  A deserialized representation is returned, or an error if one is generated
+// from a failed file read or deserialization
+func ReadProtoStack(filename string, useYAML bool, dict []byte) (*ProtoStack, error) {
+	var err error
+
+	if len(dict) == 0 {
+		dict, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	example := ProtoStack{}
+
+	// select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(dict, &example)
+	} else {
+		err = json.Unmarshal(dict, &example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &example, nil
+}
+
+// segmentBytes gives the bytes the segment layers add to every segment, and those the MTU counts
+func (ps *ProtoStack) segmentBytes() (int, int) {
+	all, counted := 0, 0
+	for _, layer := range ps.Layers {
+		if layer.Record {
+			continue
+		}
+		all += layer.Header + layer.Trailer
+		if !layer.Link {
+			counted += layer.Header + layer.Trailer
+		}
+	}
+	return all, counted
+}
+
+// Validate checks that the stack's byte counts are not negative, that only record layers are
+// encrypted, and that a segment has room under the MTU for what it carries, returning every problem found
+func (ps *ProtoStack) Validate() error {
+	errs := []error{}
+	for _, layer := range ps.Layers {
+		if layer.Header < 0 || layer.Trailer < 0 || layer.MAC < 0 {
+			errs = append(errs, fmt.Errorf("layer %s of protocol stack %s has a negative byte count", layer.Name, ps.Name))
+		}
+		if layer.Encrypted && !layer.Record {
+			errs = append(errs, fmt.Errorf("layer %s of protocol stack %s is encrypted but not a record layer",
+				layer.Name, ps.Name))
+		}
+	}
+	for alg, blockSize := range ps.BlockSizes {
+		if blockSize < 1 {
+			errs = append(errs, fmt.Errorf("block size of %s in protocol stack %s is not positive", alg, ps.Name))
+		}
+	}
+	if _, counted := ps.segmentBytes(); ps.MTU > 0 && ps.MTU <= counted {
+		errs = append(errs, fmt.Errorf("MTU %d of protocol stack %s leaves no room under %d bytes of segment headers",
+			ps.MTU, ps.Name, counted))
+	}
+	return pces.ReportErrs(errs)
+}
+
+// RecordLen gives the bytes of the record carrying a packet of pcktLen bytes.  The record is encrypted
+// with the suite's algorithm when encrypted is set, and otherwise the encrypted layers are left out
+func (ps *ProtoStack) RecordLen(pcktLen int, suite CryptoSuite, encrypted bool) int {
+	recordLen := pcktLen
+
+	// each layer carries what the layers above it made of the packet
+	for _, layer := range ps.Layers {
+		if !layer.Record || (layer.Encrypted && !encrypted) {
+			continue
+		}
+		recordLen += layer.Trailer
+		if layer.Encrypted {
+			if blockSize := ps.BlockSizes[suite.Alg]; layer.Pad && blockSize > 0 {
+				recordLen = ceilDiv(recordLen, blockSize) * blockSize
+			}
+			recordLen += layer.MAC
+		}
+		recordLen += layer.Header
+	}
+	return recordLen
+}
+
+// WireLen gives the bytes a packet of pcktLen bytes puts on the wire, and the number of segments it is
+// carried in, the record being encrypted with the suite's algorithm when encrypted is set
+func (ps *ProtoStack) WireLen(pcktLen int, suite CryptoSuite, encrypted bool) (int, int) {
+	recordLen := ps.RecordLen(pcktLen, suite, encrypted)
+	all, counted := ps.segmentBytes()
+
+	segments := 1
+	if ps.MTU > 0 {
+		segments = ceilDiv(recordLen, ps.MTU-counted)
+	}
+	return recordLen + segments*all, segments
+}
+
+// frameLen gives the bytes a frame of -pcktlen bytes of a session using the suite puts on the wire
+func (pp *ptnParams) frameLen(suite CryptoSuite) int {
+	wireLen, _ := pp.stack.WireLen(pp.pcktSize, suite, true)
+	return wireLen
+}
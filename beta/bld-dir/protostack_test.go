@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+// tlsStack is the stack of bld-dir/protoStack.yaml, with the MTU given
+func tlsStack(mtu int) *ProtoStack {
+	return &ProtoStack{Name: "tls", MTU: mtu,
+		Layers: []ProtoLayer{
+			ProtoLayer{Name: "tls-cbc", Header: 16, Trailer: 33, Pad: true, Record: true, Encrypted: true},
+			ProtoLayer{Name: "tls", Header: 5, Record: true},
+			ProtoLayer{Name: "tcp", Header: 20},
+			ProtoLayer{Name: "ipv4", Header: 20},
+			ProtoLayer{Name: "ethernet", Header: 14, Trailer: 4, Link: true}},
+		BlockSizes: map[string]int{"aes": 16, "des": 8}}
+}
+
+// espStack is the stack of bld-dir/protoStackESP.yaml
+func espStack() *ProtoStack {
+	return &ProtoStack{Name: "esp", MTU: 1500,
+		Layers: []ProtoLayer{
+			ProtoLayer{Name: "esp", Header: 24, Trailer: 2, MAC: 12, Pad: true, Record: true, Encrypted: true},
+			ProtoLayer{Name: "ipv4-outer", Header: 20, Record: true, Encrypted: true},
+			ProtoLayer{Name: "tcp", Header: 20},
+			ProtoLayer{Name: "ipv4", Header: 20},
+			ProtoLayer{Name: "ethernet", Header: 14, Trailer: 4, Link: true}},
+		BlockSizes: map[string]int{"aes": 16, "des": 8}}
+}
+
+// TestRecordLen checks the bytes of records against those counted by hand, padded to each block size
+func TestRecordLen(t *testing.T) {
+	aes := CryptoSuite{Alg: "aes", KeyLength: "256"}
+	des := CryptoSuite{Alg: "des", KeyLength: "56"}
+	rc4 := CryptoSuite{Alg: "rc4", KeyLength: "128"}
+	tests := []struct {
+		name      string
+		stack     *ProtoStack
+		pcktLen   int
+		suite     CryptoSuite
+		encrypted bool
+		want      int
+	}{
+		// 100+33 padded to 144, then the tls-cbc and tls headers
+		{"aes padded", tlsStack(1500), 100, aes, true, 165},
+		{"des padded", tlsStack(1500), 100, des, true, 157},
+		{"unknown block size not padded", tlsStack(1500), 100, rc4, true, 154},
+		{"aes already a block multiple", tlsStack(1500), 111, aes, true, 165},
+		{"aes one byte over a block", tlsStack(1500), 112, aes, true, 181},
+		{"not encrypted", tlsStack(1500), 100, aes, false, 105},
+
+		// 100+2 padded to 112, the MAC, the esp header, and the outer ip header, which is not padded
+		{"esp aes", espStack(), 100, aes, true, 168},
+		{"esp des", espStack(), 100, des, true, 160},
+		{"esp not encrypted", espStack(), 100, aes, false, 100},
+		{"legacy", legacyStack(), 100, aes, true, 100},
+	}
+	for _, test := range tests {
+		got := test.stack.RecordLen(test.pcktLen, test.suite, test.encrypted)
+		if got != test.want {
+			t.Errorf("%s: RecordLen(%d) = %d, want %d", test.name, test.pcktLen, got, test.want)
+		}
+	}
+}
+
+// TestWireLen checks the bytes on the wire and the segments of packets either side of the MTU,
+// a segment of the TLS stack carrying 1460 bytes of the record and 58 of headers and trailers
+func TestWireLen(t *testing.T) {
+	aes := CryptoSuite{Alg: "aes", KeyLength: "256"}
+	tests := []struct {
+		name         string
+		stack        *ProtoStack
+		pcktLen      int
+		encrypted    bool
+		wantWire     int
+		wantSegments int
+	}{
+		{"record filling a segment", tlsStack(1500), 1455, false, 1518, 1},
+		{"record a byte over a segment", tlsStack(1500), 1456, false, 1577, 2},
+		{"encrypted record under a segment", tlsStack(1500), 1391, true, 1503, 1},
+		{"encrypted record padded over a segment", tlsStack(1500), 1400, true, 1577, 2},
+		{"record of three segments", tlsStack(1500), 4000, false, 4179, 3},
+		{"no MTU", tlsStack(0), 4000, false, 4063, 1},
+		{"legacy", legacyStack(), 1000, true, 1036, 1},
+	}
+	for _, test := range tests {
+		wire, segments := test.stack.WireLen(test.pcktLen, aes, test.encrypted)
+		if wire != test.wantWire || segments != test.wantSegments {
+			t.Errorf("%s: WireLen(%d) = %d bytes in %d segments, want %d in %d", test.name, test.pcktLen,
+				wire, segments, test.wantWire, test.wantSegments)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+// code to find the devices a round trip crosses, which the measure Funcs note so that a round
+// trip is counted lost only to outages on its route.  mrnes routes each message along a path of
+// the fewest hops, and where there are several (as across the spines of a leaf/spine fabric),
+// any of them may be taken, so the route of a round trip holds the devices of all of them
+
+import (
+	"github.com/iti/mrnes"
+	"sort"
+)
+
+// devLinks holds, for every device, the names of the devices cabled to it, as connectDevs connects them
+var devLinks map[string][]string = make(map[string][]string)
+
+// connectDevs cables two devices together, as mrnes.ConnectDevs does, and records the link
+func connectDevs(dev1, dev2 mrnes.TopoDev, cable bool, faces string) {
+	name1, name2 := dev1.DevName(), dev2.DevName()
+	devLinks[name1] = append(devLinks[name1], name2)
+	devLinks[name2] = append(devLinks[name2], name1)
+	mrnes.ConnectDevs(dev1, dev2, cable, faces)
+}
+
+// hops gives the number of links between the device named and every device reachable from it
+func hops(from string) map[string]int {
+	dist := map[string]int{from: 0}
+	queue := []string{from}
+	for len(queue) > 0 {
+		devName := queue[0]
+		queue = queue[1:]
+		for _, peer := range devLinks[devName] {
+			_, present := dist[peer]
+			if !present {
+				dist[peer] = dist[devName] + 1
+				queue = append(queue, peer)
+			}
+		}
+	}
+	return dist
+}
+
+// routeDevs adds to devs the devices on every path of the fewest hops between the devices named
+func routeDevs(from, to string, devs map[string]bool) {
+	fromHops, toHops := hops(from), hops(to)
+	length, present := fromHops[to]
+	if !present {
+		return
+	}
+	for devName, fromHop := range fromHops {
+		toHop, reached := toHops[devName]
+		if reached && fromHop+toHop == length {
+			devs[devName] = true
+		}
+	}
+}
+
+// roundTripDevs gives, in order of name, the devices a round trip crosses out to the EUD named idx and
+// back, from the packet source of its group through the crypto devices, and the inspection device when there is one
+func (pp *ptnParams) roundTripDevs(group *sessionGroup, idx int) []string {
+	hosts := []string{group.src, group.crypto}
+	if pp.inspected() {
+		hosts = append(hosts, pp.archSpec.Inspect.Dev)
+	}
+	hosts = append(hosts, pp.archSpec.eudCryptoDev(idx), EUDName(idx))
+
+	devs := map[string]bool{group.src: true}
+	for hdx := 1; hdx < len(hosts); hdx++ {
+		routeDevs(hosts[hdx-1], hosts[hdx], devs)
+	}
+	return sortedDevs(devs)
+}
+
+// sortedDevs gives the names of a set of devices in order
+func sortedDevs(devs map[string]bool) []string {
+	names := []string{}
+	for devName := range devs {
+		names = append(names, devName)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestRouteDevs checks the devices found on the paths of the fewest hops between two devices, across
+// a leaf/spine fabric whose two spines both lie on such paths, and a chain that is longer than them
+func TestRouteDevs(t *testing.T) {
+	saved := devLinks
+	defer func() { devLinks = saved }()
+
+	devLinks = make(map[string][]string)
+	link := func(name1, name2 string) {
+		devLinks[name1] = append(devLinks[name1], name2)
+		devLinks[name2] = append(devLinks[name2], name1)
+	}
+	link("pcktsrc", "pvtRtr")
+	link("pvtRtr", "spine-0")
+	link("pvtRtr", "spine-1")
+	link("spine-0", "leaf-0")
+	link("spine-1", "leaf-0")
+	link("leaf-0", "eud-0")
+	link("pvtRtr", "chain-0")
+	link("chain-0", "chain-1")
+	link("chain-1", "chain-2")
+	link("chain-2", "leaf-0")
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"pcktsrc", "eud-0", []string{"eud-0", "leaf-0", "pcktsrc", "pvtRtr", "spine-0", "spine-1"}},
+		{"eud-0", "pvtRtr", []string{"eud-0", "leaf-0", "pvtRtr", "spine-0", "spine-1"}},
+		{"chain-0", "chain-2", []string{"chain-0", "chain-1", "chain-2"}},
+		{"pvtRtr", "pvtRtr", []string{"pvtRtr"}},
+		{"pvtRtr", "elsewhere", []string{}},
+	}
+	for _, test := range tests {
+		devs := make(map[string]bool)
+		routeDevs(test.from, test.to, devs)
+		got := sortedDevs(devs)
+		if len(got) != len(test.want) {
+			t.Errorf("route from %s to %s crosses %v, want %v", test.from, test.to, got, test.want)
+			continue
+		}
+		for idx := range got {
+			if got[idx] != test.want[idx] {
+				t.Errorf("route from %s to %s crosses %v, want %v", test.from, test.to, got, test.want)
+				break
+			}
+		}
+	}
+}
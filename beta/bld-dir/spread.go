@@ -0,0 +1,331 @@
+package main
+
+// code to build the 'spread' shape of the beta application, in which every EUD has a session
+// of its own: a CmpPtn whose connSrc generates packets for that EUD alone, and which holds
+// the whole chain out to the EUD and back,
+//    src -> encryptOut -> decryptOut -> eudProcess -> encryptRtn -> decryptRtn -> src -> finish
+// The sessions of the EUDs in a session group share a measurement group, so the results of
+// the spread shape are reported under the same names as those of the cycle shape.  When a message
+// is carried in several frames, frame Funcs split it and put it back together around each crossing.
+// The closed shape (see closed.go) builds its sessions here too
+
+import (
+	"github.com/iti/measure"
+	"github.com/iti/pces"
+)
+
+// chainStep is a Func of the chain a session's messages take out to the EUD and back, with the
+// type of the message it is given and the method code it is given it with
+type chainStep struct {
+	fn         *pces.Func
+	msgType    string
+	methodCode string
+}
+
+// framedSteps returns the steps that carry a message from the Func encrypting it to the Func decrypting it.
+// When the message is carried in several frames, fragment splits it and reassemble puts it back together,
+// outside the crypto when it is applied to each frame, and inside it when it is applied to the message.
+// When the traffic is inspected, inspect inspects what crosses between the two sides, the packets
+// on the wire.  decryptType is the type of the message the decrypting Func passes on
+func framedSteps(pp *ptnParams, encrypt, decrypt, fragment, reassemble, inspect *pces.Func, decryptType string) []chainStep {
+	encryptStep := chainStep{fn: encrypt, msgType: "plaintext", methodCode: "encryptOp"}
+	decryptStep := chainStep{fn: decrypt, msgType: "encryptext", methodCode: "decryptOp"}
+
+	// the steps of each side, the crossing being between them
+	sent := []chainStep{encryptStep}
+	received := []chainStep{decryptStep}
+	if pp.framed() && pp.cryptoPerMsg {
+		sent = append(sent, chainStep{fn: fragment, msgType: "encryptext", methodCode: "fragmentOp"})
+		received = []chainStep{chainStep{fn: reassemble, msgType: "encryptext", methodCode: "reassembleOp"}, decryptStep}
+	} else if pp.framed() {
+		sent = []chainStep{chainStep{fn: fragment, msgType: "plaintext", methodCode: "fragmentOp"}, encryptStep}
+		received = append(received, chainStep{fn: reassemble, msgType: decryptType, methodCode: "reassembleOp"})
+	}
+	if pp.inspected() {
+		sent = append(sent, chainStep{fn: inspect, msgType: "encryptext", methodCode: "inspectOp"})
+	}
+	return append(sent, received...)
+}
+
+// buildSpreadPtns adds the CmpPtns of the spread shape, their cfgs, and their mappings to the dictionaries,
+// returning every function that is timed, with the device it is mapped to
+func buildSpreadPtns(pp *ptnParams, cpDict *pces.CompPatternDict, cpInitDict *pces.CPInitListDict,
+	cmpMapDict *pces.CompPatternMapDict) []timingUse {
+
+	timingUses := []timingUse{}
+
+	for _, session := range pp.sessions() {
+		idx := session.eud
+		group := pp.groups[pp.eudGroup[idx]]
+		suite := pp.suites[pp.eudSuite[idx]]
+		eudDevName := EUDName(idx)
+		eudCryptoDev := pp.archSpec.eudCryptoDev(idx)
+
+		// the session CmpPtn is of the group's type, named by the EUD's index (and in the closed shape its own)
+		encryptPerf := pces.CreateCompPattern(group.name)
+		encryptPerf.SetName(group.name + "-" + session.suffix)
+
+		srcFunc := pces.CreateFunc("connSrc", "src")
+		measureFunc := pces.CreateFunc("measure", "measure")
+		encryptOutFunc := pces.CreateFunc("processPckt", "encryptOut")
+		decryptOutFunc := pces.CreateFunc("processPckt", "decryptOut")
+		eudMarkFunc := pces.CreateFunc("measure", "eudMark")
+		processFunc := pces.CreateFunc("processPckt", "eudProcess")
+		encryptRtnFunc := pces.CreateFunc("processPckt", "encryptRtn")
+		decryptRtnFunc := pces.CreateFunc("processPckt", "decryptRtn")
+		finishFunc := pces.CreateFunc("finish", "finish")
+		fragmentOutFunc := pces.CreateFunc("frame", "fragmentOut")
+		reassembleOutFunc := pces.CreateFunc("frame", "reassembleOut")
+		fragmentRtnFunc := pces.CreateFunc("frame", "fragmentRtn")
+		reassembleRtnFunc := pces.CreateFunc("frame", "reassembleRtn")
+		profileFunc := pces.CreateFunc("profile", "profile")
+		thinkFunc := pces.CreateFunc("think", "think")
+		paceFunc := pces.CreateFunc("pace", "pace")
+		inspectOutFunc := pces.CreateFunc("processPckt", "inspectOut")
+		inspectRtnFunc := pces.CreateFunc("processPckt", "inspectRtn")
+
+		encryptPerf.AddFunc(srcFunc)
+		encryptPerf.AddFunc(measureFunc)
+		encryptPerf.AddFunc(encryptOutFunc)
+		encryptPerf.AddFunc(decryptOutFunc)
+		encryptPerf.AddFunc(eudMarkFunc)
+		encryptPerf.AddFunc(processFunc)
+		encryptPerf.AddFunc(encryptRtnFunc)
+		encryptPerf.AddFunc(decryptRtnFunc)
+		encryptPerf.AddFunc(finishFunc)
+		if pp.framed() {
+			encryptPerf.AddFunc(fragmentOutFunc)
+			encryptPerf.AddFunc(reassembleOutFunc)
+			encryptPerf.AddFunc(fragmentRtnFunc)
+			encryptPerf.AddFunc(reassembleRtnFunc)
+		}
+		if pp.profiled() {
+			encryptPerf.AddFunc(profileFunc)
+		}
+		if pp.closedLoop() {
+			encryptPerf.AddFunc(thinkFunc)
+		} else {
+			encryptPerf.AddFunc(paceFunc)
+		}
+		if pp.inspected() {
+			encryptPerf.AddFunc(inspectOutFunc)
+			encryptPerf.AddFunc(inspectRtnFunc)
+		}
+
+		epCPInit := pces.CreateCPInitList(encryptPerf.Name, group.name, true)
+		epCPInit.AddMsg(pces.CreateCompPatternMsg("initiate", true))
+		epCPInit.AddMsg(pces.CreateCompPatternMsg("plaintext", true))
+		epCPInit.AddMsg(pces.CreateCompPatternMsg("finishtext", true))
+		epCPInit.AddMsg(pces.CreateCompPatternMsg("encryptext", true))
+
+		// self-initiation message has type 'initiate', unless the session is established by a handshake
+		// that starts src when it completes.  Then the chain out and back.
+		// measure notes when each packet leaves and returns, and eudMark the EUD it visits.
+		// Outside the closed shape 'pace' first spaces out the packets src sends.
+		// Under a load profile, 'profile' then thins the packets to the rate of the moment,
+		// returning those it drops to src unmeasured
+		if pp.handshake == nil {
+			encryptPerf.AddEdge(srcFunc.Label, srcFunc.Label, "initiate", "generateOp", &epCPInit.Msgs)
+		}
+		chain := []chainStep{chainStep{fn: measureFunc, msgType: "plaintext", methodCode: "startOp"}}
+		chain = append(chain, framedSteps(pp, encryptOutFunc, decryptOutFunc, fragmentOutFunc, reassembleOutFunc, inspectOutFunc, "plaintext")...)
+		chain = append(chain, chainStep{fn: eudMarkFunc, msgType: "plaintext", methodCode: "markOp"},
+			chainStep{fn: processFunc, msgType: "plaintext", methodCode: "processOp"})
+		chain = append(chain, framedSteps(pp, encryptRtnFunc, decryptRtnFunc, fragmentRtnFunc, reassembleRtnFunc, inspectRtnFunc, "finishtext")...)
+		// in the closed shape measure notes the response's return before the think time
+		// that precedes the next request, which src sends as soon as the response reaches it
+		if pp.closedLoop() {
+			chain = append(chain, chainStep{fn: measureFunc, msgType: "finishtext", methodCode: "endOp"},
+				chainStep{fn: thinkFunc, msgType: "finishtext", methodCode: "thinkOp"})
+		}
+		chain = append(chain, chainStep{fn: srcFunc, msgType: "finishtext", methodCode: "completeOp"})
+
+		genFunc := srcFunc
+		if !pp.closedLoop() {
+			genFunc = paceFunc
+			encryptPerf.AddEdge(srcFunc.Label, paceFunc.Label, "plaintext", "paceOp", &epCPInit.Msgs)
+		}
+		if pp.profiled() {
+			encryptPerf.AddEdge(genFunc.Label, profileFunc.Label, "plaintext", "thinOp", &epCPInit.Msgs)
+			encryptPerf.AddEdge(profileFunc.Label, measureFunc.Label, "plaintext", "startOp", &epCPInit.Msgs)
+			encryptPerf.AddEdge(profileFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPInit.Msgs)
+		} else {
+			encryptPerf.AddEdge(genFunc.Label, measureFunc.Label, "plaintext", "startOp", &epCPInit.Msgs)
+		}
+		for cdx := 1; cdx < len(chain); cdx++ {
+			encryptPerf.AddEdge(chain[cdx-1].fn.Label, chain[cdx].fn.Label, chain[cdx].msgType,
+				chain[cdx].methodCode, &epCPInit.Msgs)
+		}
+		if pp.closedLoop() {
+			encryptPerf.AddEdge(srcFunc.Label, finishFunc.Label, "finishtext", "finishOp", &epCPInit.Msgs)
+		} else {
+			encryptPerf.AddEdge(srcFunc.Label, measureFunc.Label, "finishtext", "endOp", &epCPInit.Msgs)
+			encryptPerf.AddEdge(measureFunc.Label, finishFunc.Label, "finishtext", "finishOp", &epCPInit.Msgs)
+		}
+		// measure returns to src the packets lost to an outage, in the closed shape through think
+		if !pp.closedLoop() {
+			encryptPerf.AddEdge(measureFunc.Label, srcFunc.Label, "finishtext", "completeOp", &epCPInit.Msgs)
+		}
+
+		// connSrc sends pcktburst packets, one at a time, each as soon as the last returns, and pace
+		// passes each on once the time between packets has passed since it passed on the last.
+		// In the closed shape that time is the think time, already passed by the time the response returns
+		srcCfg := pces.ClassCreateConnSrcCfg()
+		rtd := map[string]string{"generateOp": "plaintext", "completeOp": "finishtext"}
+		tcd := map[string]string{"generateOp": "generateOp", "completeOp": "completeOp"}
+		msgLen, pcktLen := pp.srcLens(suite)
+		srcCfg.Populate(0.0, pp.requests(), "const", "plaintext", msgLen, pcktLen, rtd, tcd, false)
+
+		serialSrcCfg, err := srcCfg.Serialize(useYAML)
+		if err != nil {
+			panic(err)
+		}
+		epCPInit.AddCfg(encryptPerf, srcFunc, serialSrcCfg)
+
+		// the crypto is accelerated on an SSL server or a tunnel gateway
+		accl := pp.archSpec.acclCrypto(false)
+		eudAccl := pp.archSpec.acclCrypto(true)
+		encryptOutStr := createCryptoPcktCfg("encrypt", suite.Alg, suite.KeyLength, "encryptext", accl)
+		epCPInit.AddCfg(encryptPerf, encryptOutFunc, encryptOutStr)
+
+		decryptOutStr := createCryptoPcktCfg("decrypt", suite.Alg, suite.KeyLength, "plaintext", eudAccl)
+		epCPInit.AddCfg(encryptPerf, decryptOutFunc, decryptOutStr)
+
+		rtd = map[string]string{"processOp": "plaintext"}
+		tcd = map[string]string{"processOp": "processEUD"}
+		tlb := map[string]string{"processOp": "finish"}
+		tcp := map[string]string{"processOp": encryptPerf.Name}
+		processStr := createProcessPcktCfg(rtd, tcd, tcp, tlb, false)
+		epCPInit.AddCfg(encryptPerf, processFunc, processStr)
+
+		encryptRtnStr := createCryptoPcktCfg("encrypt", suite.Alg, suite.KeyLength, "encryptext", eudAccl)
+		epCPInit.AddCfg(encryptPerf, encryptRtnFunc, encryptRtnStr)
+
+		decryptRtnStr := createCryptoPcktCfg("decrypt", suite.Alg, suite.KeyLength, "finishtext", accl)
+		epCPInit.AddCfg(encryptPerf, decryptRtnFunc, decryptRtnStr)
+
+		finishStr := createFinishCfg()
+		epCPInit.AddCfg(encryptPerf, finishFunc, finishStr)
+
+		// the inspection Funcs pass the encrypted packets on, out to the EUD and back
+		if pp.inspected() {
+			epCPInit.AddCfg(encryptPerf, inspectOutFunc, createInspectCfg(pp, "encryptext"))
+			epCPInit.AddCfg(encryptPerf, inspectRtnFunc, createInspectCfg(pp, "encryptext"))
+		}
+
+		// each frame Func passes what it is given on to the next step of the chain
+		for cdx := 1; cdx < len(chain)-1; cdx++ {
+			if chain[cdx].fn.Class == "frame" {
+				next := chain[cdx+1]
+				frameStr := createFrameCfg(pp, suite, chain[cdx].methodCode, next.msgType, next.fn.Label, next.methodCode)
+				epCPInit.AddCfg(encryptPerf, chain[cdx].fn, frameStr)
+			}
+		}
+
+		// the samples of every session of the group are gathered in the group's measurement group,
+		// noting the devices of the session's route
+		measureCfg := measure.CreateMeasureCfg(group.name)
+		measureCfg.AddRoute("startOp", "plaintext", chain[1].fn.Label, chain[1].methodCode)
+		measureCfg.Path = pp.roundTripDevs(group, idx)
+		if pp.closedLoop() {
+			measureCfg.AddRoute("endOp", "finishtext", thinkFunc.Label, "thinkOp")
+			measureCfg.SetLost("finishtext", thinkFunc.Label, "thinkOp")
+			thinkStr := createThinkCfg(pp, srcFunc.Label, "completeOp")
+			epCPInit.AddCfg(encryptPerf, thinkFunc, thinkStr)
+		} else {
+			measureCfg.AddRoute("endOp", "finishtext", finishFunc.Label, "finishOp")
+			measureCfg.SetLost("finishtext", srcFunc.Label, "completeOp")
+		}
+		measureStr, merr := measureCfg.Serialize(useYAML)
+		if merr != nil {
+			panic(merr)
+		}
+		epCPInit.AddCfg(encryptPerf, measureFunc, measureStr)
+
+		// every gap of a session is a time between packets, its packets making up a single burst
+		if !pp.closedLoop() {
+			paceDists := []Dist{pp.pcktDist, pp.pcktDist, pp.pcktDist}
+			if pp.profiled() {
+				epCPInit.AddCfg(encryptPerf, paceFunc, createPaceCfg(pp, paceDists, 1, profileFunc.Label, "thinOp"))
+			} else {
+				epCPInit.AddCfg(encryptPerf, paceFunc, createPaceCfg(pp, paceDists, 1, measureFunc.Label, "startOp"))
+			}
+		}
+		if pp.profiled() {
+			profileStr := createProfileCfg(pp, measureFunc.Label, "startOp", srcFunc.Label)
+			epCPInit.AddCfg(encryptPerf, profileFunc, profileStr)
+		}
+
+		eudMarkCfg := measure.CreateMeasureCfg("")
+		eudMarkCfg.Class = pp.archSpec.EUDs.Class(idx).Name
+		eudMarkCfg.AddRoute("markOp", "plaintext", processFunc.Label, "processOp")
+		eudMarkStr, merr := eudMarkCfg.Serialize(useYAML)
+		if merr != nil {
+			panic(merr)
+		}
+		epCPInit.AddCfg(encryptPerf, eudMarkFunc, eudMarkStr)
+
+		// the source side of the session runs on the group's packet source and crypto device,
+		// the rest on the EUD, but for the EUD side's crypto when a gateway does it
+		cmpMap := pces.CreateCompPatternMap(encryptPerf.Name)
+		cmpMap.AddMapping(srcFunc.Label, group.src, false)
+		cmpMap.AddMapping(measureFunc.Label, group.src, false)
+		cmpMap.AddMapping(finishFunc.Label, group.src, false)
+		if pp.profiled() {
+			cmpMap.AddMapping(profileFunc.Label, group.src, false)
+		}
+		if pp.closedLoop() {
+			cmpMap.AddMapping(thinkFunc.Label, group.src, false)
+		} else {
+			cmpMap.AddMapping(paceFunc.Label, group.src, false)
+		}
+		cmpMap.AddMapping(encryptOutFunc.Label, group.crypto, false)
+		cmpMap.AddMapping(decryptRtnFunc.Label, group.crypto, false)
+		cmpMap.AddMapping(decryptOutFunc.Label, eudCryptoDev, false)
+		cmpMap.AddMapping(eudMarkFunc.Label, eudDevName, false)
+		cmpMap.AddMapping(processFunc.Label, eudDevName, false)
+		cmpMap.AddMapping(encryptRtnFunc.Label, eudCryptoDev, false)
+
+		// the source side's frames are split and put back together where the crypto is applied to them,
+		// on the packet source, or on the crypto device when it is applied to the message
+		if pp.framed() {
+			frameDev := group.src
+			if pp.cryptoPerMsg {
+				frameDev = group.crypto
+			}
+			cmpMap.AddMapping(fragmentOutFunc.Label, frameDev, false)
+			cmpMap.AddMapping(reassembleRtnFunc.Label, frameDev, false)
+			cmpMap.AddMapping(reassembleOutFunc.Label, eudDevName, false)
+			cmpMap.AddMapping(fragmentRtnFunc.Label, eudDevName, false)
+		}
+
+		// packets crossing in either direction are inspected on the inspection device
+		if pp.inspected() {
+			inspector := pp.archSpec.Inspect.Dev
+			cmpMap.AddMapping(inspectOutFunc.Label, inspector, false)
+			cmpMap.AddMapping(inspectRtnFunc.Label, inspector, false)
+			timingUses = append(timingUses,
+				timingUse{code: pp.archSpec.Inspect.OpCode(), dev: inspector})
+		}
+
+		if pp.handshake != nil {
+			timingUses = append(timingUses,
+				pp.handshake.addHandshake(pp, encryptPerf, epCPInit, srcFunc, group, eudDevName, cmpMap)...)
+		}
+
+		cpDict.AddCompPattern(encryptPerf)
+		cpInitDict.AddCPInitList(epCPInit)
+		cmpMapDict.AddCompPatternMap(cmpMap, false)
+
+		timingUses = append(timingUses,
+			timingUse{code: "generateOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: "completeOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: "finishOp", dev: group.src, pcktLen: pp.msgPcktLen()},
+			timingUse{code: cryptoOpCode("encrypt", suite.Alg, suite.KeyLength), dev: group.crypto, pcktLen: pp.cryptoPcktLen()},
+			timingUse{code: cryptoOpCode("decrypt", suite.Alg, suite.KeyLength), dev: group.crypto, pcktLen: pp.cryptoPcktLen()},
+			timingUse{code: cryptoOpCode("decrypt", suite.Alg, suite.KeyLength), dev: eudCryptoDev, pcktLen: pp.cryptoPcktLen()},
+			timingUse{code: "processEUD", dev: eudDevName, pcktLen: pp.msgPcktLen()},
+			timingUse{code: cryptoOpCode("encrypt", suite.Alg, suite.KeyLength), dev: eudCryptoDev, pcktLen: pp.cryptoPcktLen()})
+	}
+	return timingUses
+}
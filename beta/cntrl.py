@@ -176,6 +176,10 @@ def createBldArgs(cld, passthru):
     bldArgs.extend(passthruArgs)
 
     for key,value in cld['cmdDict'].items():
+        # the gui's menus mark an exponentially distributed inter-arrival time by writing
+        # its mean with an exponent, e.g. 1e-3, which bld.go is to be told explicitly
+        if key == 'pcktMu' and 'e' in str(value) and '(' not in str(value):
+            value = 'exp({})'.format(value)
         bldArgs.append('-{} {}\n'.format(key,value))
 
     with open('./bld-dir/args-bld','w') as wf:
@@ -482,7 +486,7 @@ def main():
 
     os.chdir('./bld-dir')
     if not os.path.isfile("./bld"):
-        cmd = "go build bld.go"
+        cmd = "go build -o bld ."
         os.system(cmd)
     os.chdir('../')
 
@@ -498,7 +502,7 @@ def main():
 
         if not os.path.isfile("./sim-dir/sim"):
             os.chdir('./sim-dir')
-            cmd = "go build sim.go"
+            cmd = "go build -o sim ."
             os.system(cmd)
             os.chdir('../')
 
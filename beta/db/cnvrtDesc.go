@@ -13,7 +13,7 @@ import (
 	"sort"
 )
 
-var isCryptoOp map[string]bool = map[string]bool{"encrypt":true, "decrypt":true, "hash":true, "sign":true} 
+var isCryptoOp map[string]bool = map[string]bool{"encrypt":true, "decrypt":true, "hash":true, "sign":true, "verify":true, "keyex":true} 
 
 // cmdlineParams defines the parameters recognized
 // on the command line
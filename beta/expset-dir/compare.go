@@ -0,0 +1,303 @@
+package main
+
+// compare.go runs a paired comparison of the SSL and NoSSL architectures.  For every experiment
+// of the experiment-set, and for each of a number of replications, the model is built and simulated
+// once with -sslsrvr true and once with -sslsrvr false, both simulations using the same rng seed
+// and the replication's substream.  The Funcs shaping the arrivals draw from arrival streams that
+// do not depend on the architecture (see beta/measure/stream.go), and these common random numbers
+// give the two runs of a pair the same packet arrivals, so that the difference between their RTT
+// statistics reflects the architecture rather than noise.  A pair whose runs are seen to draw different
+// arrivals for the same execution thread of a source is an error, as is a pair whose measurement groups
+// do not correspond, and so -sslsrvrs may not exceed 1.  Differences are computed pair by pair and
+// reported with a confidence interval.
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/measure"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// compareCfg holds the parameters of a comparison.  seedArgs holds the -rngseed passed to sim,
+// none when expset is given none, so that the pairs are seeded as any run of sim without one is
+type compareCfg struct {
+	replications int
+	seedArgs     []string
+	confidence   float64
+}
+
+// createCompareCfg is a constructor, drawing the parameters from the command line
+func createCompareCfg(cp *cmdline.CmdParser) *compareCfg {
+	cmp := &compareCfg{replications: 10, seedArgs: []string{}, confidence: 0.95}
+	if cp.IsLoaded("replications") {
+		cmp.replications = cp.GetVar("replications").(int)
+	}
+	if cp.IsLoaded("rngseed") {
+		cmp.seedArgs = append(cmp.seedArgs, fmt.Sprintf("-rngseed %d", cp.GetVar("rngseed").(int64)))
+	}
+	if cp.IsLoaded("confidence") {
+		cmp.confidence = cp.GetVar("confidence").(float64)
+	}
+	return cmp
+}
+
+// archNames lists the architectures compared, archSSL gives the value of -sslsrvr that builds each
+var archNames = []string{"SSL", "NoSSL"}
+var archSSL = map[string]string{"SSL": "true", "NoSSL": "false"}
+
+// simSamplesFile is the file in simDir where sim writes the samples of a run being compared
+const simSamplesFile = "expset-samples.json"
+
+// compareHeading heads the results file of a comparison.  The values are in milliseconds,
+// differences are the SSL value less the NoSSL value
+const compareHeading = "base parameter, attribute parameter, group, statistic, replications, confidence, SSL mean, NoSSL mean, mean difference, halfwidth, low, high\n"
+
+// pairKey strips the architecture from the name of a measurement group, e.g. encryptPerf-SSL -> encryptPerf,
+// so that the groups of the two runs of a pair can be matched
+func pairKey(group, arch string) string {
+	return strings.Replace(group, "-"+arch, "", 1)
+}
+
+// statValues lists the names and values (in milliseconds) of the statistics of a group that are compared
+func statValues(gs measure.GroupStats) ([]string, []float64) {
+	names := []string{"min", "q25", "mean", "median", "q75", "max"}
+	values := []float64{gs.Min, gs.Q25, gs.Mean, gs.Median, gs.Q75, gs.Max}
+	for _, pv := range gs.Percentiles {
+		names = append(names, "p"+strconv.FormatFloat(pv.Pct, 'f', -1, 64))
+		values = append(values, pv.Value)
+	}
+	for idx := range values {
+		values[idx] *= 1000.0
+	}
+	return names, values
+}
+
+// drawKey identifies an arrival draw by the CmpPtn whose execution thread started after it,
+// its name stripped of the architecture, and the draw's place among those of the Func that made it
+type drawKey struct {
+	src string
+	seq int
+}
+
+// synchronized reports how many of the execution threads sampled in the two runs of a pair started
+// after the same arrival draw, and how many of those saw draws of different values.  Threads are
+// matched by the CmpPtn that started them and the place of their draw, rather than by start time or
+// execution ID, as under load a source starting its next thread only once its last returns starts it
+// at a time that depends on the architecture, though the gap drawn for it does not.  A thread sampled in
+// one run only, having completed in it before the end of the run and not in the other, says nothing of the draws
+func synchronized(ssl, nossl []measure.Sample) (int, int) {
+	draws := make(map[drawKey]float64)
+	for _, sample := range ssl {
+		if sample.Seq >= 0 {
+			draws[drawKey{src: pairKey(sample.Src, "SSL"), seq: sample.Seq}] = sample.Draw
+		}
+	}
+	common := 0
+	mismatched := 0
+	for _, sample := range nossl {
+		if sample.Seq < 0 {
+			continue
+		}
+		draw, present := draws[drawKey{src: pairKey(sample.Src, "NoSSL"), seq: sample.Seq}]
+		if !present {
+			continue
+		}
+		common += 1
+		if math.Abs(draw-sample.Draw) > 1e-9*math.Max(math.Abs(draw), 1e-9) {
+			mismatched += 1
+		}
+	}
+	return common, mismatched
+}
+
+// flagValue gives the value the last of the lines of args giving flag sets it to, if any does
+func flagValue(args []string, flag string) (string, bool) {
+	value := ""
+	present := false
+	for _, arg := range args {
+		fields := strings.Fields(arg)
+		if len(fields) == 2 && fields[0] == "-"+flag {
+			value, present = fields[1], true
+		}
+	}
+	return value, present
+}
+
+// pairedStats accumulates, for one statistic of one measurement group, its value in each
+// architecture and their difference, replication by replication
+type pairedStats struct {
+	ssl   []float64
+	nossl []float64
+	diff  []float64
+}
+
+// run performs the comparison for every experiment of the experiment-set, writing the results to resultsFile
+func (cmp *compareCfg) run(es *ExpSet, bldDir, simDir string, argsBase, passthru, simArgs []string, resultsFile string) error {
+	if es.BaseParam == "sslsrvr" || es.AttrbParam == "sslsrvr" {
+		return fmt.Errorf("a comparison chooses the value of sslsrvr, so it cannot be an experiment-set parameter")
+	}
+	_, present := es.Fixed["archSpec"]
+	if present {
+		return fmt.Errorf("a comparison builds the architectures through -sslsrvr, and so cannot use an archSpec file")
+	}
+
+	// -archtype takes the place of -sslsrvr in bld.go, and so would build the same architecture twice
+	_, present = es.Fixed["archtype"]
+	if present || es.BaseParam == "archtype" || es.AttrbParam == "archtype" {
+		return fmt.Errorf("a comparison builds the architectures through -sslsrvr, and so cannot use archtype")
+	}
+	if cmp.replications < 2 {
+		return fmt.Errorf("a comparison needs at least 2 replications")
+	}
+
+	err := os.WriteFile(resultsFile, []byte(compareHeading), 0644)
+	if err != nil {
+		return err
+	}
+
+	numExp := len(es.BaseList) * len(es.AttrbList)
+	expCount := 1
+	for _, baseValue := range es.BaseList {
+		for _, attrbValue := range es.AttrbList {
+			fmt.Printf("comparing architectures in experiment %d of %d ...\n", expCount, numExp)
+			expCount += 1
+
+			// the SSL architecture names its session groups for the SSL server serving them when there are
+			// several, and the NoSSL architecture does not, so that the groups, and the arrival streams
+			// given out by group, would not correspond
+			sslSrvrs, present := flagValue(es.bldArgs(argsBase, passthru, baseValue, attrbValue), "sslsrvrs")
+			if present && sslSrvrs != "1" {
+				return fmt.Errorf("experiment %d sets sslsrvrs to %s, which divides the sessions of the SSL architecture "+
+					"into groups the NoSSL architecture does not have", expCount-1, sslSrvrs)
+			}
+
+			// paired holds the statistics of every group, indexed by group and statistic name
+			paired := make(map[string]map[string]*pairedStats)
+			statOrder := []string{}
+
+			for repl := 0; repl < cmp.replications; repl++ {
+				runs := make(map[string]*measure.Results)
+				samples := make(map[string][]measure.Sample)
+				for _, arch := range archNames {
+					bArgs := es.bldArgs(argsBase, passthru, baseValue, attrbValue)
+					bArgs = append(bArgs, "-sslsrvr "+archSSL[arch])
+
+					sArgs := append([]string{}, simArgs...)
+					sArgs = append(sArgs, "-replications 1")
+					sArgs = append(sArgs, cmp.seedArgs...)
+					sArgs = append(sArgs, fmt.Sprintf("-substream %d", repl), "-results "+simResultsFile, "-samples "+simSamplesFile)
+
+					if err = runBldSim(bldDir, simDir, bArgs, sArgs); err != nil {
+						return err
+					}
+
+					runs[arch], err = measure.ReadResults(filepath.Join(simDir, simResultsFile))
+					if err != nil {
+						return err
+					}
+					samples[arch], err = measure.ReadSamples(filepath.Join(simDir, simSamplesFile))
+					if err != nil {
+						return err
+					}
+				}
+
+				common, mismatched := synchronized(samples["SSL"], samples["NoSSL"])
+				if common == 0 {
+					return fmt.Errorf("the runs of replication %d of experiment %d share no execution thread started after an arrival draw",
+						repl, expCount-1)
+				}
+				if mismatched > 0 {
+					return fmt.Errorf("the runs of replication %d of experiment %d drew different arrivals for %d of %d execution threads (%.3g%%)",
+						repl, expCount-1, mismatched, common, 100.0*float64(mismatched)/float64(common))
+				}
+
+				// match the groups of the two runs and difference their statistics.  Every group must have its counterpart
+				nosslGroups := make(map[string]measure.GroupStats)
+				for _, gs := range runs["NoSSL"].Groups {
+					nosslGroups[pairKey(gs.Group, "NoSSL")] = gs
+				}
+				if len(nosslGroups) != len(runs["SSL"].Groups) {
+					return fmt.Errorf("in experiment %d the SSL architecture has %d measurement groups and the NoSSL architecture %d",
+						expCount-1, len(runs["SSL"].Groups), len(nosslGroups))
+				}
+				for _, gs := range runs["SSL"].Groups {
+					key := pairKey(gs.Group, "SSL")
+					ngs, present := nosslGroups[key]
+					if !present {
+						return fmt.Errorf("in experiment %d the SSL architecture's measurement group %s has no NoSSL counterpart",
+							expCount-1, gs.Group)
+					}
+					names, sslValues := statValues(gs)
+					_, nosslValues := statValues(ngs)
+					if len(statOrder) < len(names) {
+						statOrder = names
+					}
+
+					if _, present := paired[key]; !present {
+						paired[key] = make(map[string]*pairedStats)
+					}
+					for idx, name := range names {
+						if idx >= len(nosslValues) {
+							break
+						}
+						ps, present := paired[key][name]
+						if !present {
+							ps = new(pairedStats)
+							paired[key][name] = ps
+						}
+						ps.ssl = append(ps.ssl, sslValues[idx])
+						ps.nossl = append(ps.nossl, nosslValues[idx])
+						ps.diff = append(ps.diff, sslValues[idx]-nosslValues[idx])
+					}
+				}
+			}
+
+			err = cmp.appendComparison(resultsFile, baseValue, attrbValue, paired, statOrder)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendComparison writes the comparison of one experiment to the end of the results file
+func (cmp *compareCfg) appendComparison(filename, baseValue, attrbValue string,
+	paired map[string]map[string]*pairedStats, statOrder []string) error {
+
+	wf, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer wf.Close()
+
+	groups := []string{}
+	for group := range paired {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		for _, name := range statOrder {
+			ps, present := paired[group][name]
+			if !present {
+				continue
+			}
+			sslCI := measure.ComputeCI(ps.ssl, cmp.confidence)
+			nosslCI := measure.ComputeCI(ps.nossl, cmp.confidence)
+			diffCI := measure.ComputeCI(ps.diff, cmp.confidence)
+			_, err = fmt.Fprintf(wf, "%s,%s,%s,%s,%d,%g,%g,%g,%g,%g,%g,%g\n", baseValue, attrbValue, group, name,
+				len(ps.diff), cmp.confidence, sslCI.Mean, nosslCI.Mean, diffCI.Mean, diffCI.HalfWidth,
+				diffCI.Low, diffCI.High)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package main
+
+// expset runs an experiment-set: every combination of a 'base' parameter menu and an
+// 'attribute' parameter menu is built with bld and simulated with sim, and the RTT
+// statistics from each run are gathered into one results file.  It does what cntrl.py
+// does for the GUI, without the dependence on python and matplotlib.
+//
+// Each combination is built and simulated by a fresh bld and sim process.  mrnes and pces
+// keep the experiment they build in package-level state, so running the experiments
+// as separate processes is what keeps one experiment from seeing the remnants of another.
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/measure"
+	"gopkg.in/yaml.v3"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cmdlineParams defines the parameters recognized
+// on the command line
+func cmdlineParams() *cmdline.CmdParser {
+	cp := cmdline.NewCmdParser()
+	cp.AddFlag(cmdline.StringFlag, "expSet", true)     // file describing the experiment-set
+	cp.AddFlag(cmdline.StringFlag, "bldDir", true)     // directory holding bld.go and args-bld-base
+	cp.AddFlag(cmdline.StringFlag, "simDir", true)     // directory holding sim.go and args-sim
+	cp.AddFlag(cmdline.StringFlag, "results", false)   // file where results are written, overriding the experiment-set
+	cp.AddFlag(cmdline.BoolFlag, "rebuild", false)     // if set, rebuild bld and sim even if the executables exist
+	cp.AddFlag(cmdline.BoolFlag, "compare", false)     // if set, compare the SSL and NoSSL architectures in every experiment
+	cp.AddFlag(cmdline.IntFlag, "replications", false) // number of paired replications run by -compare, default 10
+	cp.AddFlag(cmdline.Int64Flag, "rngseed", false)    // rng seed of every paired replication, sim's default if absent
+	cp.AddFlag(cmdline.FloatFlag, "confidence", false) // confidence level of intervals reported by -compare, default 0.95
+	return cp
+}
+
+// ExpSet describes a set of experiments.  BaseParam and AttrbParam name bld command line flags,
+// BaseList and AttrbList give the values they take on.  Fixed holds flags given to bld in every
+// experiment, Passthru names a file whose lines are copied into every args-bld
+type ExpSet struct {
+	Name       string            `json:"name" yaml:"name"`
+	BaseParam  string            `json:"baseparam" yaml:"baseparam"`
+	BaseList   []string          `json:"baselist" yaml:"baselist"`
+	AttrbParam string            `json:"attrbparam" yaml:"attrbparam"`
+	AttrbList  []string          `json:"attrblist" yaml:"attrblist"`
+	Fixed      map[string]string `json:"fixed" yaml:"fixed"`
+	Passthru   string            `json:"passthru" yaml:"passthru"`
+	Results    string            `json:"results" yaml:"results"`
+}
+
+// ExpResult holds the RTT statistics (in milliseconds) reported for one measurement group of one experiment
+type ExpResult struct {
+	BaseValue  string
+	AttrbValue string
+	Group      string
+	Min        float64
+	Q25        float64
+	Mean       float64
+	Median     float64
+	Q75        float64
+	Max        float64
+	Samples    int
+}
+
+// csvHeading is the heading cntrl.py writes to its data file, kept the same so
+// that downstream consumers of that file can read ours, with the name of
+// the measurement group added at the end
+const csvHeading = "base parameter, attribute parameter, minimum, 25% percentile, mean, median, 75% percentile, maximum, samples, group\n"
+
+// simArgsFile and simResultsFile are the files in simDir holding the arguments
+// expset runs sim with, and the results it has sim write
+const simArgsFile = "args-sim-expset"
+const simResultsFile = "expset-results.json"
+
+// ReadExpSet reads the description of an experiment-set from file
+func ReadExpSet(filename string) (*ExpSet, error) {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	es := new(ExpSet)
+	err = yaml.Unmarshal(dict, es)
+	if err != nil {
+		return nil, err
+	}
+
+	// no base or attrb parameter is represented the way cntrl.py does, with a menu holding "None"
+	if len(es.BaseParam) == 0 || isNone(es.BaseParam) || len(es.BaseList) == 0 {
+		es.BaseParam = "None"
+		es.BaseList = []string{"None"}
+	}
+	if len(es.AttrbParam) == 0 || isNone(es.AttrbParam) || len(es.AttrbList) == 0 {
+		es.AttrbParam = "None"
+		es.AttrbList = []string{"None"}
+	}
+	return es, nil
+}
+
+func isNone(str string) bool {
+	return str == "None" || str == "none"
+}
+
+// bldArgs creates the lines of an args-bld file for one experiment.
+// The lines of args-bld-base come first, then those of the passthru file,
+// then the fixed flags, and finally the base and attrb parameter values
+func (es *ExpSet) bldArgs(argsBase []string, passthru []string, baseValue, attrbValue string) []string {
+	args := []string{}
+	args = append(args, argsBase...)
+	args = append(args, passthru...)
+
+	// order the fixed flags so that args-bld is the same from run to run
+	keys := []string{}
+	for key := range es.Fixed {
+		if key != es.BaseParam && key != es.AttrbParam {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("-%s %s", key, es.Fixed[key]))
+	}
+	if !isNone(baseValue) {
+		args = append(args, fmt.Sprintf("-%s %s", es.BaseParam, baseValue))
+	}
+	if !isNone(attrbValue) {
+		args = append(args, fmt.Sprintf("-%s %s", es.AttrbParam, attrbValue))
+	}
+	return args
+}
+
+// readLines returns the non-empty lines of a file
+func readLines(filename string) ([]string, error) {
+	rf, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rf.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(rf)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// buildExecutable compiles the program in dir into an executable named name,
+// unless one already exists and rebuild is not set
+func buildExecutable(dir, name string, rebuild bool) error {
+	_, err := os.Stat(filepath.Join(dir, name))
+	if err == nil && !rebuild {
+		return nil
+	}
+	cmd := exec.Command("go", "build", "-o", name, ".")
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runProgram runs an executable in dir with its arguments read from argsFile
+func runProgram(dir, name, argsFile string) error {
+	cmd := exec.Command("./"+name, "-is", argsFile)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s in %s failed: %w", name, dir, err)
+	}
+	return nil
+}
+
+// readSimResults reads the results file written by sim and returns the results
+// of each of its measurement groups, with the statistics converted to milliseconds
+func readSimResults(filename, baseValue, attrbValue string) ([]*ExpResult, error) {
+	rs, err := measure.ReadResults(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(rs.Groups) == 0 {
+		return nil, fmt.Errorf("simulator results in %s hold no measurement groups", filename)
+	}
+
+	ers := []*ExpResult{}
+	for _, gs := range rs.Groups {
+		er := &ExpResult{BaseValue: baseValue, AttrbValue: attrbValue, Group: gs.Group,
+			Min: 1000.0 * gs.Min, Q25: 1000.0 * gs.Q25, Mean: 1000.0 * gs.Mean, Median: 1000.0 * gs.Median,
+			Q75: 1000.0 * gs.Q75, Max: 1000.0 * gs.Max, Samples: gs.Samples}
+		ers = append(ers, er)
+	}
+	return ers, nil
+}
+
+// writeArgs writes the lines of an arguments file
+func writeArgs(filename string, args []string) error {
+	return os.WriteFile(filename, []byte(strings.Join(args, "\n")+"\n"), 0644)
+}
+
+// runBldSim builds a model by running bld with the arguments in bldArgs, and then simulates it
+// by running sim with the arguments in simArgs
+func runBldSim(bldDir, simDir string, bldArgs, simArgs []string) error {
+	err := writeArgs(filepath.Join(bldDir, "args-bld"), bldArgs)
+	if err != nil {
+		return err
+	}
+	if err = runProgram(bldDir, "bld", "args-bld"); err != nil {
+		return err
+	}
+
+	err = writeArgs(filepath.Join(simDir, simArgsFile), simArgs)
+	if err != nil {
+		return err
+	}
+	return runProgram(simDir, "sim", simArgsFile)
+}
+
+// appendResult writes one experiment's results to the end of the results file,
+// so that the results of completed experiments survive a later failure
+func appendResult(filename string, er *ExpResult) error {
+	wf, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer wf.Close()
+
+	_, err = fmt.Fprintf(wf, "%s,%s,%g,%g,%g,%g,%g,%g,%d,%s\n", er.BaseValue, er.AttrbValue,
+		er.Min, er.Q25, er.Mean, er.Median, er.Q75, er.Max, er.Samples, er.Group)
+	return err
+}
+
+// main gives the entry point
+func main() {
+	cp := cmdlineParams()
+	cp.Parse()
+
+	es, err := ReadExpSet(cp.GetVar("expSet").(string))
+	if err != nil {
+		panic(err)
+	}
+
+	bldDir := cp.GetVar("bldDir").(string)
+	simDir := cp.GetVar("simDir").(string)
+
+	resultsFile := es.Results
+	if cp.IsLoaded("results") {
+		resultsFile = cp.GetVar("results").(string)
+	}
+	if len(resultsFile) == 0 {
+		panic(fmt.Errorf("experiment-set names no results file"))
+	}
+
+	argsBase, err := readLines(filepath.Join(bldDir, "args-bld-base"))
+	if err != nil {
+		panic(err)
+	}
+
+	passthru := []string{}
+	if len(es.Passthru) > 0 {
+		passthru, err = readLines(es.Passthru)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	rebuild := cp.IsLoaded("rebuild") && cp.GetVar("rebuild").(bool)
+	if err = buildExecutable(bldDir, "bld", rebuild); err != nil {
+		panic(err)
+	}
+	if err = buildExecutable(simDir, "sim", rebuild); err != nil {
+		panic(err)
+	}
+
+	// sim is run with the arguments in its args-sim, plus a request to write its results
+	// where we can find them
+	simArgs, err := readLines(filepath.Join(simDir, "args-sim"))
+	if err != nil {
+		panic(err)
+	}
+
+	if cp.IsLoaded("compare") && cp.GetVar("compare").(bool) {
+		cmp := createCompareCfg(cp)
+		err = cmp.run(es, bldDir, simDir, argsBase, passthru, simArgs, resultsFile)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("Done")
+		return
+	}
+	simArgs = append(simArgs, "-results "+simResultsFile)
+
+	err = os.WriteFile(resultsFile, []byte(csvHeading), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	numExp := len(es.BaseList) * len(es.AttrbList)
+	expCount := 1
+	for _, baseValue := range es.BaseList {
+		for _, attrbValue := range es.AttrbList {
+			fmt.Printf("running experiment %d of %d ...\n", expCount, numExp)
+			expCount += 1
+
+			args := es.bldArgs(argsBase, passthru, baseValue, attrbValue)
+			if err = runBldSim(bldDir, simDir, args, simArgs); err != nil {
+				panic(err)
+			}
+
+			ers, err := readSimResults(filepath.Join(simDir, simResultsFile), baseValue, attrbValue)
+			if err != nil {
+				panic(err)
+			}
+
+			for _, er := range ers {
+				if err = appendResult(resultsFile, er); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+	fmt.Println("Done")
+}
@@ -0,0 +1,142 @@
+package measure
+
+// bckgrnd.go describes background flows, cross-traffic that shares the network with the
+// application without being modeled packet by packet.  bld.go checks a list of them against
+// the architecture and writes it with the model, and sim.go sets them up through mrnes before
+// the run starts, each flow being created at its start time and removed at its stop time
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// BckgrndFlowDesc describes a background flow from the endpoint device named by Src to
+// the one named by Dst, of Rate Mbps, in priority class Class.  The flow starts at
+// Start and stops at Stop (in seconds), a Stop of zero meaning it runs to the end
+type BckgrndFlowDesc struct {
+	Name  string  `json:"name" yaml:"name"`
+	Src   string  `json:"src" yaml:"src"`
+	Dst   string  `json:"dst" yaml:"dst"`
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Class int     `json:"class" yaml:"class"`
+	Start float64 `json:"start" yaml:"start"`
+	Stop  float64 `json:"stop,omitempty" yaml:"stop,omitempty"`
+}
+
+// BckgrndFlowList is a list of background flows
+type BckgrndFlowList struct {
+	Name  string            `json:"name" yaml:"name"`
+	Flows []BckgrndFlowDesc `json:"flows" yaml:"flows"`
+}
+
+// ReadBckgrndFlowList deserializes a byte slice holding a representation of a BckgrndFlowList struct.
+// If the input argument of dict (those bytes) is empty, the file whose name is given is read
+// to acquire them.  A deserialized representation is returned, or an error if one is generated
+// from a failed file read or deserialization
+func ReadBckgrndFlowList(filename string, useYAML bool, dict []byte) (*BckgrndFlowList, error) {
+	var err error
+
+	if len(dict) == 0 {
+		dict, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	example := BckgrndFlowList{}
+
+	// select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(dict, &example)
+	} else {
+		err = json.Unmarshal(dict, &example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &example, nil
+}
+
+// WriteToFile stores the BckgrndFlowList struct to the file whose name is given,
+// serialized to yaml when useYAML is set and to json otherwise
+func (bfl *BckgrndFlowList) WriteToFile(filename string, useYAML bool) error {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*bfl)
+	} else {
+		bytes, merr = json.MarshalIndent(*bfl, "", "\t")
+	}
+	if merr != nil {
+		return merr
+	}
+	return os.WriteFile(filename, bytes, 0644)
+}
+
+// Validate checks that every flow has distinct endpoints, a positive rate, a class that is not
+// negative, and a start time that is not negative and precedes its stop time, returning every problem found.
+// Whether the endpoints exist is for the caller to check
+func (bfl *BckgrndFlowList) Validate() error {
+	errs := []error{}
+	for fdx, bfd := range bfl.Flows {
+		name := bfd.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("%d", fdx)
+		}
+		if bfd.Src == bfd.Dst {
+			errs = append(errs, fmt.Errorf("background flow %s starts and ends at %s", name, bfd.Src))
+		}
+		if bfd.Rate <= 0.0 {
+			errs = append(errs, fmt.Errorf("background flow %s has rate %g Mbps", name, bfd.Rate))
+		}
+		if bfd.Class < 0 {
+			errs = append(errs, fmt.Errorf("background flow %s has negative class %d", name, bfd.Class))
+		}
+		if bfd.Start < 0.0 || (bfd.Stop > 0.0 && bfd.Stop <= bfd.Start) {
+			errs = append(errs, fmt.Errorf("background flow %s does not start at or after 0 and before it stops", name))
+		}
+	}
+	return pces.ReportErrs(errs)
+}
+
+// bckgrndFlowID is the ID of the last background flow created
+var bckgrndFlowID int = 0
+
+// ScheduleBckgrndFlows schedules the creation of every flow of the list at its start time,
+// and its removal at its stop time.  The network must have been built
+func (bfl *BckgrndFlowList) ScheduleBckgrndFlows(evtMgr *evtm.EventManager) {
+	for fdx := range bfl.Flows {
+		evtMgr.Schedule(&bfl.Flows[fdx], nil, startBckgrndFlow, vrtime.SecondsToTime(bfl.Flows[fdx].Start))
+	}
+}
+
+// startBckgrndFlow creates the background flow its context describes, and schedules its removal
+func startBckgrndFlow(evtMgr *evtm.EventManager, context any, data any) any {
+	bfd := context.(*BckgrndFlowDesc)
+	bckgrndFlowID += 1
+
+	bgf, ok := mrnes.CreateBckgrndFlow(evtMgr, bfd.Src, bfd.Dst, bfd.Rate, bckgrndFlowID, bckgrndFlowID,
+		bfd.Class, bfd.Name, bckgrndFlowEstablished)
+	if !ok {
+		panic(fmt.Errorf("background flow %s from %s to %s could not be created", bfd.Name, bfd.Src, bfd.Dst))
+	}
+
+	if bfd.Stop > 0.0 {
+		evtMgr.Schedule(bgf, nil, bgf.RmBckgrndFlow, vrtime.SecondsToTime(bfd.Stop-bfd.Start))
+	}
+	return nil
+}
+
+// bckgrndFlowEstablished is called back when the network has taken a background flow on,
+// and has nothing more to do
+func bckgrndFlowEstablished(evtMgr *evtm.EventManager, context any, data any) any {
+	return nil
+}
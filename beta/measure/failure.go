@@ -0,0 +1,223 @@
+package measure
+
+// failure.go describes a failure model, under which devices fail and are repaired at random times
+// through a run.  Every class of devices has a distribution of the time between its failures and one of
+// the time it takes to repair.  bld.go gives each class the devices of the architecture of the type it names
+// and writes the model with the rest, and sim.go draws the times of each device as the run goes on,
+// taking the device out of service, as outage.go describes, from when it fails until it is repaired
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"github.com/iti/rngstream"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+)
+
+// TimeDist describes a distribution of times, with the distribution names and parameters
+// (in seconds) of the think class
+type TimeDist struct {
+	Dist   string    `json:"dist" yaml:"dist"`
+	Params []float64 `json:"params" yaml:"params"`
+}
+
+// FailureClass describes a class of devices that fail alike: the devices of type DevType, one of
+// the device types of an architecture ("host", "srvr", "eud", "switch", or "router"), and those
+// listed in Devices.  MTBF describes the time from a device's repair (or the run's start) to its
+// next failure, and MTTR the time from its failure to its repair
+type FailureClass struct {
+	Name    string   `json:"name" yaml:"name"`
+	DevType string   `json:"devtype,omitempty" yaml:"devtype,omitempty"`
+	Devices []string `json:"devices,omitempty" yaml:"devices,omitempty"`
+	MTBF    TimeDist `json:"mtbf" yaml:"mtbf"`
+	MTTR    TimeDist `json:"mttr" yaml:"mttr"`
+}
+
+// FailureModel is a list of classes of devices that fail
+type FailureModel struct {
+	Name    string         `json:"name" yaml:"name"`
+	Classes []FailureClass `json:"classes" yaml:"classes"`
+}
+
+// ReadFailureModel deserializes a byte slice holding a representation of a FailureModel struct.
+// If the input argument of dict (those bytes) is empty, the file whose name is given is read
+// to acquire them.  A deserialized representation is returned, or an error if one is generated
+// from a failed file read or deserialization
+func ReadFailureModel(filename string, useYAML bool, dict []byte) (*FailureModel, error) {
+	var err error
+
+	if len(dict) == 0 {
+		dict, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	example := FailureModel{}
+
+	// select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(dict, &example)
+	} else {
+		err = json.Unmarshal(dict, &example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &example, nil
+}
+
+// WriteToFile stores the FailureModel struct to the file whose name is given,
+// serialized to yaml when useYAML is set and to json otherwise
+func (fm *FailureModel) WriteToFile(filename string, useYAML bool) error {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*fm)
+	} else {
+		bytes, merr = json.MarshalIndent(*fm, "", "\t")
+	}
+	if merr != nil {
+		return merr
+	}
+	return os.WriteFile(filename, bytes, 0644)
+}
+
+// Validate checks that every class names a device type or devices, that its distributions are known
+// and have the parameters they need, with some positive so that time moves on between a device's
+// failures and repairs, and that no device is in two classes, returning every problem found
+func (fm *FailureModel) Validate() error {
+	errs := []error{}
+	classOf := make(map[string]string)
+	for _, fc := range fm.Classes {
+		if len(fc.DevType) == 0 && len(fc.Devices) == 0 {
+			errs = append(errs, fmt.Errorf("failure class %s names no device type or devices", fc.Name))
+		}
+		for _, td := range []struct {
+			name string
+			dist TimeDist
+		}{{"mtbf", fc.MTBF}, {"mttr", fc.MTTR}} {
+			if err := checkDist(td.dist.Dist, td.dist.Params); err != nil {
+				errs = append(errs, fmt.Errorf("%s of failure class %s %s", td.name, fc.Name, err.Error()))
+				continue
+			}
+			positive := false
+			for _, param := range td.dist.Params {
+				positive = positive || param > 0.0
+			}
+			if !positive {
+				errs = append(errs, fmt.Errorf("%s of failure class %s has no positive parameter", td.name, fc.Name))
+			}
+		}
+		for _, devName := range fc.Devices {
+			if class, present := classOf[devName]; present {
+				errs = append(errs, fmt.Errorf("device %s is in failure classes %s and %s", devName, class, fc.Name))
+			}
+			classOf[devName] = fc.Name
+		}
+	}
+	return pces.ReportErrs(errs)
+}
+
+// Resolve adds to every class that names a device type the devices of that type, which devTypes
+// gives by type.  It checks that every device type is known and that every device a class lists
+// is one of those in devTypes, returning every problem found
+func (fm *FailureModel) Resolve(devTypes map[string][]string) error {
+	errs := []error{}
+	known := make(map[string]bool)
+	for _, devNames := range devTypes {
+		for _, devName := range devNames {
+			known[devName] = true
+		}
+	}
+
+	for cdx := range fm.Classes {
+		fc := &fm.Classes[cdx]
+		for _, devName := range fc.Devices {
+			if !known[devName] {
+				errs = append(errs, fmt.Errorf("failure class %s names %s, which is not a device of the architecture",
+					fc.Name, devName))
+			}
+		}
+		if len(fc.DevType) == 0 {
+			continue
+		}
+		devNames, present := devTypes[fc.DevType]
+		if !present {
+			errs = append(errs, fmt.Errorf("failure class %s names unknown device type %s", fc.Name, fc.DevType))
+			continue
+		}
+
+		listed := make(map[string]bool)
+		for _, devName := range fc.Devices {
+			listed[devName] = true
+		}
+		for _, devName := range devNames {
+			if !listed[devName] {
+				fc.Devices = append(fc.Devices, devName)
+			}
+		}
+		sort.Strings(fc.Devices)
+	}
+	return pces.ReportErrs(errs)
+}
+
+// CheckDevices checks that every device the classes list is in the network that has been built,
+// returning every problem found
+func (fm *FailureModel) CheckDevices() error {
+	errs := []error{}
+	for _, fc := range fm.Classes {
+		for _, devName := range fc.Devices {
+			if _, present := mrnes.TopoDevByName[devName]; !present {
+				errs = append(errs, fmt.Errorf("failure class %s names unknown device %s", fc.Name, devName))
+			}
+		}
+	}
+	return pces.ReportErrs(errs)
+}
+
+// failingDev is a device of a failure class, with the rng stream its times are drawn from
+type failingDev struct {
+	devName string
+	class   *FailureClass
+	rng     *rngstream.RngStream
+}
+
+// Schedule schedules the first failure of every device of every class, each device drawing its
+// times from its own rng stream.  The network must have been built
+func (fm *FailureModel) Schedule(evtMgr *evtm.EventManager) {
+	for cdx := range fm.Classes {
+		fc := &fm.Classes[cdx]
+		for _, devName := range fc.Devices {
+			fd := &failingDev{devName: devName, class: fc, rng: rngstream.New("failure-" + devName)}
+			evtMgr.Schedule(fd, nil, failDev, vrtime.SecondsToTime(sampleDist(fc.MTBF.Dist, fc.MTBF.Params, fd.rng)))
+		}
+	}
+}
+
+// failDev takes the device its context describes out of service, and schedules its repair
+func failDev(evtMgr *evtm.EventManager, context any, data any) any {
+	fd := context.(*failingDev)
+	takeDown(fd.devName, "failure", evtMgr.CurrentSeconds())
+
+	mttr := fd.class.MTTR
+	evtMgr.Schedule(fd, nil, repairDev, vrtime.SecondsToTime(sampleDist(mttr.Dist, mttr.Params, fd.rng)))
+	return nil
+}
+
+// repairDev puts the device its context describes back in service, and schedules its next failure
+func repairDev(evtMgr *evtm.EventManager, context any, data any) any {
+	fd := context.(*failingDev)
+	restore(evtMgr, fd.devName, "failure")
+
+	mtbf := fd.class.MTBF
+	evtMgr.Schedule(fd, nil, failDev, vrtime.SecondsToTime(sampleDist(mtbf.Dist, mtbf.Params, fd.rng)))
+	return nil
+}
@@ -0,0 +1,228 @@
+package measure
+
+// the frame class carries messages larger than a frame.  A frame Func receiving a message with
+// method code 'fragmentOp' splits it into the number of frames its cfg gives, each of which is passed
+// on separately, so that the frames cross the network independently.  A frame Func receiving a
+// message with method code 'reassembleOp' holds the frames of an execution thread until all of them
+// have arrived, and then passes on the message they carried.  Neither takes any time, and in
+// both cases the messages passed on go to the Func the cfg names for that method code.
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+)
+
+// like every Func class, get the frame class recognized within pces
+// when the file is loaded, by any application that imports it
+var frmcfgVar *FrameCfg = ClassCreateFrameCfg()
+var frmcfgLoaded bool = pces.RegisterFuncClass(frmcfgVar)
+
+// FrameCfg is the cfg put into the cpInit input file for a frame Func.  Frames is the
+// number of frames a message is carried in.  FramePcktLen and FrameMsgLen are the packet
+// and message lengths of each frame fragmentOp passes on, and PcktLen and MsgLen those of the
+// message reassembleOp passes on.  Route, TgtLabel, and TgtMC are indexed by method code, as
+// they are for the measure class
+type FrameCfg struct {
+	Frames       int               `yaml:"frames" json:"frames"`
+	FramePcktLen int               `yaml:"framepcktlen" json:"framepcktlen"`
+	FrameMsgLen  int               `yaml:"framemsglen" json:"framemsglen"`
+	PcktLen      int               `yaml:"pcktlen" json:"pcktlen"`
+	MsgLen       int               `yaml:"msglen" json:"msglen"`
+	Route        map[string]string `yaml:"route" json:"route"`
+	TgtLabel     map[string]string `yaml:"tgtlabel" json:"tgtlabel"`
+	TgtMC        map[string]string `yaml:"tgtmc" json:"tgtmc"`
+	Trace        bool              `yaml:"trace" json:"trace"`
+}
+
+// CreateFrameCfg is a constructor.  A message of pcktLen bytes, sent as msgLen bytes,
+// is carried in frames frames, each of framePcktLen bytes sent as frameMsgLen bytes
+func CreateFrameCfg(frames, framePcktLen, frameMsgLen, pcktLen, msgLen int) *FrameCfg {
+	frmcfg := new(FrameCfg)
+	frmcfg.Frames = frames
+	frmcfg.FramePcktLen = framePcktLen
+	frmcfg.FrameMsgLen = frameMsgLen
+	frmcfg.PcktLen = pcktLen
+	frmcfg.MsgLen = msgLen
+	frmcfg.Route = make(map[string]string)
+	frmcfg.TgtLabel = make(map[string]string)
+	frmcfg.TgtMC = make(map[string]string)
+	frmcfg.Trace = false
+	return frmcfg
+}
+
+// AddRoute says that what is passed on for a message arriving with method code methodCode
+// is of type msgType, and goes to the Func labeled tgtLabel, with method code tgtMC
+func (frmcfg *FrameCfg) AddRoute(methodCode, msgType, tgtLabel, tgtMC string) {
+	frmcfg.Route[methodCode] = msgType
+	frmcfg.TgtLabel[methodCode] = tgtLabel
+	frmcfg.TgtMC[methodCode] = tgtMC
+}
+
+// route is required for the router interface
+func (frmcfg *FrameCfg) route(methodCode string) (string, string, string) {
+	return frmcfg.Route[methodCode], frmcfg.TgtLabel[methodCode], frmcfg.TgtMC[methodCode]
+}
+
+// FrameState holds the number of frames of each execution thread that have arrived
+// at a reassembling Func, and not yet been passed on as a message
+type FrameState struct {
+	calls   int
+	arrived map[threadKey]int
+}
+
+// createFrameState is a constructor
+func createFrameState() *FrameState {
+	frms := new(FrameState)
+	frms.calls = 0
+	frms.arrived = make(map[threadKey]int)
+	return frms
+}
+
+// ClassCreateFrameCfg is a constructor called just to create an instance,
+// and put reference to frame and its methods in the pces data structures
+func ClassCreateFrameCfg() *FrameCfg {
+	frmcfg := CreateFrameCfg(1, 0, 0, 0, 0)
+
+	// put the event handling information into pces.ClassMethods
+	fmap := make(map[string]pces.RespMethod)
+	fmap["fragmentOp"] = pces.RespMethod{Start: frameFragment, End: pces.ExitFunc}
+	fmap["reassembleOp"] = pces.RespMethod{Start: frameReassemble, End: pces.ExitFunc}
+	pces.ClassMethods["frame"] = fmap
+
+	return frmcfg
+}
+
+// FuncClassName required for the FuncClassCfg interface
+func (frmcfg *FrameCfg) FuncClassName() string {
+	return "frame"
+}
+
+// CreateCfg required for the FuncClassCfg interface
+func (frmcfg *FrameCfg) CreateCfg(cfgStr string, useYAML bool) any {
+	frmcfgVarAny, err := frmcfg.Deserialize(cfgStr, useYAML)
+	if err != nil {
+		panic(fmt.Errorf("frame.InitCfg sees deserialization error"))
+	}
+	return frmcfgVarAny
+}
+
+// InitCfg required for the FuncClassCfg interface
+func (frmcfg *FrameCfg) InitCfg(cpfi *pces.CmpPtnFuncInst, cfgStr string, useYAML bool) {
+
+	// Deserialize the configuration for this Func
+	frmcfgVarAny := frmcfg.CreateCfg(cfgStr, useYAML)
+	frmcfgv := frmcfgVarAny.(*FrameCfg)
+	cpfi.Cfg = frmcfgv
+
+	cpfi.State = createFrameState()
+	cpfi.Trace = frmcfgv.Trace
+}
+
+// ValidateCfg checks that the Func carries a message in at least one frame, and has routes,
+// each for a method code the class responds to
+func (frmcfg *FrameCfg) ValidateCfg(cpfi *pces.CmpPtnFuncInst) error {
+	frmcfgv := cpfi.Cfg.(*FrameCfg)
+	if frmcfgv.Frames < 1 {
+		return fmt.Errorf("frame Func %s carries a message in %d frames", cpfi.Label, frmcfgv.Frames)
+	}
+	if len(frmcfgv.TgtLabel) == 0 {
+		return fmt.Errorf("frame Func %s has no routes", cpfi.Label)
+	}
+	for methodCode := range frmcfgv.TgtLabel {
+		_, present := pces.ClassMethods["frame"][methodCode]
+		if !present {
+			return fmt.Errorf("frame Func %s has route for unknown method code %s", cpfi.Label, methodCode)
+		}
+	}
+	return nil
+}
+
+// Serialize transforms the frame cfg into string form for
+// inclusion through a file
+func (frmcfg *FrameCfg) Serialize(useYAML bool) (string, error) {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*frmcfg)
+	} else {
+		bytes, merr = json.Marshal(*frmcfg)
+	}
+
+	if merr != nil {
+		return "", merr
+	}
+
+	return string(bytes[:]), nil
+}
+
+// Deserialize recovers a serialized representation of a frame cfg structure
+func (frmcfg *FrameCfg) Deserialize(fss string, useYAML bool) (any, error) {
+	// turn the string into a slice of bytes
+	var err error
+	fsb := []byte(fss)
+
+	example := CreateFrameCfg(1, 0, 0, 0, 0)
+
+	// Select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(fsb, example)
+	} else {
+		err = json.Unmarshal(fsb, example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return example, nil
+}
+
+// now include the functions whose executions are triggered by messages to the frame function,
+// passing through pces.EnterFunc.
+//
+// frameFragment passes the message on as the frames that carry it.  The frames are copies of
+// the message, belonging to its execution thread, and differ from it only in their lengths
+func frameFragment(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	frms := cpfi.State.(*FrameState)
+	frms.calls += 1
+	frmcfg := cpfi.Cfg.(*FrameCfg)
+
+	// update the message to reflect the next station, in the same CmpPtn, before it is copied
+	msgType, tgtLabel, tgtMC := frmcfg.route(methodCode)
+	pces.UpdateMsg(msg, cpfi.CPID, tgtLabel, msgType, tgtMC)
+
+	frames := make([]*pces.CmpPtnMsg, frmcfg.Frames)
+	for fdx := range frames {
+		frames[fdx] = new(pces.CmpPtnMsg)
+		*frames[fdx] = *msg
+		frames[fdx].PcktLen = frmcfg.FramePcktLen
+		frames[fdx].MsgLen = frmcfg.FrameMsgLen
+	}
+
+	// put the frames where pces.ExitFunc will be looking for them
+	cpfi.AddResponse(msg.ExecID, frames)
+	evtMgr.Schedule(cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(0.0))
+}
+
+// frameReassemble counts the frames of the execution thread that have arrived, and when the
+// last arrives passes the message they carried on
+func frameReassemble(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	frms := cpfi.State.(*FrameState)
+	frms.calls += 1
+	frmcfg := cpfi.Cfg.(*FrameCfg)
+
+	key := threadKey{srtCPID: msg.CmpHdr.SrtCPID, execID: msg.ExecID}
+	frms.arrived[key] += 1
+	if frms.arrived[key] < frmcfg.Frames {
+		return
+	}
+	delete(frms.arrived, key)
+
+	msg.PcktLen = frmcfg.PcktLen
+	msg.MsgLen = frmcfg.MsgLen
+	passOn(evtMgr, cpfi, methodCode, msg)
+}
@@ -0,0 +1,18 @@
+module github.com/iti/measure
+
+go 1.22.7
+
+require (
+	github.com/iti/evt/evtm v0.1.4
+	github.com/iti/evt/vrtime v0.1.5
+	github.com/iti/mrnes v0.0.13
+	github.com/iti/pces v0.0.11
+	github.com/iti/rngstream v0.2.2
+	gonum.org/v1/gonum v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/iti/evt/evtq v0.1.4 // indirect
+	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
+)
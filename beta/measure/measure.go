@@ -0,0 +1,257 @@
+package measure
+
+// the measure class takes round-trip time (RTT) measurements of the execution threads
+// of a computational pattern.  A measure Func sits on the path an execution thread takes
+// as it leaves the Func that starts it, and on the path the thread takes when it returns.
+// A message arriving with method code 'startOp' has its start time noted, a message arriving
+// with method code 'endOp' has its RTT computed and saved as a sample of the measurement group
+// named in the Func's cfg.   A measure Func placed in a destination CmpPtn marks messages
+// arriving with method code 'markOp', so that the samples record the CmpPtn the thread visited,
+// and the class of that CmpPtn named in the marking Func's cfg.
+// In every case the message is passed on, without delay, to the Func the cfg names for that method code.
+// The devices a thread's round trip crosses may be given in the cfg of the Func starting it, and narrowed in
+// that of the Func marking it, so that a thread stalled at a device that goes down is returned to its source
+// when the device is restored, and counted lost (see outage.go)
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+)
+
+// like every Func class, get the measure class recognized within pces
+// when the file is loaded, by any application that imports it
+var msrcfgVar *MeasureCfg = ClassCreateMeasureCfg()
+var msrcfgLoaded bool = pces.RegisterFuncClass(msrcfgVar)
+
+// like every Func class, define a Cfg struct that will be put into the cpInit input
+// file for the measure Func.  Route, TgtLabel, and TgtMC are indexed by the method code
+// of the arriving message, and give the type of the message passed on, the label of the Func
+// in the same CmpPtn it is passed to, and the method code it is passed with.  Class is used by a
+// Func that marks messages, naming the class (e.g. the kind of device) of its CmpPtn.
+// Path lists the devices the round trip of a thread the Func starts or marks crosses, and LostType,
+// LostLabel, and LostMC say where a Func starting threads returns one stalled at a device that was down
+type MeasureCfg struct {
+	Group     string            `yaml:"group" json:"group"`
+	Class     string            `yaml:"class,omitempty" json:"class,omitempty"`
+	Route     map[string]string `yaml:"route" json:"route"`
+	TgtLabel  map[string]string `yaml:"tgtlabel" json:"tgtlabel"`
+	TgtMC     map[string]string `yaml:"tgtmc" json:"tgtmc"`
+	Path      []string          `yaml:"path,omitempty" json:"path,omitempty"`
+	LostType  string            `yaml:"losttype,omitempty" json:"losttype,omitempty"`
+	LostLabel string            `yaml:"lostlabel,omitempty" json:"lostlabel,omitempty"`
+	LostMC    string            `yaml:"lostmc,omitempty" json:"lostmc,omitempty"`
+	Trace     bool              `yaml:"trace" json:"trace"`
+}
+
+// CreateMeasureCfg is a constructor.  group names the measurement group
+// whose samples the Func gathers; when empty the name of the Func's CmpPtn is used
+func CreateMeasureCfg(group string) *MeasureCfg {
+	msrcfg := new(MeasureCfg)
+	msrcfg.Group = group
+	msrcfg.Route = make(map[string]string)
+	msrcfg.TgtLabel = make(map[string]string)
+	msrcfg.TgtMC = make(map[string]string)
+	msrcfg.Path = []string{}
+	msrcfg.Trace = false
+	return msrcfg
+}
+
+// SetLost says that a thread the Func started, stalled at a device that was down, is returned
+// when the device is restored as a message of type msgType to the Func labeled tgtLabel, with method code tgtMC
+func (msrcfg *MeasureCfg) SetLost(msgType, tgtLabel, tgtMC string) {
+	msrcfg.LostType = msgType
+	msrcfg.LostLabel = tgtLabel
+	msrcfg.LostMC = tgtMC
+}
+
+// AddRoute says that a message arriving with method code methodCode is passed on as
+// a message of type msgType to the Func labeled tgtLabel, with method code tgtMC
+func (msrcfg *MeasureCfg) AddRoute(methodCode, msgType, tgtLabel, tgtMC string) {
+	msrcfg.Route[methodCode] = msgType
+	msrcfg.TgtLabel[methodCode] = tgtLabel
+	msrcfg.TgtMC[methodCode] = tgtMC
+}
+
+// MeasureState holds the name of the measurement group the Func gathers samples for,
+// and the class of the CmpPtn it marks messages in
+type MeasureState struct {
+	calls int
+	group string
+	class string
+}
+
+// createMeasureState is a constructor
+func createMeasureState(group, class string) *MeasureState {
+	msrs := new(MeasureState)
+	msrs.calls = 0
+	msrs.group = group
+	msrs.class = class
+	return msrs
+}
+
+// ClassCreateMeasureCfg is a constructor called just to create an instance,
+// and put reference to measure and its methods in the pces data structures
+func ClassCreateMeasureCfg() *MeasureCfg {
+	msrcfg := CreateMeasureCfg("")
+
+	// put the event handling information into pces.ClassMethods
+	fmap := make(map[string]pces.RespMethod)
+	fmap["startOp"] = pces.RespMethod{Start: measureStart, End: pces.ExitFunc}
+	fmap["endOp"] = pces.RespMethod{Start: measureEnd, End: pces.ExitFunc}
+	fmap["markOp"] = pces.RespMethod{Start: measureMark, End: pces.ExitFunc}
+	pces.ClassMethods["measure"] = fmap
+
+	return msrcfg
+}
+
+// FuncClassName required for the FuncClassCfg interface
+func (msrcfg *MeasureCfg) FuncClassName() string {
+	return "measure"
+}
+
+// CreateCfg required for the FuncClassCfg interface
+func (msrcfg *MeasureCfg) CreateCfg(cfgStr string, useYAML bool) any {
+	msrcfgVarAny, err := msrcfg.Deserialize(cfgStr, useYAML)
+	if err != nil {
+		panic(fmt.Errorf("measure.InitCfg sees deserialization error"))
+	}
+	return msrcfgVarAny
+}
+
+// InitCfg required for the FuncClassCfg interface
+func (msrcfg *MeasureCfg) InitCfg(cpfi *pces.CmpPtnFuncInst, cfgStr string, useYAML bool) {
+
+	// Deserialize the configuration for this Func
+	msrcfgVarAny := msrcfg.CreateCfg(cfgStr, useYAML)
+	msrcfgv := msrcfgVarAny.(*MeasureCfg)
+	cpfi.Cfg = msrcfgv
+
+	group := msrcfgv.Group
+	if len(group) == 0 {
+		group = pces.CmpPtnInstByID[cpfi.CPID].Name
+	}
+
+	cpfi.State = createMeasureState(group, msrcfgv.Class)
+	cpfi.Trace = msrcfgv.Trace
+}
+
+// ValidateCfg checks that the Func has routes, each for a method code the class responds to,
+// and that a Func returning lost threads says how
+func (msrcfg *MeasureCfg) ValidateCfg(cpfi *pces.CmpPtnFuncInst) error {
+	msrcfgv := cpfi.Cfg.(*MeasureCfg)
+	if len(msrcfgv.TgtLabel) == 0 {
+		return fmt.Errorf("measure Func %s has no routes", cpfi.Label)
+	}
+	for methodCode := range msrcfgv.TgtLabel {
+		_, present := pces.ClassMethods["measure"][methodCode]
+		if !present {
+			return fmt.Errorf("measure Func %s has route for unknown method code %s", cpfi.Label, methodCode)
+		}
+	}
+	if len(msrcfgv.LostLabel) > 0 && (len(msrcfgv.LostType) == 0 || len(msrcfgv.LostMC) == 0) {
+		return fmt.Errorf("measure Func %s returns lost threads without a message type and method code", cpfi.Label)
+	}
+	return nil
+}
+
+// Serialize transforms the measure cfg into string form for
+// inclusion through a file
+func (msrcfg *MeasureCfg) Serialize(useYAML bool) (string, error) {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*msrcfg)
+	} else {
+		bytes, merr = json.Marshal(*msrcfg)
+	}
+
+	if merr != nil {
+		return "", merr
+	}
+
+	return string(bytes[:]), nil
+}
+
+// Deserialize recovers a serialized representation of a measure cfg structure
+func (msrcfg *MeasureCfg) Deserialize(fss string, useYAML bool) (any, error) {
+	// turn the string into a slice of bytes
+	var err error
+	fsb := []byte(fss)
+
+	example := CreateMeasureCfg("")
+
+	// Select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(fsb, example)
+	} else {
+		err = json.Unmarshal(fsb, example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return example, nil
+}
+
+// now include the functions whose executions are triggered by messages to the measure function,
+// passing through pces.EnterFunc.
+//
+// measureStart notes the time at which an execution thread starts, with the Func and the message that
+// started it, and passes the message on
+func measureStart(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	msrs := cpfi.State.(*MeasureState)
+	msrs.calls += 1
+
+	startThread(msrs.group, msg.CmpHdr.SrtCPID, msg.ExecID, evtMgr.CurrentSeconds(), cpfi, msg)
+	passOn(evtMgr, cpfi, methodCode, msg)
+}
+
+// measureEnd notes the time at which an execution thread returns, saves its RTT, and passes the message on
+func measureEnd(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	msrs := cpfi.State.(*MeasureState)
+	msrs.calls += 1
+
+	endThread(msrs.group, msg.CmpHdr.SrtCPID, msg.ExecID, evtMgr.CurrentSeconds())
+	passOn(evtMgr, cpfi, methodCode, msg)
+}
+
+// measureMark notes the CmpPtn an execution thread visits, its class, and the devices of the thread's
+// round trip when the Func knows them, and passes the message on
+func measureMark(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	msrs := cpfi.State.(*MeasureState)
+	msrs.calls += 1
+
+	markThread(msg.CmpHdr.SrtCPID, msg.ExecID, pces.CmpPtnInstByID[cpfi.CPID].Name, msrs.class,
+		cpfi.Cfg.(*MeasureCfg).Path)
+	passOn(evtMgr, cpfi, methodCode, msg)
+}
+
+// router is satisfied by the cfgs of the classes in this package, which name for each method
+// code the type of the message passed on, the Func it goes to, and the method code it goes with
+type router interface {
+	route(methodCode string) (string, string, string)
+}
+
+// route is required for the router interface
+func (msrcfg *MeasureCfg) route(methodCode string) (string, string, string) {
+	return msrcfg.Route[methodCode], msrcfg.TgtLabel[methodCode], msrcfg.TgtMC[methodCode]
+}
+
+// passOn sends the message to the Func the cfg names for the method code it arrived with
+func passOn(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	msgType, tgtLabel, tgtMC := cpfi.Cfg.(router).route(methodCode)
+
+	// update the message to reflect the next station, in the same CmpPtn
+	pces.UpdateMsg(msg, cpfi.CPID, tgtLabel, msgType, tgtMC)
+
+	// put the message where pces.ExitFunc will be looking for it
+	cpfi.AddResponse(msg.ExecID, []*pces.CmpPtnMsg{msg})
+
+	// just schedule ExitFunc
+	evtMgr.Schedule(cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(0.0))
+}
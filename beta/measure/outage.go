@@ -0,0 +1,331 @@
+package measure
+
+// outage.go takes devices out of service and puts them back, for the scenario timeline and the
+// failure model alike, and reports what the outages cost: how long the devices and the service were
+// up, the round trips lost, and the RTTs of packets that met the network degraded and of those that did not.
+// mrnes has no notion of a device failing, so a device is taken down by making its interfaces so slow
+// that nothing crosses them within any run.  A packet reaching a device that is down stalls there, and as the
+// routes are fixed when the network is built, no packet is routed around one.  A stalled packet never moves
+// again, so when the device is restored every execution thread in flight on a route through it is counted
+// lost and returned to its source by the measure Func that started it, as though it had completed, so that
+// a session waiting on it goes on.  A thread whose route the measure Funcs do not know cannot be returned,
+// and is counted lost when it has not returned by the end of the run and some device was down meanwhile
+
+import (
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"sort"
+)
+
+// downBndwdth is the bandwidth (in Mbps) given to the interfaces of a device that is down
+const downBndwdth float64 = 1e-12
+
+// Outage records a device being out of service from Start until End (in seconds), for
+// Cause, "timeline" or "failure".  End is negative while the device is still down
+type Outage struct {
+	Dev   string
+	Cause string
+	Start float64
+	End   float64
+}
+
+// Outages holds every outage, in the order they started
+var Outages []Outage = []Outage{}
+
+// downDev holds what is needed to restore a device that is down: the outages it is down for
+// (a device can fail while a timeline has it down), and the bandwidths of its interfaces
+type downDev struct {
+	outages  []int
+	bndwdths []float64
+}
+
+// downDevs holds every device that is down, by name
+var downDevs map[string]*downDev = make(map[string]*downDev)
+
+// isDown is true when the device named is out of service
+func isDown(devName string) bool {
+	_, present := downDevs[devName]
+	return present
+}
+
+// takeDown takes the device named out of service at time now, for cause.  The bandwidths of its
+// interfaces are saved when it goes down for the first of its causes
+func takeDown(devName, cause string, now float64) {
+	Outages = append(Outages, Outage{Dev: devName, Cause: cause, Start: now, End: -1.0})
+
+	dd, present := downDevs[devName]
+	if present {
+		dd.outages = append(dd.outages, len(Outages)-1)
+		return
+	}
+	intrfcs := mrnes.TopoDevByName[devName].DevIntrfcs()
+	dd = &downDev{outages: []int{len(Outages) - 1}, bndwdths: make([]float64, len(intrfcs))}
+	for idx, intrfc := range intrfcs {
+		dd.bndwdths[idx] = intrfc.State.Bndwdth
+		intrfc.State.Bndwdth = downBndwdth
+	}
+	downDevs[devName] = dd
+}
+
+// restore ends the outage of the device named for cause.  The bandwidths of its interfaces are
+// given back once no cause keeps it down, and the threads stalled there are returned to their sources
+func restore(evtMgr *evtm.EventManager, devName, cause string) {
+	now := evtMgr.CurrentSeconds()
+	dd, present := downDevs[devName]
+	if !present {
+		return
+	}
+	for odx, outage := range dd.outages {
+		if Outages[outage].Cause == cause {
+			Outages[outage].End = now
+			dd.outages = append(dd.outages[:odx], dd.outages[odx+1:]...)
+			break
+		}
+	}
+	if len(dd.outages) > 0 {
+		return
+	}
+	for idx, intrfc := range mrnes.TopoDevByName[devName].DevIntrfcs() {
+		intrfc.State.Bndwdth = dd.bndwdths[idx]
+	}
+	delete(downDevs, devName)
+	returnStalled(evtMgr, devName)
+}
+
+// lostThreads holds the number of execution threads of every measurement group returned as lost
+var lostThreads map[string]int = make(map[string]int)
+
+// onRoute is true when the device named is among devs
+func onRoute(devs []string, devName string) bool {
+	for _, dev := range devs {
+		if dev == devName {
+			return true
+		}
+	}
+	return false
+}
+
+// returnStalled counts lost every execution thread in flight on a route through the device named,
+// and returns it to its source through the message the measure Func that started it was given,
+// routed as that Func's cfg says.  The threads are taken in order of the CmpPtn that started them and their
+// execution IDs, so that the run is reproduced.  A thread started so shortly before the device was restored
+// that it had not yet reached it is returned too, and may later return of itself
+func returnStalled(evtMgr *evtm.EventManager, devName string) {
+	stalled := []threadKey{}
+	for key, thread := range startTimes {
+		if thread.cpfi != nil && len(thread.cpfi.Cfg.(*MeasureCfg).LostLabel) > 0 && onRoute(thread.devs, devName) {
+			stalled = append(stalled, key)
+		}
+	}
+	sort.Slice(stalled, func(i, j int) bool {
+		if stalled[i].srtCPID != stalled[j].srtCPID {
+			return stalled[i].srtCPID < stalled[j].srtCPID
+		}
+		return stalled[i].execID < stalled[j].execID
+	})
+
+	for _, key := range stalled {
+		thread := startTimes[key]
+		delete(startTimes, key)
+		delete(visited, key)
+		lostThreads[thread.group] += 1
+
+		msrcfg := thread.cpfi.Cfg.(*MeasureCfg)
+		msg := thread.msg
+		pces.UpdateMsg(msg, thread.cpfi.CPID, msrcfg.LostLabel, msrcfg.LostType, msrcfg.LostMC)
+		thread.cpfi.AddResponse(msg.ExecID, []*pces.CmpPtnMsg{msg})
+		evtMgr.Schedule(thread.cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(0.0))
+	}
+}
+
+// setDownBndwdth gives a new bandwidth to the idx-th interface of a device that is down,
+// to take effect when the device is restored
+func setDownBndwdth(devName string, idx int, bndwdth float64) {
+	downDevs[devName].bndwdths[idx] = bndwdth
+}
+
+// downIntervals gives the intervals of time (clipped to the run, which ended at endTime) during which
+// the device named was down, or some device when devName is empty, merged so that they do not overlap, in order of time
+func downIntervals(devName string, endTime float64) [][2]float64 {
+	intervals := [][2]float64{}
+	for _, outage := range Outages {
+		if len(devName) > 0 && outage.Dev != devName {
+			continue
+		}
+		end := outage.End
+		if end < 0.0 || end > endTime {
+			end = endTime
+		}
+		if outage.Start < end {
+			intervals = append(intervals, [2]float64{outage.Start, end})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i][0] < intervals[j][0] })
+
+	merged := [][2]float64{}
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && interval[0] <= merged[last][1] {
+			merged[last][1] = max(merged[last][1], interval[1])
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// duration gives the time the intervals span in all
+func duration(intervals [][2]float64) float64 {
+	total := 0.0
+	for _, interval := range intervals {
+		total += interval[1] - interval[0]
+	}
+	return total
+}
+
+// degraded is true when some device was down at some time from start to end
+func degraded(intervals [][2]float64, start, end float64) bool {
+	for _, interval := range intervals {
+		if interval[0] <= end && start <= interval[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// DevAvail describes the outages of a device: how many there were, how long it was
+// down in all (in seconds), and the fraction of the run it was up
+type DevAvail struct {
+	Dev          string  `json:"dev" yaml:"dev"`
+	Outages      int     `json:"outages" yaml:"outages"`
+	Downtime     float64 `json:"downtime" yaml:"downtime"`
+	Availability float64 `json:"availability" yaml:"availability"`
+}
+
+// Availability describes what the outages of a run cost.  Service is the fraction of the run
+// during which every device was up, and Degraded the time (in seconds) some device was down.
+// Completed and Lost give the numbers of round trips that returned and that were lost, over every
+// measurement group, and Devices the outages of each device that had one, ordered by name
+type Availability struct {
+	Service   float64    `json:"service" yaml:"service"`
+	Degraded  float64    `json:"degraded" yaml:"degraded"`
+	Completed int        `json:"completed" yaml:"completed"`
+	Lost      int        `json:"lost" yaml:"lost"`
+	Devices   []DevAvail `json:"devices" yaml:"devices"`
+}
+
+// routeDegraded is true when a device of devs was down at some time from start to end, devIntervals
+// giving the intervals each device was down.  With no devices, the route not being known, any device counts
+func routeDegraded(devs []string, devIntervals map[string][][2]float64, start, end float64) bool {
+	if len(devs) == 0 {
+		return degraded(devIntervals[""], start, end)
+	}
+	for _, dev := range devs {
+		if degraded(devIntervals[dev], start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAvailability adds to the results what the outages of the run cost.  A round trip is lost when it
+// was returned to its source, stalled at a device on its route that was restored, or when it had not
+// returned by the end of the run and a device on its route was down at some time after it started,
+// as a round trip that had not returned while its route was up is merely in flight.
+// The number each group lost is given in its statistics, and the kept samples of every group, as tr deletes
+// the warm-up samples, are split between groups named by the group and 'degraded', e.g.
+// 'encryptPerf-SSL:degraded', holding those that had a device on their route down at some time while
+// they were in flight, and by the group and 'normal', holding the others
+func (rs *Results) AddAvailability(pcts []float64, tr Truncation) {
+	intervals := downIntervals("", rs.EndTime)
+	devIntervals := map[string][][2]float64{"": intervals}
+	for _, outage := range Outages {
+		devIntervals[outage.Dev] = downIntervals(outage.Dev, rs.EndTime)
+	}
+
+	avail := new(Availability)
+	avail.Devices = []DevAvail{}
+	avail.Degraded = duration(intervals)
+	avail.Service = 1.0
+	if rs.EndTime > 0.0 {
+		avail.Service = 1.0 - avail.Degraded/rs.EndTime
+	}
+
+	devAvail := make(map[string]*DevAvail)
+	for _, outage := range Outages {
+		da, present := devAvail[outage.Dev]
+		if !present {
+			da = &DevAvail{Dev: outage.Dev}
+			devAvail[outage.Dev] = da
+		}
+		da.Outages += 1
+	}
+	for devName, da := range devAvail {
+		da.Downtime = duration(devIntervals[devName])
+		da.Availability = 1.0
+		if rs.EndTime > 0.0 {
+			da.Availability = 1.0 - da.Downtime/rs.EndTime
+		}
+	}
+	devNames := []string{}
+	for devName := range devAvail {
+		devNames = append(devNames, devName)
+	}
+	sort.Strings(devNames)
+	for _, devName := range devNames {
+		avail.Devices = append(avail.Devices, *devAvail[devName])
+	}
+
+	// round trips returned as lost, and those that had not returned when the run ended
+	lost := make(map[string]int)
+	for group, count := range lostThreads {
+		lost[group] += count
+		avail.Lost += count
+	}
+	for _, thread := range startTimes {
+		if routeDegraded(thread.devs, devIntervals, thread.start, rs.EndTime) {
+			lost[thread.group] += 1
+			avail.Lost += 1
+		}
+	}
+	avail.Completed = len(Samples)
+
+	groupSamples := make(map[string][]Sample)
+	for _, sample := range Samples {
+		groupSamples[sample.Group] = append(groupSamples[sample.Group], sample)
+	}
+	for gdx := range rs.Groups {
+		rs.Groups[gdx].Lost = lost[rs.Groups[gdx].Group]
+	}
+
+	groups := []string{}
+	for group := range groupSamples {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		kept, _, _ := truncate(groupSamples[group], tr)
+		rtts := map[bool][]float64{true: []float64{}, false: []float64{}}
+		for _, sample := range kept {
+			isDegraded := routeDegraded(sample.devs, devIntervals, sample.Start, sample.End)
+			rtts[isDegraded] = append(rtts[isDegraded], sample.RTT)
+		}
+		rs.Groups = append(rs.Groups, computeStats(group+":degraded", rtts[true], pcts))
+		rs.Groups = append(rs.Groups, computeStats(group+":normal", rtts[false], pcts))
+	}
+	rs.Availability = avail
+}
+
+// Report prints the availability of the service and of every device that had an outage
+func (avail *Availability) Report() {
+	fmt.Printf("Service available %g of the run, degraded for %g seconds, %d round trips completed and %d lost\n",
+		avail.Service, avail.Degraded, avail.Completed, avail.Lost)
+	for _, da := range avail.Devices {
+		fmt.Printf("Device %s had %d outages, down %g seconds, available %g of the run\n",
+			da.Dev, da.Outages, da.Downtime, da.Availability)
+	}
+}
@@ -0,0 +1,103 @@
+package measure
+
+import (
+	"testing"
+)
+
+// TestDownIntervals checks the intervals a device, or some device, was down, clipped to the run and merged
+func TestDownIntervals(t *testing.T) {
+	saved := Outages
+	defer func() { Outages = saved }()
+
+	// B is still down when the run ends at 10, and C goes down only after it has ended
+	Outages = []Outage{
+		{Dev: "A", Cause: "failure", Start: 1.0, End: 3.0},
+		{Dev: "B", Cause: "timeline", Start: 2.0, End: -1.0},
+		{Dev: "A", Cause: "timeline", Start: 2.5, End: 5.0},
+		{Dev: "D", Cause: "failure", Start: 4.0, End: 6.0},
+		{Dev: "D", Cause: "failure", Start: 6.0, End: 7.0},
+		{Dev: "A", Cause: "failure", Start: 8.0, End: 12.0},
+		{Dev: "C", Cause: "failure", Start: 20.0, End: 25.0},
+	}
+	tests := []struct {
+		devName  string
+		want     [][2]float64
+		duration float64
+	}{
+		{"A", [][2]float64{{1.0, 5.0}, {8.0, 10.0}}, 6.0},
+		{"B", [][2]float64{{2.0, 10.0}}, 8.0},
+		{"C", [][2]float64{}, 0.0},
+		{"D", [][2]float64{{4.0, 7.0}}, 3.0},
+		{"E", [][2]float64{}, 0.0},
+		{"", [][2]float64{{1.0, 10.0}}, 9.0},
+	}
+	for _, test := range tests {
+		got := downIntervals(test.devName, 10.0)
+		if len(got) != len(test.want) {
+			t.Errorf("downIntervals(%q) = %v, want %v", test.devName, got, test.want)
+			continue
+		}
+		for idx := range got {
+			if got[idx] != test.want[idx] {
+				t.Errorf("downIntervals(%q) = %v, want %v", test.devName, got, test.want)
+				break
+			}
+		}
+		if dur := duration(got); dur != test.duration {
+			t.Errorf("duration(downIntervals(%q)) = %g, want %g", test.devName, dur, test.duration)
+		}
+	}
+}
+
+// TestDegraded checks whether a round trip from start to end overlaps a time some device was down
+func TestDegraded(t *testing.T) {
+	intervals := [][2]float64{{1.0, 5.0}, {8.0, 10.0}}
+	tests := []struct {
+		start, end float64
+		want       bool
+	}{
+		{0.0, 0.5, false},
+		{0.5, 1.0, true},
+		{2.0, 3.0, true},
+		{5.0, 8.0, true},
+		{5.5, 7.9, false},
+		{9.0, 20.0, true},
+		{10.5, 11.0, false},
+	}
+	for _, test := range tests {
+		if got := degraded(intervals, test.start, test.end); got != test.want {
+			t.Errorf("degraded from %g to %g is %v, want %v", test.start, test.end, got, test.want)
+		}
+	}
+	if degraded([][2]float64{}, 0.0, 10.0) {
+		t.Errorf("degraded with no outages is true")
+	}
+}
+
+// TestRouteDegraded checks that a round trip is degraded only by the outages of devices on its route,
+// or of any device when its route is not known
+func TestRouteDegraded(t *testing.T) {
+	devIntervals := map[string][][2]float64{
+		"":       {{1.0, 5.0}, {8.0, 10.0}},
+		"pvtRtr": {{1.0, 5.0}},
+		"eud-3":  {{8.0, 10.0}},
+	}
+	tests := []struct {
+		devs       []string
+		start, end float64
+		want       bool
+	}{
+		{[]string{"srcHost", "pvtRtr"}, 2.0, 3.0, true},
+		{[]string{"srcHost", "pvtRtr"}, 8.5, 9.0, false},
+		{[]string{"srcHost", "eud-3"}, 2.0, 3.0, false},
+		{[]string{"srcHost", "eud-3"}, 7.0, 8.5, true},
+		{[]string{"srcHost"}, 0.0, 20.0, false},
+		{[]string{}, 8.5, 9.0, true},
+		{[]string{}, 5.5, 7.5, false},
+	}
+	for _, test := range tests {
+		if got := routeDegraded(test.devs, devIntervals, test.start, test.end); got != test.want {
+			t.Errorf("route %v degraded from %g to %g is %v, want %v", test.devs, test.start, test.end, got, test.want)
+		}
+	}
+}
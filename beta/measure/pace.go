@@ -0,0 +1,258 @@
+package measure
+
+// the pace class spaces out the packets a source generates.  The source generates its packets
+// without delay, and a pace Func between it and the rest of the CmpPtn passes each packet arriving
+// with method code 'paceOp' on once the time drawn for the gap before it has passed since the last was
+// passed on, or at once if that time is already past.  The packets of a source are passed on in
+// bursts, the bursts in cycles, and the gap before a packet is drawn from the distribution of the
+// times between packets, between bursts, or between cycles, as its place calls for.  The hold uses
+// no CPU, as the time is that of the source's schedule rather than of the device's work
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/pces"
+	"github.com/iti/rngstream"
+	"gopkg.in/yaml.v3"
+	"math"
+)
+
+// like every Func class, get the pace class recognized within pces
+// when the file is loaded, by any application that imports it
+var pcecfgVar *PaceCfg = ClassCreatePaceCfg()
+var pcecfgLoaded bool = pces.RegisterFuncClass(pcecfgVar)
+
+// Gap describes the distribution of a time between packets.  Dist names it and Params are its
+// parameters, as they are for the think class
+type Gap struct {
+	Dist   string    `yaml:"dist" json:"dist"`
+	Params []float64 `yaml:"params" json:"params"`
+}
+
+// PaceCfg is the cfg put into the cpInit input file for a pace Func.  Gaps holds the distributions
+// of the time between the packets of a burst, between bursts, and between cycles, in that order.
+// Burst is the number of packets in a burst and Bursts the number of bursts in a cycle.
+// Route, TgtLabel, and TgtMC are indexed by method code, as they are for the measure class.
+// Stream is the arrival stream the gaps are drawn from (see stream.go), 0 for one of the package's streams
+type PaceCfg struct {
+	Gaps     []Gap             `yaml:"gaps" json:"gaps"`
+	Burst    int               `yaml:"burst" json:"burst"`
+	Bursts   int               `yaml:"bursts" json:"bursts"`
+	Route    map[string]string `yaml:"route" json:"route"`
+	TgtLabel map[string]string `yaml:"tgtlabel" json:"tgtlabel"`
+	TgtMC    map[string]string `yaml:"tgtmc" json:"tgtmc"`
+	Stream   int               `yaml:"stream" json:"stream"`
+	Trace    bool              `yaml:"trace" json:"trace"`
+}
+
+// CreatePaceCfg is a constructor.  Packets are passed on in bursts of burst packets,
+// bursts bursts to a cycle, the gaps between them drawn from the distributions added by AddGap
+func CreatePaceCfg(burst, bursts int) *PaceCfg {
+	pcecfg := new(PaceCfg)
+	pcecfg.Gaps = []Gap{}
+	pcecfg.Burst = burst
+	pcecfg.Bursts = bursts
+	pcecfg.Route = make(map[string]string)
+	pcecfg.TgtLabel = make(map[string]string)
+	pcecfg.TgtMC = make(map[string]string)
+	pcecfg.Stream = 0
+	pcecfg.Trace = false
+	return pcecfg
+}
+
+// AddGap adds the distribution named by dist, with parameters params, to those the gaps
+// are drawn from, the first added being that of the time between the packets of a burst
+func (pcecfg *PaceCfg) AddGap(dist string, params []float64) {
+	pcecfg.Gaps = append(pcecfg.Gaps, Gap{Dist: dist, Params: params})
+}
+
+// AddRoute says that what arrives with method code methodCode is passed on
+// as type msgType, to the Func labeled tgtLabel, with method code tgtMC
+func (pcecfg *PaceCfg) AddRoute(methodCode, msgType, tgtLabel, tgtMC string) {
+	pcecfg.Route[methodCode] = msgType
+	pcecfg.TgtLabel[methodCode] = tgtLabel
+	pcecfg.TgtMC[methodCode] = tgtMC
+}
+
+// route is required for the router interface
+func (pcecfg *PaceCfg) route(methodCode string) (string, string, string) {
+	return pcecfg.Route[methodCode], pcecfg.TgtLabel[methodCode], pcecfg.TgtMC[methodCode]
+}
+
+// gapIndex gives the index in Gaps of the distribution of the gap before the packet passed on
+// after the first count: between packets within a burst, between bursts within a cycle,
+// and otherwise between cycles
+func (pcecfg *PaceCfg) gapIndex(count int) int {
+	switch {
+	case count%pcecfg.Burst != 0:
+		return 0
+	case (count/pcecfg.Burst)%pcecfg.Bursts != 0:
+		return 1
+	}
+	return 2
+}
+
+// Sample draws the gap (in seconds) before the packet passed on after the first count,
+// using the rng stream
+func (pcecfg *PaceCfg) Sample(count int, rng *rngstream.RngStream) float64 {
+	gap := pcecfg.Gaps[pcecfg.gapIndex(count)]
+	return sampleDist(gap.Dist, gap.Params, rng)
+}
+
+// PaceState holds the number of packets a pace Func has passed on, the time (in seconds)
+// it passed on the last, and the rng stream it draws from
+type PaceState struct {
+	calls int
+	last  float64
+	rng   *rngstream.RngStream
+}
+
+// createPaceState is a constructor.  The gaps are drawn from the arrival stream stream
+func createPaceState(label string, stream int) *PaceState {
+	pcs := new(PaceState)
+	pcs.calls = 0
+	pcs.last = 0.0
+	pcs.rng = arrivalStream(label, stream)
+	return pcs
+}
+
+// ClassCreatePaceCfg is a constructor called just to create an instance,
+// and put reference to pace and its methods in the pces data structures
+func ClassCreatePaceCfg() *PaceCfg {
+	pcecfg := CreatePaceCfg(1, 1)
+
+	// put the event handling information into pces.ClassMethods
+	fmap := make(map[string]pces.RespMethod)
+	fmap["paceOp"] = pces.RespMethod{Start: paceHold, End: pces.ExitFunc}
+	pces.ClassMethods["pace"] = fmap
+
+	return pcecfg
+}
+
+// FuncClassName required for the FuncClassCfg interface
+func (pcecfg *PaceCfg) FuncClassName() string {
+	return "pace"
+}
+
+// CreateCfg required for the FuncClassCfg interface
+func (pcecfg *PaceCfg) CreateCfg(cfgStr string, useYAML bool) any {
+	pcecfgVarAny, err := pcecfg.Deserialize(cfgStr, useYAML)
+	if err != nil {
+		panic(fmt.Errorf("pace.InitCfg sees deserialization error"))
+	}
+	return pcecfgVarAny
+}
+
+// InitCfg required for the FuncClassCfg interface
+func (pcecfg *PaceCfg) InitCfg(cpfi *pces.CmpPtnFuncInst, cfgStr string, useYAML bool) {
+
+	// Deserialize the configuration for this Func
+	pcecfgVarAny := pcecfg.CreateCfg(cfgStr, useYAML)
+	pcecfgv := pcecfgVarAny.(*PaceCfg)
+	cpfi.Cfg = pcecfgv
+
+	cpfi.State = createPaceState(cpfi.Label, pcecfgv.Stream)
+	cpfi.Trace = pcecfgv.Trace
+}
+
+// ValidateCfg checks that the Func has the distributions of the times between packets, bursts,
+// and cycles, each known and with the parameters it needs, that bursts and cycles are not empty,
+// and that the Func has routes, each for a method code the class responds to
+func (pcecfg *PaceCfg) ValidateCfg(cpfi *pces.CmpPtnFuncInst) error {
+	pcecfgv := cpfi.Cfg.(*PaceCfg)
+	if len(pcecfgv.Gaps) != 3 {
+		return fmt.Errorf("pace Func %s has %d gap distributions rather than 3", cpfi.Label, len(pcecfgv.Gaps))
+	}
+	for _, gap := range pcecfgv.Gaps {
+		if err := checkDist(gap.Dist, gap.Params); err != nil {
+			return fmt.Errorf("pace Func %s %s", cpfi.Label, err.Error())
+		}
+	}
+	if pcecfgv.Burst < 1 || pcecfgv.Bursts < 1 {
+		return fmt.Errorf("pace Func %s has bursts of %d packets and cycles of %d bursts", cpfi.Label,
+			pcecfgv.Burst, pcecfgv.Bursts)
+	}
+	if len(pcecfgv.TgtLabel) == 0 {
+		return fmt.Errorf("pace Func %s has no routes", cpfi.Label)
+	}
+	for methodCode := range pcecfgv.TgtLabel {
+		_, present := pces.ClassMethods["pace"][methodCode]
+		if !present {
+			return fmt.Errorf("pace Func %s has route for unknown method code %s", cpfi.Label, methodCode)
+		}
+	}
+	return nil
+}
+
+// Serialize transforms the pace cfg into string form for
+// inclusion through a file
+func (pcecfg *PaceCfg) Serialize(useYAML bool) (string, error) {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*pcecfg)
+	} else {
+		bytes, merr = json.Marshal(*pcecfg)
+	}
+
+	if merr != nil {
+		return "", merr
+	}
+
+	return string(bytes[:]), nil
+}
+
+// Deserialize recovers a serialized representation of a pace cfg structure
+func (pcecfg *PaceCfg) Deserialize(fss string, useYAML bool) (any, error) {
+	// turn the string into a slice of bytes
+	var err error
+	fsb := []byte(fss)
+
+	example := CreatePaceCfg(1, 1)
+
+	// Select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(fsb, example)
+	} else {
+		err = json.Unmarshal(fsb, example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return example, nil
+}
+
+// now include the functions whose executions are triggered by messages to the pace function,
+// passing through pces.EnterFunc.
+//
+// paceHold passes the packet on once the gap drawn for it has passed since the last was passed on,
+// or at once when the packet arrives after that.  The first packet is passed on as it arrives.
+// The gap is noted as the draw the packet's execution thread starts after
+func paceHold(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	pcs := cpfi.State.(*PaceState)
+	pcecfg := cpfi.Cfg.(*PaceCfg)
+
+	now := evtMgr.CurrentSeconds()
+	release := now
+	gap := 0.0
+	if pcs.calls > 0 {
+		gap = pcecfg.Sample(pcs.calls, pcs.rng)
+		release = math.Max(now, pcs.last+gap)
+	}
+	drawThread(msg.CmpHdr.SrtCPID, msg.ExecID, pcs.calls, gap)
+	pcs.calls += 1
+	pcs.last = release
+
+	msgType, tgtLabel, tgtMC := pcecfg.route(methodCode)
+
+	// update the message to reflect the next station, in the same CmpPtn
+	pces.UpdateMsg(msg, cpfi.CPID, tgtLabel, msgType, tgtMC)
+
+	// put the message where pces.ExitFunc will be looking for it, once the gap has passed
+	cpfi.AddResponse(msg.ExecID, []*pces.CmpPtnMsg{msg})
+	evtMgr.Schedule(cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(release-now))
+}
@@ -0,0 +1,279 @@
+package measure
+
+// the profile class varies the rate of a packet source over virtual time.  The source generates
+// packets at the greatest rate the profile reaches, and a profile Func between it and the rest of
+// the CmpPtn thins them: a packet arriving with method code 'thinOp' at time t is passed on with
+// probability rate(t)/peak, and otherwise is returned at once to the source as though it had completed
+// (Lewis and Shedler's thinning, which gives exactly the profile's rate when the source is Poisson).
+// A returned packet was never started by a measure Func, and so gives no RTT sample.
+// The profile is a piecewise schedule of rate multipliers, stepped or linear between its steps,
+// repeated every Period seconds when Period is positive.  Neither method takes any time.
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/pces"
+	"github.com/iti/rngstream"
+	"gopkg.in/yaml.v3"
+	"math"
+)
+
+// like every Func class, get the profile class recognized within pces
+// when the file is loaded, by any application that imports it
+var prfcfgVar *ProfileCfg = ClassCreateProfileCfg()
+var prfcfgLoaded bool = pces.RegisterFuncClass(prfcfgVar)
+
+// ProfileCfg is the cfg put into the cpInit input file for a profile Func.  Times holds the
+// times (in seconds) the steps of the profile start at, in increasing order, and Rates the
+// rate multiplier of each step.  Linear moves the rate linearly from each step to the next,
+// rather than holding it until the next.  Peak is the multiplier the source generates at.
+// Route, TgtLabel, and TgtMC give where kept packets go, indexed by method code as they are
+// for the measure class, and ThinType, ThinLabel, and ThinMC where thinned packets go.  Stream is
+// the arrival stream the Func draws from (see stream.go), 0 for one of the package's streams
+type ProfileCfg struct {
+	Times     []float64         `yaml:"times" json:"times"`
+	Rates     []float64         `yaml:"rates" json:"rates"`
+	Linear    bool              `yaml:"linear" json:"linear"`
+	Period    float64           `yaml:"period" json:"period"`
+	Peak      float64           `yaml:"peak" json:"peak"`
+	Route     map[string]string `yaml:"route" json:"route"`
+	TgtLabel  map[string]string `yaml:"tgtlabel" json:"tgtlabel"`
+	TgtMC     map[string]string `yaml:"tgtmc" json:"tgtmc"`
+	ThinType  string            `yaml:"thintype" json:"thintype"`
+	ThinLabel string            `yaml:"thinlabel" json:"thinlabel"`
+	ThinMC    string            `yaml:"thinmc" json:"thinmc"`
+	Stream    int               `yaml:"stream" json:"stream"`
+	Trace     bool              `yaml:"trace" json:"trace"`
+}
+
+// CreateProfileCfg is a constructor.  The step starting at times[i] has rate multiplier
+// rates[i], and the profile repeats every period seconds when period is positive
+func CreateProfileCfg(times, rates []float64, linear bool, period float64) *ProfileCfg {
+	prfcfg := new(ProfileCfg)
+	prfcfg.Times = times
+	prfcfg.Rates = rates
+	prfcfg.Linear = linear
+	prfcfg.Period = period
+	prfcfg.Peak = 0.0
+	for _, rate := range rates {
+		prfcfg.Peak = math.Max(prfcfg.Peak, rate)
+	}
+	prfcfg.Route = make(map[string]string)
+	prfcfg.TgtLabel = make(map[string]string)
+	prfcfg.TgtMC = make(map[string]string)
+	prfcfg.Stream = 0
+	prfcfg.Trace = false
+	return prfcfg
+}
+
+// AddRoute says that a packet kept when it arrives with method code methodCode
+// is passed on as type msgType, to the Func labeled tgtLabel, with method code tgtMC
+func (prfcfg *ProfileCfg) AddRoute(methodCode, msgType, tgtLabel, tgtMC string) {
+	prfcfg.Route[methodCode] = msgType
+	prfcfg.TgtLabel[methodCode] = tgtLabel
+	prfcfg.TgtMC[methodCode] = tgtMC
+}
+
+// AddThinned says that a thinned packet is returned as type msgType, to the Func
+// labeled tgtLabel, with method code tgtMC
+func (prfcfg *ProfileCfg) AddThinned(msgType, tgtLabel, tgtMC string) {
+	prfcfg.ThinType = msgType
+	prfcfg.ThinLabel = tgtLabel
+	prfcfg.ThinMC = tgtMC
+}
+
+// route is required for the router interface
+func (prfcfg *ProfileCfg) route(methodCode string) (string, string, string) {
+	return prfcfg.Route[methodCode], prfcfg.TgtLabel[methodCode], prfcfg.TgtMC[methodCode]
+}
+
+// RateAt gives the rate multiplier of the profile at time t (in seconds).  Before
+// the first step the first step's multiplier holds
+func (prfcfg *ProfileCfg) RateAt(t float64) float64 {
+	if len(prfcfg.Times) == 0 {
+		return prfcfg.Peak
+	}
+	if prfcfg.Period > 0.0 {
+		t = math.Mod(t, prfcfg.Period)
+	}
+
+	// find the step holding t
+	step := 0
+	for sdx, start := range prfcfg.Times {
+		if start <= t {
+			step = sdx
+		}
+	}
+	rate := prfcfg.Rates[step]
+	if !prfcfg.Linear || t < prfcfg.Times[step] {
+		return rate
+	}
+
+	// a linear profile moves towards the next step, which after the last is the
+	// first step of the next period, when there is one
+	var nextTime, nextRate float64
+	switch {
+	case step+1 < len(prfcfg.Times):
+		nextTime, nextRate = prfcfg.Times[step+1], prfcfg.Rates[step+1]
+	case prfcfg.Period > 0.0:
+		nextTime, nextRate = prfcfg.Period+prfcfg.Times[0], prfcfg.Rates[0]
+	default:
+		return rate
+	}
+	return rate + (nextRate-rate)*(t-prfcfg.Times[step])/(nextTime-prfcfg.Times[step])
+}
+
+// ProfileState holds the number of packets a profile Func has seen and thinned,
+// and the rng stream it draws from
+type ProfileState struct {
+	calls   int
+	thinned int
+	rng     *rngstream.RngStream
+}
+
+// createProfileState is a constructor.  The Func draws from the arrival stream stream
+func createProfileState(label string, stream int) *ProfileState {
+	prfs := new(ProfileState)
+	prfs.calls = 0
+	prfs.thinned = 0
+	prfs.rng = arrivalStream(label, stream)
+	return prfs
+}
+
+// ClassCreateProfileCfg is a constructor called just to create an instance,
+// and put reference to profile and its methods in the pces data structures
+func ClassCreateProfileCfg() *ProfileCfg {
+	prfcfg := CreateProfileCfg([]float64{}, []float64{}, false, 0.0)
+
+	// put the event handling information into pces.ClassMethods
+	fmap := make(map[string]pces.RespMethod)
+	fmap["thinOp"] = pces.RespMethod{Start: profileThin, End: pces.ExitFunc}
+	pces.ClassMethods["profile"] = fmap
+
+	return prfcfg
+}
+
+// FuncClassName required for the FuncClassCfg interface
+func (prfcfg *ProfileCfg) FuncClassName() string {
+	return "profile"
+}
+
+// CreateCfg required for the FuncClassCfg interface
+func (prfcfg *ProfileCfg) CreateCfg(cfgStr string, useYAML bool) any {
+	prfcfgVarAny, err := prfcfg.Deserialize(cfgStr, useYAML)
+	if err != nil {
+		panic(fmt.Errorf("profile.InitCfg sees deserialization error"))
+	}
+	return prfcfgVarAny
+}
+
+// InitCfg required for the FuncClassCfg interface
+func (prfcfg *ProfileCfg) InitCfg(cpfi *pces.CmpPtnFuncInst, cfgStr string, useYAML bool) {
+
+	// Deserialize the configuration for this Func
+	prfcfgVarAny := prfcfg.CreateCfg(cfgStr, useYAML)
+	prfcfgv := prfcfgVarAny.(*ProfileCfg)
+	cpfi.Cfg = prfcfgv
+
+	cpfi.State = createProfileState(cpfi.Label, prfcfgv.Stream)
+	cpfi.Trace = prfcfgv.Trace
+}
+
+// ValidateCfg checks that the profile has a rate for each step, in increasing order of time and
+// within a period, that no rate is negative or above the peak, and that the Func has routes for
+// kept and thinned packets
+func (prfcfg *ProfileCfg) ValidateCfg(cpfi *pces.CmpPtnFuncInst) error {
+	prfcfgv := cpfi.Cfg.(*ProfileCfg)
+	if len(prfcfgv.Times) == 0 || len(prfcfgv.Times) != len(prfcfgv.Rates) {
+		return fmt.Errorf("profile Func %s has %d step times and %d rates", cpfi.Label,
+			len(prfcfgv.Times), len(prfcfgv.Rates))
+	}
+	if prfcfgv.Peak <= 0.0 {
+		return fmt.Errorf("profile Func %s has no positive rate", cpfi.Label)
+	}
+	for sdx := range prfcfgv.Times {
+		if sdx > 0 && prfcfgv.Times[sdx] <= prfcfgv.Times[sdx-1] {
+			return fmt.Errorf("profile Func %s has step times out of order", cpfi.Label)
+		}
+		if prfcfgv.Rates[sdx] < 0.0 || prfcfgv.Rates[sdx] > prfcfgv.Peak {
+			return fmt.Errorf("profile Func %s has rate %g outside [0,%g]", cpfi.Label, prfcfgv.Rates[sdx], prfcfgv.Peak)
+		}
+	}
+	if prfcfgv.Period > 0.0 && (prfcfgv.Times[0] < 0.0 || prfcfgv.Times[len(prfcfgv.Times)-1] >= prfcfgv.Period) {
+		return fmt.Errorf("profile Func %s has steps outside its period of %g seconds", cpfi.Label, prfcfgv.Period)
+	}
+	if len(prfcfgv.TgtLabel) == 0 || len(prfcfgv.ThinLabel) == 0 {
+		return fmt.Errorf("profile Func %s lacks a route for kept or thinned packets", cpfi.Label)
+	}
+	for methodCode := range prfcfgv.TgtLabel {
+		_, present := pces.ClassMethods["profile"][methodCode]
+		if !present {
+			return fmt.Errorf("profile Func %s has route for unknown method code %s", cpfi.Label, methodCode)
+		}
+	}
+	return nil
+}
+
+// Serialize transforms the profile cfg into string form for
+// inclusion through a file
+func (prfcfg *ProfileCfg) Serialize(useYAML bool) (string, error) {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*prfcfg)
+	} else {
+		bytes, merr = json.Marshal(*prfcfg)
+	}
+
+	if merr != nil {
+		return "", merr
+	}
+
+	return string(bytes[:]), nil
+}
+
+// Deserialize recovers a serialized representation of a profile cfg structure
+func (prfcfg *ProfileCfg) Deserialize(fss string, useYAML bool) (any, error) {
+	// turn the string into a slice of bytes
+	var err error
+	fsb := []byte(fss)
+
+	example := CreateProfileCfg([]float64{}, []float64{}, false, 0.0)
+
+	// Select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(fsb, example)
+	} else {
+		err = json.Unmarshal(fsb, example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return example, nil
+}
+
+// now include the functions whose executions are triggered by messages to the profile function,
+// passing through pces.EnterFunc.
+//
+// profileThin passes the packet on with probability rate(t)/peak, and otherwise returns it to the source
+func profileThin(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	prfs := cpfi.State.(*ProfileState)
+	prfs.calls += 1
+	prfcfg := cpfi.Cfg.(*ProfileCfg)
+
+	if prfs.rng.RandU01()*prfcfg.Peak < prfcfg.RateAt(evtMgr.CurrentSeconds()) {
+		passOn(evtMgr, cpfi, methodCode, msg)
+		return
+	}
+	prfs.thinned += 1
+	dropThread(msg.CmpHdr.SrtCPID, msg.ExecID)
+
+	// update the message to reflect its return to the source, in the same CmpPtn
+	pces.UpdateMsg(msg, cpfi.CPID, prfcfg.ThinLabel, prfcfg.ThinType, prfcfg.ThinMC)
+	cpfi.AddResponse(msg.ExecID, []*pces.CmpPtnMsg{msg})
+	evtMgr.Schedule(cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(0.0))
+}
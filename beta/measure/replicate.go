@@ -0,0 +1,267 @@
+package measure
+
+// replicate.go combines the Results of independent replications of a simulation run,
+// reporting for every statistic of every measurement group its mean across the
+// replications and a t-based confidence interval around that mean
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gonum.org/v1/gonum/stat/distuv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// CI describes the mean of a statistic across replications, and the half-width
+// and bounds of the confidence interval around it
+type CI struct {
+	Mean      float64 `json:"mean" yaml:"mean"`
+	HalfWidth float64 `json:"halfwidth" yaml:"halfwidth"`
+	Low       float64 `json:"low" yaml:"low"`
+	High      float64 `json:"high" yaml:"high"`
+}
+
+// PctCI gives the confidence interval of the RTT at a percentile
+type PctCI struct {
+	Pct float64 `json:"pct" yaml:"pct"`
+	CI  CI      `json:"ci" yaml:"ci"`
+}
+
+// GroupCI holds the confidence intervals of the statistics of a measurement group.
+// Replications is the number of replications in which the group gathered samples
+type GroupCI struct {
+	Group        string  `json:"group" yaml:"group"`
+	Replications int     `json:"replications" yaml:"replications"`
+	Samples      CI      `json:"samples" yaml:"samples"`
+	Min          CI      `json:"min" yaml:"min"`
+	Q25          CI      `json:"q25" yaml:"q25"`
+	Mean         CI      `json:"mean" yaml:"mean"`
+	Median       CI      `json:"median" yaml:"median"`
+	Q75          CI      `json:"q75" yaml:"q75"`
+	Max          CI      `json:"max" yaml:"max"`
+	Percentiles  []PctCI `json:"percentiles,omitempty" yaml:"percentiles,omitempty"`
+}
+
+// AvailCI holds the confidence intervals of the fraction of a run the service was available,
+// and of the number of round trips lost, over the replications that report their availability
+type AvailCI struct {
+	Service CI `json:"service" yaml:"service"`
+	Lost    CI `json:"lost" yaml:"lost"`
+}
+
+// ReplResults is what a set of replications reports: the confidence level, the rng master seed
+// they share (-1 when none was given, the streams then starting from their default seeds) and the substream each drew from, the confidence intervals of every measurement group and, when devices were
+// taken out of service, of the availability, and the Results of each replication
+type ReplResults struct {
+	Replications int        `json:"replications" yaml:"replications"`
+	Confidence   float64    `json:"confidence" yaml:"confidence"`
+	Seed         int64      `json:"seed" yaml:"seed"`
+	Substreams   []int      `json:"substreams" yaml:"substreams"`
+	StopTime     float64    `json:"stoptime" yaml:"stoptime"`
+	Groups       []GroupCI  `json:"groups" yaml:"groups"`
+	Availability *AvailCI   `json:"availability,omitempty" yaml:"availability,omitempty"`
+	Runs         []*Results `json:"runs" yaml:"runs"`
+}
+
+// ReadResults recovers the json form of Results written by WriteToFile
+func ReadResults(filename string) (*Results, error) {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rs := new(Results)
+	err = json.Unmarshal(dict, rs)
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// ComputeCI computes the mean of the values and the confidence interval around it
+// at the given confidence level (e.g. 0.95), using the Student t distribution with
+// one less degree of freedom than there are values.  With fewer than two values
+// there is no interval, and the half-width is reported as zero
+func ComputeCI(values []float64, confidence float64) CI {
+	n := len(values)
+	if n == 0 {
+		return CI{}
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(n)
+	if n < 2 {
+		return CI{Mean: mean, Low: mean, High: mean}
+	}
+
+	sumsq := 0.0
+	for _, value := range values {
+		sumsq += (value - mean) * (value - mean)
+	}
+	stddev := math.Sqrt(sumsq / float64(n-1))
+
+	tdist := distuv.StudentsT{Mu: 0.0, Sigma: 1.0, Nu: float64(n - 1)}
+	halfWidth := tdist.Quantile(1.0-(1.0-confidence)/2.0) * stddev / math.Sqrt(float64(n))
+
+	return CI{Mean: mean, HalfWidth: halfWidth, Low: mean - halfWidth, High: mean + halfWidth}
+}
+
+// CombineReplications computes the confidence intervals of every statistic of every measurement
+// group reported by the replications in runs, which were run with the rng master seed given,
+// each drawing from the substream of every rng stream given by substreams
+func CombineReplications(runs []*Results, seed int64, substreams []int, confidence float64) *ReplResults {
+	rr := new(ReplResults)
+	rr.Replications = len(runs)
+	rr.Confidence = confidence
+	rr.Seed = seed
+	rr.Substreams = substreams
+	rr.Runs = runs
+	rr.Groups = []GroupCI{}
+	if len(runs) > 0 {
+		rr.StopTime = runs[0].StopTime
+	}
+
+	// gather the statistics of each group from every replication that reports it
+	groupStats := make(map[string][]GroupStats)
+	for _, rs := range runs {
+		for _, gs := range rs.Groups {
+			groupStats[gs.Group] = append(groupStats[gs.Group], gs)
+		}
+	}
+
+	groups := []string{}
+	for group := range groupStats {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		gsList := groupStats[group]
+
+		// ci applies ComputeCI to one statistic, pulled out of each replication's GroupStats by stat
+		ci := func(stat func(GroupStats) float64) CI {
+			values := make([]float64, len(gsList))
+			for idx, gs := range gsList {
+				values[idx] = stat(gs)
+			}
+			return ComputeCI(values, confidence)
+		}
+
+		gci := GroupCI{Group: group, Replications: len(gsList)}
+		gci.Samples = ci(func(gs GroupStats) float64 { return float64(gs.Samples) })
+		gci.Min = ci(func(gs GroupStats) float64 { return gs.Min })
+		gci.Q25 = ci(func(gs GroupStats) float64 { return gs.Q25 })
+		gci.Mean = ci(func(gs GroupStats) float64 { return gs.Mean })
+		gci.Median = ci(func(gs GroupStats) float64 { return gs.Median })
+		gci.Q75 = ci(func(gs GroupStats) float64 { return gs.Q75 })
+		gci.Max = ci(func(gs GroupStats) float64 { return gs.Max })
+
+		// every replication was asked for the same percentiles
+		for idx, pv := range gsList[0].Percentiles {
+			pctCI := ci(func(gs GroupStats) float64 {
+				if idx < len(gs.Percentiles) {
+					return gs.Percentiles[idx].Value
+				}
+				return 0.0
+			})
+			gci.Percentiles = append(gci.Percentiles, PctCI{Pct: pv.Pct, CI: pctCI})
+		}
+		rr.Groups = append(rr.Groups, gci)
+	}
+
+	service := []float64{}
+	lost := []float64{}
+	for _, rs := range runs {
+		if rs.Availability != nil {
+			service = append(service, rs.Availability.Service)
+			lost = append(lost, float64(rs.Availability.Lost))
+		}
+	}
+	if len(service) > 0 {
+		rr.Availability = &AvailCI{Service: ComputeCI(service, confidence), Lost: ComputeCI(lost, confidence)}
+	}
+	return rr
+}
+
+// WriteToFile stores the ReplResults struct to the file whose name is given.
+// A file whose name ends in '.csv' gets a csv form with one line per statistic of each measurement group,
+// any other gets json
+func (rr *ReplResults) WriteToFile(filename string) error {
+	if filepath.Ext(filename) == ".csv" {
+		return rr.writeCSV(filename)
+	}
+
+	bytes, merr := json.MarshalIndent(*rr, "", "\t")
+	if merr != nil {
+		return merr
+	}
+	return os.WriteFile(filename, bytes, 0644)
+}
+
+// writeCSV writes the confidence intervals in csv form
+func (rr *ReplResults) writeCSV(filename string) error {
+	f, cerr := os.Create(filename)
+	if cerr != nil {
+		return cerr
+	}
+	defer f.Close()
+
+	ftoa := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"group", "statistic", "replications", "confidence", "mean", "halfwidth", "low", "high"})
+	for _, gci := range rr.Groups {
+		line := func(stat string, ci CI) {
+			w.Write([]string{gci.Group, stat, strconv.Itoa(gci.Replications), ftoa(rr.Confidence),
+				ftoa(ci.Mean), ftoa(ci.HalfWidth), ftoa(ci.Low), ftoa(ci.High)})
+		}
+		line("samples", gci.Samples)
+		line("min", gci.Min)
+		line("q25", gci.Q25)
+		line("mean", gci.Mean)
+		line("median", gci.Median)
+		line("q75", gci.Q75)
+		line("max", gci.Max)
+		for _, pci := range gci.Percentiles {
+			line(pctLabel(pci.Pct), pci.CI)
+		}
+	}
+
+	// the availability takes lines of its own, under the group 'availability'
+	if rr.Availability != nil {
+		for _, stat := range []struct {
+			name string
+			ci   CI
+		}{{"service", rr.Availability.Service}, {"lost", rr.Availability.Lost}} {
+			w.Write([]string{"availability", stat.name, strconv.Itoa(rr.Replications), ftoa(rr.Confidence),
+				ftoa(stat.ci.Mean), ftoa(stat.ci.HalfWidth), ftoa(stat.ci.Low), ftoa(stat.ci.High)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing replication results to %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Report prints the mean and confidence interval of the mean and median RTT of every measurement group
+func (rr *ReplResults) Report() {
+	for _, gci := range rr.Groups {
+		fmt.Printf("Measurement group %s over %d replications has mean %g +/- %g, median %g +/- %g (%g%% confidence)\n",
+			gci.Group, gci.Replications, gci.Mean.Mean, gci.Mean.HalfWidth, gci.Median.Mean, gci.Median.HalfWidth,
+			100.0*rr.Confidence)
+	}
+	if rr.Availability != nil {
+		fmt.Printf("Service available %g +/- %g of the run, %g +/- %g round trips lost (%g%% confidence)\n",
+			rr.Availability.Service.Mean, rr.Availability.Service.HalfWidth, rr.Availability.Lost.Mean,
+			rr.Availability.Lost.HalfWidth, 100.0*rr.Confidence)
+	}
+}
@@ -0,0 +1,40 @@
+package measure
+
+import (
+	"math"
+	"testing"
+)
+
+// TestComputeCI checks the confidence intervals of ComputeCI against those computed by hand,
+// from the quantiles of Student's t distribution as tabulated
+func TestComputeCI(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []float64
+		confidence float64
+		want       CI
+	}{
+		{"none", []float64{}, 0.95, CI{}},
+		{"one", []float64{5.0}, 0.95, CI{Mean: 5.0, HalfWidth: 0.0, Low: 5.0, High: 5.0}},
+
+		// s = 1.4142136, s/sqrt(2) = 1, t(0.975; 1) = 12.7062047
+		{"two", []float64{2.0, 4.0}, 0.95,
+			CI{Mean: 3.0, HalfWidth: 12.7062047, Low: -9.7062047, High: 15.7062047}},
+
+		// s = sqrt(2.5), s/sqrt(5) = 0.7071068, t(0.975; 4) = 2.7764451
+		{"five at 95%", []float64{1.0, 2.0, 3.0, 4.0, 5.0}, 0.95,
+			CI{Mean: 3.0, HalfWidth: 1.9632432, Low: 1.0367568, High: 4.9632432}},
+
+		// t(0.95; 4) = 2.1318468
+		{"five at 90%", []float64{5.0, 4.0, 3.0, 2.0, 1.0}, 0.90,
+			CI{Mean: 3.0, HalfWidth: 1.5074432, Low: 1.4925568, High: 4.5074432}},
+		{"identical", []float64{7.0, 7.0, 7.0}, 0.95, CI{Mean: 7.0, HalfWidth: 0.0, Low: 7.0, High: 7.0}},
+	}
+	for _, test := range tests {
+		got := ComputeCI(test.values, test.confidence)
+		if math.Abs(got.Mean-test.want.Mean) > 1e-6 || math.Abs(got.HalfWidth-test.want.HalfWidth) > 1e-6 ||
+			math.Abs(got.Low-test.want.Low) > 1e-6 || math.Abs(got.High-test.want.High) > 1e-6 {
+			t.Errorf("%s: ComputeCI(%v, %g) = %+v, want %+v", test.name, test.values, test.confidence, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package measure
+
+// results.go holds the samples gathered by measure Funcs, and the
+// statistics computed from them that a simulation run reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/iti/pces"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// threadKey identifies an execution thread by the CmpPtn that started it and its execution ID
+type threadKey struct {
+	srtCPID int
+	execID  int
+}
+
+// Sample describes one completed execution thread: the measurement group it belongs to,
+// the CmpPtn it started from and the one it visited (and that one's class, if it has one),
+// and when it started and ended (in seconds).  Seq and Draw describe the arrival draw the thread
+// started after, the gap drawn for it by a pace Func or the think time drawn before it: Seq counts
+// the draws of the Func that made it, from 0, and Draw is the time drawn (in seconds).  Seq is -1
+// for a thread that started after no draw
+type Sample struct {
+	ExecID int     `json:"execid" yaml:"execid"`
+	Group  string  `json:"group" yaml:"group"`
+	Src    string  `json:"src" yaml:"src"`
+	Dst    string  `json:"dst" yaml:"dst"`
+	Class  string  `json:"class,omitempty" yaml:"class,omitempty"`
+	Start  float64 `json:"start" yaml:"start"`
+	End    float64 `json:"end" yaml:"end"`
+	RTT    float64 `json:"rtt" yaml:"rtt"`
+	Seq    int     `json:"seq" yaml:"seq"`
+	Draw   float64 `json:"draw" yaml:"draw"`
+
+	// the devices the thread's round trip crossed, when the measure Funcs knew them
+	devs []string
+}
+
+// arrivalDraw describes a draw from an arrival stream, its place among the draws of the Func that made it and its value
+type arrivalDraw struct {
+	seq   int
+	value float64
+}
+
+// noDraw is the arrivalDraw of a thread that started after no draw
+var noDraw arrivalDraw = arrivalDraw{seq: -1}
+
+// started describes the start of an execution thread, its measurement group and time, the arrival
+// draw it started after, the devices its round trip crosses (none when they are not known), and the
+// Func that started it with a copy of the message it started with, through which it is returned when lost
+type started struct {
+	group string
+	start float64
+	draw  arrivalDraw
+	devs  []string
+	cpfi  *pces.CmpPtnFuncInst
+	msg   *pces.CmpPtnMsg
+}
+
+// startTimes holds the start of every execution thread that has been started but not ended
+var startTimes map[threadKey]started = make(map[threadKey]started)
+
+// threadDraws holds the arrival draw made for every execution thread that has not yet started
+var threadDraws map[threadKey]arrivalDraw = make(map[threadKey]arrivalDraw)
+
+// nextDraws holds, by the ID of the CmpPtn that starts it, the arrival draw made for the
+// next execution thread it starts, whose execution ID is not known when the draw is made
+var nextDraws map[int]arrivalDraw = make(map[int]arrivalDraw)
+
+// drawThread notes the arrival draw made for an execution thread that is about to start
+func drawThread(srtCPID, execID, seq int, value float64) {
+	threadDraws[threadKey{srtCPID: srtCPID, execID: execID}] = arrivalDraw{seq: seq, value: value}
+}
+
+// dropThread forgets the arrival draw made for an execution thread that will not start
+func dropThread(srtCPID, execID int) {
+	delete(threadDraws, threadKey{srtCPID: srtCPID, execID: execID})
+}
+
+// drawNext notes the arrival draw made for the next execution thread the CmpPtn srtCPID starts
+func drawNext(srtCPID, seq int, value float64) {
+	nextDraws[srtCPID] = arrivalDraw{seq: seq, value: value}
+}
+
+// visit describes the CmpPtn an execution thread visited, and its class
+type visit struct {
+	cpName string
+	class  string
+}
+
+// visited holds the CmpPtn marked as visited by every execution thread that has not ended
+var visited map[threadKey]visit = make(map[threadKey]visit)
+
+// Samples holds every completed execution thread, in the order they completed
+var Samples []Sample = []Sample{}
+
+// startThread notes the time an execution thread started, and the measure Func and the message that started it.
+// The devices of its round trip are those the Func's cfg lists
+func startThread(group string, srtCPID, execID int, now float64, cpfi *pces.CmpPtnFuncInst, msg *pces.CmpPtnMsg) {
+	key := threadKey{srtCPID: srtCPID, execID: execID}
+	draw, present := threadDraws[key]
+	if present {
+		delete(threadDraws, key)
+	} else if draw, present = nextDraws[srtCPID]; present {
+		delete(nextDraws, srtCPID)
+	} else {
+		draw = noDraw
+	}
+	thread := started{group: group, start: now, draw: draw, devs: []string{}, cpfi: cpfi}
+	if cpfi != nil {
+		thread.devs = cpfi.Cfg.(*MeasureCfg).Path
+		held := *msg
+		thread.msg = &held
+	}
+	startTimes[key] = thread
+}
+
+// markThread notes the CmpPtn an execution thread visited, and its class.  devs, when not empty,
+// lists the devices of the thread's round trip, known more closely than where it started
+func markThread(srtCPID, execID int, cpName, class string, devs []string) {
+	key := threadKey{srtCPID: srtCPID, execID: execID}
+	visited[key] = visit{cpName: cpName, class: class}
+	thread, present := startTimes[key]
+	if present && len(devs) > 0 {
+		thread.devs = devs
+		startTimes[key] = thread
+	}
+}
+
+// endThread saves the sample of an execution thread in a measurement group.
+// A thread whose start was not seen is ignored
+func endThread(group string, srtCPID, execID int, now float64) {
+	key := threadKey{srtCPID: srtCPID, execID: execID}
+	thread, present := startTimes[key]
+	if !present {
+		return
+	}
+	start := thread.start
+	dst := visited[key]
+	delete(startTimes, key)
+	delete(visited, key)
+
+	src := ""
+	cpi, present := pces.CmpPtnInstByID[srtCPID]
+	if present {
+		src = cpi.Name
+	}
+	Samples = append(Samples, Sample{ExecID: execID, Group: group, Src: src, Dst: dst.cpName,
+		Class: dst.class, Start: start, End: now, RTT: now - start, Seq: thread.draw.seq, Draw: thread.draw.value,
+		devs: thread.devs})
+}
+
+// PctValue gives the value of the RTT at a percentile (e.g. 99.9) of a measurement group's samples
+type PctValue struct {
+	Pct   float64 `json:"pct" yaml:"pct"`
+	Value float64 `json:"value" yaml:"value"`
+}
+
+// GroupStats holds the statistics of the RTTs (in seconds) gathered by a measurement group,
+// including the values at the percentiles requested when the results were gathered.
+// Deleted gives the number of samples deleted as warm-up, TruncTime the start
+// time of the first sample kept, and Lost the number of round trips lost to outages
+type GroupStats struct {
+	Group       string     `json:"group" yaml:"group"`
+	Deleted     int        `json:"deleted" yaml:"deleted"`
+	TruncTime   float64    `json:"trunctime" yaml:"trunctime"`
+	Lost        int        `json:"lost,omitempty" yaml:"lost,omitempty"`
+	Samples     int        `json:"samples" yaml:"samples"`
+	Min         float64    `json:"min" yaml:"min"`
+	Q25         float64    `json:"q25" yaml:"q25"`
+	Mean        float64    `json:"mean" yaml:"mean"`
+	Median      float64    `json:"median" yaml:"median"`
+	Q75         float64    `json:"q75" yaml:"q75"`
+	Max         float64    `json:"max" yaml:"max"`
+	Percentiles []PctValue `json:"percentiles,omitempty" yaml:"percentiles,omitempty"`
+}
+
+// Results is what a simulation run reports: the time the run was to stop, the simulation
+// time when it did stop, how the warm-up samples were deleted, the statistics of every measurement group,
+// and, when devices were taken out of service, what that cost
+type Results struct {
+	StopTime     float64       `json:"stoptime" yaml:"stoptime"`
+	EndTime      float64       `json:"endtime" yaml:"endtime"`
+	Truncation   string        `json:"truncation" yaml:"truncation"`
+	Groups       []GroupStats  `json:"groups" yaml:"groups"`
+	Availability *Availability `json:"availability,omitempty" yaml:"availability,omitempty"`
+}
+
+// percentile returns the p-th percentile (0 <= p <= 100) of the sorted list of values,
+// interpolating linearly between the values that straddle it
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0.0
+	}
+	pos := (p / 100.0) * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+// ParsePercentiles transforms a comma separated list of percentiles, e.g. "90,95,99,99.9",
+// into a list of values
+func ParsePercentiles(pctStr string) ([]float64, error) {
+	pcts := []float64{}
+	for _, field := range strings.Split(pctStr, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		pct, err := strconv.ParseFloat(field, 64)
+		if err != nil || pct < 0.0 || pct > 100.0 {
+			return nil, fmt.Errorf("percentile %s is not a number between 0 and 100", field)
+		}
+		pcts = append(pcts, pct)
+	}
+	return pcts, nil
+}
+
+// pctLabel names a percentile, e.g. "p99.9"
+func pctLabel(pct float64) string {
+	return "p" + strconv.FormatFloat(pct, 'f', -1, 64)
+}
+
+// computeStats computes the statistics of the RTTs of one measurement group
+func computeStats(group string, rtts []float64, pcts []float64) GroupStats {
+	gs := GroupStats{Group: group, Samples: len(rtts)}
+	if len(rtts) == 0 {
+		return gs
+	}
+
+	sorted := make([]float64, len(rtts))
+	copy(sorted, rtts)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+
+	gs.Min = sorted[0]
+	gs.Q25 = percentile(sorted, 25.0)
+	gs.Mean = sum / float64(len(sorted))
+	gs.Median = percentile(sorted, 50.0)
+	gs.Q75 = percentile(sorted, 75.0)
+	gs.Max = sorted[len(sorted)-1]
+
+	for _, pct := range pcts {
+		gs.Percentiles = append(gs.Percentiles, PctValue{Pct: pct, Value: percentile(sorted, pct)})
+	}
+	return gs
+}
+
+// GatherResults computes the statistics of every measurement group, ordered by group name,
+// reporting the RTT at each of the percentiles in pcts.  The warm-up samples of each
+// group are deleted as tr describes before the statistics are computed.  When the samples of a group
+// visited CmpPtns of named classes, the statistics of each class are also reported, as a group
+// named by the group and the class, e.g. 'encryptPerf-SSL/laptop'.  A class's samples are
+// those of the group's kept samples that visited the class, so the group's truncation applies
+func GatherResults(stopTime, endTime float64, pcts []float64, tr Truncation) *Results {
+	rs := new(Results)
+	rs.StopTime = stopTime
+	rs.EndTime = endTime
+	rs.Truncation = tr.Method()
+	rs.Groups = []GroupStats{}
+
+	groupSamples := make(map[string][]Sample)
+	for _, sample := range Samples {
+		groupSamples[sample.Group] = append(groupSamples[sample.Group], sample)
+	}
+
+	groups := []string{}
+	for group := range groupSamples {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		kept, deleted, truncTime := truncate(groupSamples[group], tr)
+		rtts := make([]float64, len(kept))
+		for idx, sample := range kept {
+			rtts[idx] = sample.RTT
+		}
+		gs := computeStats(group, rtts, pcts)
+		gs.Deleted = deleted
+		gs.TruncTime = truncTime
+		rs.Groups = append(rs.Groups, gs)
+
+		rs.Groups = append(rs.Groups, classStats(group, groupSamples[group], kept, truncTime, pcts)...)
+	}
+	return rs
+}
+
+// AddWindows adds to the results the statistics of every measurement group's samples in each
+// window of window seconds, from time zero to the end of the run, so that how the RTTs vary over the
+// run can be seen.  A sample falls in the window holding its start time.  The window of a group starting
+// at time t is reported as a group named by the group and t, e.g. 'encryptPerf-SSL@30'.  Every sample is
+// counted, including those deleted as warm-up from the group's own statistics
+func (rs *Results) AddWindows(window float64, pcts []float64) {
+	if window <= 0.0 {
+		return
+	}
+	windows := int(math.Ceil(rs.EndTime / window))
+	if windows < 1 {
+		windows = 1
+	}
+
+	groupRTTs := make(map[string][][]float64)
+	for _, sample := range Samples {
+		if _, present := groupRTTs[sample.Group]; !present {
+			groupRTTs[sample.Group] = make([][]float64, windows)
+		}
+		wdx := int(sample.Start / window)
+		if wdx >= windows {
+			wdx = windows - 1
+		}
+		groupRTTs[sample.Group][wdx] = append(groupRTTs[sample.Group][wdx], sample.RTT)
+	}
+
+	groups := []string{}
+	for group := range groupRTTs {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		for wdx, rtts := range groupRTTs[group] {
+			start := strconv.FormatFloat(float64(wdx)*window, 'g', -1, 64)
+			rs.Groups = append(rs.Groups, computeStats(group+"@"+start, rtts, pcts))
+		}
+	}
+}
+
+// classStats computes the statistics of the kept samples of a measurement group that visited
+// each named class, ordered by class name.  samples holds all of the group's samples,
+// from which the number of each class's samples deleted as warm-up is found
+func classStats(group string, samples, kept []Sample, truncTime float64, pcts []float64) []GroupStats {
+	classRTTs := make(map[string][]float64)
+	for _, sample := range kept {
+		if len(sample.Class) > 0 {
+			classRTTs[sample.Class] = append(classRTTs[sample.Class], sample.RTT)
+		}
+	}
+
+	classSamples := make(map[string]int)
+	for _, sample := range samples {
+		classSamples[sample.Class] += 1
+	}
+
+	classes := []string{}
+	for class := range classRTTs {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	stats := []GroupStats{}
+	for _, class := range classes {
+		gs := computeStats(group+"/"+class, classRTTs[class], pcts)
+		gs.Deleted = classSamples[class] - len(classRTTs[class])
+		gs.TruncTime = truncTime
+		stats = append(stats, gs)
+	}
+	return stats
+}
+
+// WriteToFile stores the Results struct to the file whose name is given.
+// A file whose name ends in '.csv' gets a csv form with one line per measurement group,
+// any other gets json.  Only the json form holds the availability of the service and of each device
+func (rs *Results) WriteToFile(filename string) error {
+	if filepath.Ext(filename) == ".csv" {
+		return rs.writeCSV(filename)
+	}
+
+	bytes, merr := json.MarshalIndent(*rs, "", "\t")
+	if merr != nil {
+		return merr
+	}
+	return os.WriteFile(filename, bytes, 0644)
+}
+
+// writeCSV writes the results in csv form, repeating the stop and end times on each line
+func (rs *Results) writeCSV(filename string) error {
+	f, cerr := os.Create(filename)
+	if cerr != nil {
+		return cerr
+	}
+	defer f.Close()
+
+	ftoa := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	// every group reports the same percentiles, each getting a column after the fixed ones
+	heading := []string{"group", "samples", "min", "q25", "mean", "median", "q75", "max", "stoptime", "endtime",
+		"truncation", "deleted", "trunctime", "lost"}
+	if len(rs.Groups) > 0 {
+		for _, pv := range rs.Groups[0].Percentiles {
+			heading = append(heading, pctLabel(pv.Pct))
+		}
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(heading)
+	for _, gs := range rs.Groups {
+		line := []string{gs.Group, strconv.Itoa(gs.Samples), ftoa(gs.Min), ftoa(gs.Q25), ftoa(gs.Mean),
+			ftoa(gs.Median), ftoa(gs.Q75), ftoa(gs.Max), ftoa(rs.StopTime), ftoa(rs.EndTime),
+			rs.Truncation, strconv.Itoa(gs.Deleted), ftoa(gs.TruncTime), strconv.Itoa(gs.Lost)}
+		for _, pv := range gs.Percentiles {
+			line = append(line, ftoa(pv.Value))
+		}
+		w.Write(line)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing results to %s: %w", filename, err)
+	}
+	return nil
+}
+
+// WriteSamples writes every completed execution thread to the file whose name is given,
+// csv with one line per sample if the name ends in '.csv', and json otherwise
+func WriteSamples(filename string) error {
+	if filepath.Ext(filename) != ".csv" {
+		bytes, merr := json.MarshalIndent(Samples, "", "\t")
+		if merr != nil {
+			return merr
+		}
+		return os.WriteFile(filename, bytes, 0644)
+	}
+
+	f, cerr := os.Create(filename)
+	if cerr != nil {
+		return cerr
+	}
+	defer f.Close()
+
+	ftoa := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"execid", "group", "src", "dst", "class", "start", "end", "rtt", "seq", "draw"})
+	for _, sample := range Samples {
+		w.Write([]string{strconv.Itoa(sample.ExecID), sample.Group, sample.Src, sample.Dst, sample.Class,
+			ftoa(sample.Start), ftoa(sample.End), ftoa(sample.RTT), strconv.Itoa(sample.Seq), ftoa(sample.Draw)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing samples to %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ReadSamples recovers the json form of the samples written by WriteSamples
+func ReadSamples(filename string) ([]Sample, error) {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	samples := []Sample{}
+	err = json.Unmarshal(dict, &samples)
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
@@ -0,0 +1,119 @@
+package measure
+
+import (
+	"math"
+	"testing"
+)
+
+// TestPercentile checks percentile against values interpolated by hand
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", []float64{}, 50.0, 0.0},
+		{"single", []float64{7.0}, 50.0, 7.0},
+		{"least", []float64{1.0, 2.0, 3.0, 4.0}, 0.0, 1.0},
+		{"greatest", []float64{1.0, 2.0, 3.0, 4.0}, 100.0, 4.0},
+		{"median between two", []float64{1.0, 2.0, 3.0, 4.0}, 50.0, 2.5},
+		{"median on one", []float64{1.0, 2.0, 3.0, 4.0, 5.0}, 50.0, 3.0},
+		{"first quartile", []float64{1.0, 2.0, 3.0, 4.0}, 25.0, 1.75},
+		{"90th", []float64{1.0, 2.0, 3.0, 4.0}, 90.0, 3.7},
+		{"99th of uneven gaps", []float64{0.0, 10.0, 100.0}, 99.0, 98.2},
+	}
+	for _, test := range tests {
+		got := percentile(test.sorted, test.p)
+		if math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%s: percentile(%v, %g) = %g, want %g", test.name, test.sorted, test.p, got, test.want)
+		}
+	}
+}
+
+// TestParsePercentiles checks the parsing of lists of percentiles, and the rejection of those out of range
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		pctStr string
+		want   []float64
+		bad    bool
+	}{
+		{"90,95,99,99.9", []float64{90.0, 95.0, 99.0, 99.9}, false},
+		{" 50 , ,75", []float64{50.0, 75.0}, false},
+		{"", []float64{}, false},
+		{"101", nil, true},
+		{"-1", nil, true},
+		{"p99", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParsePercentiles(test.pctStr)
+		if test.bad {
+			if err == nil {
+				t.Errorf("ParsePercentiles(%q) = %v, want an error", test.pctStr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePercentiles(%q) gives error %v", test.pctStr, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("ParsePercentiles(%q) = %v, want %v", test.pctStr, got, test.want)
+			continue
+		}
+		for idx := range got {
+			if got[idx] != test.want[idx] {
+				t.Errorf("ParsePercentiles(%q) = %v, want %v", test.pctStr, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+// TestComputeStats checks the statistics of a group against those computed by hand
+func TestComputeStats(t *testing.T) {
+	gs := computeStats("g", []float64{4.0, 1.0, 3.0, 2.0}, []float64{90.0})
+	want := GroupStats{Group: "g", Samples: 4, Min: 1.0, Q25: 1.75, Mean: 2.5, Median: 2.5, Q75: 3.25, Max: 4.0}
+	if gs.Group != want.Group || gs.Samples != want.Samples || gs.Min != want.Min || gs.Q25 != want.Q25 ||
+		gs.Mean != want.Mean || gs.Median != want.Median || gs.Q75 != want.Q75 || gs.Max != want.Max {
+		t.Errorf("computeStats = %+v, want %+v", gs, want)
+	}
+	if len(gs.Percentiles) != 1 || math.Abs(gs.Percentiles[0].Value-3.7) > 1e-12 {
+		t.Errorf("computeStats gives percentiles %+v, want the 90th at 3.7", gs.Percentiles)
+	}
+}
+
+// TestThreadDraws checks that a sample carries the arrival draw made for its thread, or failing that
+// the one made for the next thread of its source, and that a draw is used by one thread only
+func TestThreadDraws(t *testing.T) {
+	saved := Samples
+	defer func() { Samples = saved }()
+	Samples = []Sample{}
+
+	// thread 1 of CmpPtn 7 has a gap drawn for it, and thread 2 starts after a think time
+	drawThread(7, 1, 4, 0.25)
+	drawNext(7, 5, 0.5)
+	drawThread(7, 9, 6, 0.75)
+	dropThread(7, 9)
+	for execID := 1; execID <= 3; execID++ {
+		startThread("g", 7, execID, float64(execID), nil, nil)
+		endThread("g", 7, execID, float64(execID)+1.0)
+	}
+
+	want := []struct {
+		seq  int
+		draw float64
+	}{{4, 0.25}, {5, 0.5}, {-1, 0.0}}
+	if len(Samples) != len(want) {
+		t.Fatalf("%d samples gathered, want %d", len(Samples), len(want))
+	}
+	for idx, sample := range Samples {
+		if sample.Seq != want[idx].seq || sample.Draw != want[idx].draw {
+			t.Errorf("sample %d started after draw %d of %g, want %d of %g", idx, sample.Seq, sample.Draw,
+				want[idx].seq, want[idx].draw)
+		}
+	}
+	if len(threadDraws) != 0 || len(nextDraws) != 0 {
+		t.Errorf("draws %v and %v are left once the threads have started", threadDraws, nextDraws)
+	}
+}
@@ -0,0 +1,64 @@
+package measure
+
+// code to give the Funcs that shape the arrival of packets (pace, think, and profile) rng streams
+// that are the same whatever architecture is built around them.  The streams of the rngstream package
+// are handed out in the order the Funcs are initialized, and an architecture with more Funcs than
+// another (an SSL server's, say) shifts the streams of the Funcs initialized after them, so that the
+// same -rngseed would give two architectures being compared different arrivals.  A Func whose cfg
+// gives it an arrival stream draws instead from a family of streams of its own, the k-th arrival
+// stream being the k-th stream of that family, whatever else has been created
+
+import (
+	"github.com/iti/rngstream"
+)
+
+// the moduli of the two components of the MRG32k3a generator behind rngstream
+const rngM1 uint64 = 4294967087
+const rngM2 uint64 = 4294944443
+
+// DefaultArrivalSeed is the master seed of the arrival streams when none is given,
+// the seed the rngstream package gives every word of its default state
+const DefaultArrivalSeed uint64 = 12345
+
+// arrivalSeed is the seed of the first arrival stream, and arrivalSubstream
+// the substream every arrival stream is moved on to
+var arrivalSeed []uint64 = arrivalSeedFrom(DefaultArrivalSeed)
+var arrivalSubstream int = 0
+
+// arrivalSeedFrom gives the seed of the first arrival stream for the master seed seed.  Where
+// rngstream.SetRngStreamMasterSeed counts up from seed, it counts down from the moduli, so
+// that the family starts apart from the package's streams.  seed is less than rngM2-6
+func arrivalSeedFrom(seed uint64) []uint64 {
+	return []uint64{rngM1 - 1 - seed, rngM1 - 2 - seed, rngM1 - 3 - seed,
+		rngM2 - 4 - seed, rngM2 - 5 - seed, rngM2 - 6 - seed}
+}
+
+// SeedArrivalStreams seeds the arrival streams from the master seed seed, every stream starting
+// at the start of its substream-th substream.  It must be called before any Func is initialized
+func SeedArrivalStreams(seed uint64, substream int) {
+	arrivalSeed = arrivalSeedFrom(seed)
+	arrivalSubstream = substream
+}
+
+// arrivalStream creates the index-th arrival stream (counting from 1), named name, or the next
+// of the package's streams when index is 0
+func arrivalStream(name string, index int) *rngstream.RngStream {
+	if index == 0 {
+		return rngstream.New(name)
+	}
+
+	// note where the package's streams are, and put them back once the family's have been counted out.
+	// Only the stream created last is kept
+	next := rngstream.New("").GetState()
+	rngstream.SetPackageSeed(arrivalSeed)
+	rng := rngstream.New(name)
+	for sdx := 1; sdx < index; sdx++ {
+		rng = rngstream.New(name)
+	}
+	rngstream.SetPackageSeed(next)
+
+	for sdx := 0; sdx < arrivalSubstream; sdx++ {
+		rng.ResetNextSubstream()
+	}
+	return rng
+}
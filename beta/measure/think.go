@@ -0,0 +1,250 @@
+package measure
+
+// the think class models the time a user takes between receiving a response and issuing the next
+// request.  A think Func receiving a message with method code 'thinkOp' holds it for a time drawn
+// from the distribution its cfg describes, and then passes it on, to the Func the cfg names for that
+// method code.  The hold uses no CPU, as the time is the user's rather than the device's.
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/pces"
+	"github.com/iti/rngstream"
+	"gopkg.in/yaml.v3"
+	"math"
+)
+
+// like every Func class, get the think class recognized within pces
+// when the file is loaded, by any application that imports it
+var thkcfgVar *ThinkCfg = ClassCreateThinkCfg()
+var thkcfgLoaded bool = pces.RegisterFuncClass(thkcfgVar)
+
+// ThinkCfg is the cfg put into the cpInit input file for a think Func.  Dist names the
+// distribution of the think time, one of const, exp, uniform, lognormal, pareto, or empirical,
+// and Params are its parameters, in seconds: the mean of const and exp, the least and greatest
+// times of uniform, the mean and standard deviation of the log of the time of lognormal, the shape
+// and scale of pareto, and the times drawn from by empirical.  Route, TgtLabel, and TgtMC are
+// indexed by method code, as they are for the measure class.  Stream is the arrival stream
+// the think times are drawn from (see stream.go), 0 for one of the package's streams
+type ThinkCfg struct {
+	Dist     string            `yaml:"dist" json:"dist"`
+	Params   []float64         `yaml:"params" json:"params"`
+	Route    map[string]string `yaml:"route" json:"route"`
+	TgtLabel map[string]string `yaml:"tgtlabel" json:"tgtlabel"`
+	TgtMC    map[string]string `yaml:"tgtmc" json:"tgtmc"`
+	Stream   int               `yaml:"stream" json:"stream"`
+	Trace    bool              `yaml:"trace" json:"trace"`
+}
+
+// distParams gives the number of parameters of each distribution a think time, or a time between
+// failures or to repair, is drawn from, -1 meaning at least one
+var distParams map[string]int = map[string]int{"const": 1, "exp": 1, "uniform": 2, "lognormal": 2,
+	"pareto": 2, "empirical": -1}
+
+// checkDist checks that the distribution named by dist is known and has the parameters it needs,
+// none negative where a time is expected
+func checkDist(dist string, params []float64) error {
+	nParams, present := distParams[dist]
+	if !present {
+		return fmt.Errorf("has unknown distribution %s", dist)
+	}
+	if (nParams < 0 && len(params) == 0) || (nParams > 0 && len(params) != nParams) {
+		return fmt.Errorf("has %d parameters for distribution %s", len(params), dist)
+	}
+	if dist != "lognormal" {
+		for _, param := range params {
+			if param < 0.0 {
+				return fmt.Errorf("has a negative parameter")
+			}
+		}
+	}
+	return nil
+}
+
+// sampleDist draws a time (in seconds) from the distribution named by dist, with parameters params,
+// using the rng stream
+func sampleDist(dist string, params []float64, rng *rngstream.RngStream) float64 {
+	switch dist {
+	case "exp":
+		return -params[0] * math.Log(1.0-rng.RandU01())
+	case "uniform":
+		return params[0] + (params[1]-params[0])*rng.RandU01()
+	case "lognormal":
+		// Box-Muller gives the standard normal deviate
+		normal := math.Sqrt(-2.0*math.Log(1.0-rng.RandU01())) * math.Cos(2.0*math.Pi*rng.RandU01())
+		return math.Exp(params[0] + params[1]*normal)
+	case "pareto":
+		return params[1] / math.Pow(1.0-rng.RandU01(), 1.0/params[0])
+	case "empirical":
+		return params[rng.RandInt(0, len(params)-1)]
+	}
+	return params[0]
+}
+
+// CreateThinkCfg is a constructor.  The think time has the distribution named by dist, with parameters params
+func CreateThinkCfg(dist string, params []float64) *ThinkCfg {
+	thkcfg := new(ThinkCfg)
+	thkcfg.Dist = dist
+	thkcfg.Params = params
+	thkcfg.Route = make(map[string]string)
+	thkcfg.TgtLabel = make(map[string]string)
+	thkcfg.TgtMC = make(map[string]string)
+	thkcfg.Stream = 0
+	thkcfg.Trace = false
+	return thkcfg
+}
+
+// AddRoute says that what arrives with method code methodCode is passed on
+// as type msgType, to the Func labeled tgtLabel, with method code tgtMC
+func (thkcfg *ThinkCfg) AddRoute(methodCode, msgType, tgtLabel, tgtMC string) {
+	thkcfg.Route[methodCode] = msgType
+	thkcfg.TgtLabel[methodCode] = tgtLabel
+	thkcfg.TgtMC[methodCode] = tgtMC
+}
+
+// route is required for the router interface
+func (thkcfg *ThinkCfg) route(methodCode string) (string, string, string) {
+	return thkcfg.Route[methodCode], thkcfg.TgtLabel[methodCode], thkcfg.TgtMC[methodCode]
+}
+
+// Sample draws a think time (in seconds) from the distribution, using the rng stream
+func (thkcfg *ThinkCfg) Sample(rng *rngstream.RngStream) float64 {
+	return sampleDist(thkcfg.Dist, thkcfg.Params, rng)
+}
+
+// ThinkState holds the number of messages a think Func has held, and the rng stream it draws from
+type ThinkState struct {
+	calls int
+	rng   *rngstream.RngStream
+}
+
+// createThinkState is a constructor.  The think times are drawn from the arrival stream stream
+func createThinkState(label string, stream int) *ThinkState {
+	thks := new(ThinkState)
+	thks.calls = 0
+	thks.rng = arrivalStream(label, stream)
+	return thks
+}
+
+// ClassCreateThinkCfg is a constructor called just to create an instance,
+// and put reference to think and its methods in the pces data structures
+func ClassCreateThinkCfg() *ThinkCfg {
+	thkcfg := CreateThinkCfg("const", []float64{0.0})
+
+	// put the event handling information into pces.ClassMethods
+	fmap := make(map[string]pces.RespMethod)
+	fmap["thinkOp"] = pces.RespMethod{Start: thinkHold, End: pces.ExitFunc}
+	pces.ClassMethods["think"] = fmap
+
+	return thkcfg
+}
+
+// FuncClassName required for the FuncClassCfg interface
+func (thkcfg *ThinkCfg) FuncClassName() string {
+	return "think"
+}
+
+// CreateCfg required for the FuncClassCfg interface
+func (thkcfg *ThinkCfg) CreateCfg(cfgStr string, useYAML bool) any {
+	thkcfgVarAny, err := thkcfg.Deserialize(cfgStr, useYAML)
+	if err != nil {
+		panic(fmt.Errorf("think.InitCfg sees deserialization error"))
+	}
+	return thkcfgVarAny
+}
+
+// InitCfg required for the FuncClassCfg interface
+func (thkcfg *ThinkCfg) InitCfg(cpfi *pces.CmpPtnFuncInst, cfgStr string, useYAML bool) {
+
+	// Deserialize the configuration for this Func
+	thkcfgVarAny := thkcfg.CreateCfg(cfgStr, useYAML)
+	thkcfgv := thkcfgVarAny.(*ThinkCfg)
+	cpfi.Cfg = thkcfgv
+
+	cpfi.State = createThinkState(cpfi.Label, thkcfgv.Stream)
+	cpfi.Trace = thkcfgv.Trace
+}
+
+// ValidateCfg checks that the Func's distribution is known and has the parameters it needs,
+// none negative where a time is expected, and that the Func has routes, each for a method code
+// the class responds to
+func (thkcfg *ThinkCfg) ValidateCfg(cpfi *pces.CmpPtnFuncInst) error {
+	thkcfgv := cpfi.Cfg.(*ThinkCfg)
+	if err := checkDist(thkcfgv.Dist, thkcfgv.Params); err != nil {
+		return fmt.Errorf("think Func %s %s", cpfi.Label, err.Error())
+	}
+	if len(thkcfgv.TgtLabel) == 0 {
+		return fmt.Errorf("think Func %s has no routes", cpfi.Label)
+	}
+	for methodCode := range thkcfgv.TgtLabel {
+		_, present := pces.ClassMethods["think"][methodCode]
+		if !present {
+			return fmt.Errorf("think Func %s has route for unknown method code %s", cpfi.Label, methodCode)
+		}
+	}
+	return nil
+}
+
+// Serialize transforms the think cfg into string form for
+// inclusion through a file
+func (thkcfg *ThinkCfg) Serialize(useYAML bool) (string, error) {
+	var bytes []byte
+	var merr error
+
+	if useYAML {
+		bytes, merr = yaml.Marshal(*thkcfg)
+	} else {
+		bytes, merr = json.Marshal(*thkcfg)
+	}
+
+	if merr != nil {
+		return "", merr
+	}
+
+	return string(bytes[:]), nil
+}
+
+// Deserialize recovers a serialized representation of a think cfg structure
+func (thkcfg *ThinkCfg) Deserialize(fss string, useYAML bool) (any, error) {
+	// turn the string into a slice of bytes
+	var err error
+	fsb := []byte(fss)
+
+	example := CreateThinkCfg("const", []float64{0.0})
+
+	// Select whether we read in json or yaml
+	if useYAML {
+		err = yaml.Unmarshal(fsb, example)
+	} else {
+		err = json.Unmarshal(fsb, example)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return example, nil
+}
+
+// now include the functions whose executions are triggered by messages to the think function,
+// passing through pces.EnterFunc.
+//
+// thinkHold passes the message on once a think time drawn from the distribution has passed,
+// noting the draw as the one the next execution thread of the message's source starts after
+func thinkHold(evtMgr *evtm.EventManager, cpfi *pces.CmpPtnFuncInst, methodCode string, msg *pces.CmpPtnMsg) {
+	thks := cpfi.State.(*ThinkState)
+	thks.calls += 1
+	thkcfg := cpfi.Cfg.(*ThinkCfg)
+
+	msgType, tgtLabel, tgtMC := thkcfg.route(methodCode)
+
+	// update the message to reflect the next station, in the same CmpPtn
+	pces.UpdateMsg(msg, cpfi.CPID, tgtLabel, msgType, tgtMC)
+
+	// put the message where pces.ExitFunc will be looking for it, once the think time has passed
+	cpfi.AddResponse(msg.ExecID, []*pces.CmpPtnMsg{msg})
+	think := thkcfg.Sample(thks.rng)
+	drawNext(msg.CmpHdr.SrtCPID, thks.calls-1, think)
+	evtMgr.Schedule(cpfi, msg, pces.ExitFunc, vrtime.SecondsToTime(think))
+}
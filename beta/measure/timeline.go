@@ -0,0 +1,282 @@
+package measure
+
+// timeline.go holds a scenario timeline, events that change the network while the simulation runs.
+// A timeline file has an event per line, starting with the virtual time it happens at, e.g.
+//
+//	t=5s set Interface[devname=pvtRtr].bandwidth=10
+//	t=8s down eudSwitch-0
+//	t=12s restore eudSwitch-0
+//
+// 'set' gives a new value to a parameter of the interfaces of a device (devname=) or of an interface
+// (name=), or of a network (Network[name=...]), using the object and parameter names of exp.yaml.
+// 'down' takes a device out of service and 'restore' (or 'up') puts it back, as outage.go describes.  Times may carry a
+// unit, s, ms, us, or ns, and are in milliseconds without one, as the times of bld.go's distributions are.
+// Empty lines and lines starting with '#' are skipped
+
+import (
+	"fmt"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+	"github.com/iti/mrnes"
+	"github.com/iti/pces"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TimelineEvent is an event of a scenario timeline.  Action is "set", "down", or "restore".
+// A set event gives Value to parameter Param of the objects of type Object ("Interface" or "Network")
+// whose attribute AttrbName has value AttrbValue.  The down and restore events name the device Dev.
+// Line holds the line the event was read from
+type TimelineEvent struct {
+	Time       float64
+	Action     string
+	Object     string
+	AttrbName  string
+	AttrbValue string
+	Param      string
+	Value      float64
+	Dev        string
+	Line       string
+}
+
+// Timeline is a scenario timeline, its events in order of time
+type Timeline struct {
+	Events []TimelineEvent
+}
+
+// timelineParams lists the parameters of each type of object a set event can change
+var timelineParams map[string][]string = map[string][]string{"Interface": []string{"bandwidth", "latency", "delay"},
+	"Network": []string{"bandwidth", "latency"}}
+
+// timelineUnits gives the number of seconds in each unit of time
+var timelineUnits map[string]float64 = map[string]float64{"s": 1.0, "ms": 1e-3, "us": 1e-6, "ns": 1e-9}
+
+// parseTimelineTime transforms a time, e.g. "5s" or "250ms", into seconds.  A time without a unit is in milliseconds
+func parseTimelineTime(str string) (float64, error) {
+	scale := timelineUnits["ms"]
+
+	// "s" is checked last, as it ends the other units
+	for _, unit := range []string{"ms", "us", "ns", "s"} {
+		if strings.HasSuffix(str, unit) {
+			scale = timelineUnits[unit]
+			str = strings.TrimSuffix(str, unit)
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0.0 {
+		return 0.0, fmt.Errorf("%s is not a time", str)
+	}
+	return value * scale, nil
+}
+
+// parseTimelineEvent transforms a line of a timeline file into an event
+func parseTimelineEvent(line string) (TimelineEvent, error) {
+	te := TimelineEvent{Line: line}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "t=") {
+		return te, fmt.Errorf("timeline event '%s' does not start with t=<time>", line)
+	}
+
+	var err error
+	te.Time, err = parseTimelineTime(strings.TrimPrefix(fields[0], "t="))
+	if err != nil {
+		return te, fmt.Errorf("timeline event '%s': %s", line, err.Error())
+	}
+
+	// 'take <dev> down' is another way of writing 'down <dev>'
+	action := fields[1:]
+	if len(action) == 3 && action[0] == "take" && action[2] == "down" {
+		action = []string{"down", action[1]}
+	}
+
+	switch {
+	case len(action) == 2 && action[0] == "down":
+		te.Action, te.Dev = "down", action[1]
+	case len(action) == 2 && (action[0] == "restore" || action[0] == "up"):
+		te.Action, te.Dev = "restore", action[1]
+	case len(action) == 2 && action[0] == "set":
+		te.Action = "set"
+		err = te.parseSet(action[1])
+	default:
+		err = fmt.Errorf("timeline event '%s' is not a set, down, or restore", line)
+	}
+	return te, err
+}
+
+// parseSet fills in the event from the assignment of a set event, e.g. Interface[devname=pvtRtr].bandwidth=10
+func (te *TimelineEvent) parseSet(assign string) error {
+	open := strings.Index(assign, "[")
+	shut := strings.Index(assign, "]")
+	eq := strings.LastIndex(assign, "=")
+	if open < 1 || shut < open || eq < shut || !strings.HasPrefix(assign[shut+1:], ".") {
+		return fmt.Errorf("timeline event '%s' does not set Object[attribute=value].param=value", te.Line)
+	}
+	te.Object = assign[:open]
+	te.Param = assign[shut+2 : eq]
+
+	attrb := strings.SplitN(assign[open+1:shut], "=", 2)
+	if len(attrb) != 2 || (attrb[0] != "name" && attrb[0] != "devname") || (te.Object == "Network" && attrb[0] != "name") {
+		return fmt.Errorf("timeline event '%s' selects by other than devname or name", te.Line)
+	}
+	te.AttrbName, te.AttrbValue = attrb[0], attrb[1]
+
+	params, present := timelineParams[te.Object]
+	if !present {
+		return fmt.Errorf("timeline event '%s' sets an object other than Interface or Network", te.Line)
+	}
+	known := false
+	for _, param := range params {
+		known = known || param == te.Param
+	}
+	if !known {
+		return fmt.Errorf("timeline event '%s' sets %s, which is not one of %s", te.Line, te.Param, strings.Join(params, ", "))
+	}
+
+	var err error
+	te.Value, err = strconv.ParseFloat(assign[eq+1:], 64)
+	if err != nil || te.Value < 0.0 || (te.Param == "bandwidth" && te.Value == 0.0) {
+		return fmt.Errorf("timeline event '%s' sets %s to a value out of range", te.Line, te.Param)
+	}
+	return nil
+}
+
+// ReadTimeline reads a scenario timeline from the file whose name is given, returning
+// every problem found with its lines.  The events are put in order of time, events at
+// the same time keeping the order of their lines
+func ReadTimeline(filename string) (*Timeline, error) {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tl := new(Timeline)
+	tl.Events = []TimelineEvent{}
+
+	errs := []error{}
+	for _, line := range strings.Split(string(dict), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		te, terr := parseTimelineEvent(line)
+		if terr != nil {
+			errs = append(errs, terr)
+			continue
+		}
+		tl.Events = append(tl.Events, te)
+	}
+	sort.SliceStable(tl.Events, func(i, j int) bool { return tl.Events[i].Time < tl.Events[j].Time })
+	return tl, pces.ReportErrs(errs)
+}
+
+// Validate checks that every device, interface, and network the events name is in the network
+// that has been built, and that a device is only taken down when up and restored when down,
+// returning every problem found
+func (tl *Timeline) Validate() error {
+	errs := []error{}
+	down := make(map[string]bool)
+	for _, te := range tl.Events {
+		switch te.Action {
+		case "down", "restore":
+			if _, present := mrnes.TopoDevByName[te.Dev]; !present {
+				errs = append(errs, fmt.Errorf("timeline event '%s' names unknown device %s", te.Line, te.Dev))
+			} else if down[te.Dev] == (te.Action == "down") {
+				errs = append(errs, fmt.Errorf("timeline event '%s' finds device %s already %s", te.Line, te.Dev,
+					map[bool]string{true: "down", false: "up"}[down[te.Dev]]))
+			}
+			down[te.Dev] = te.Action == "down"
+		case "set":
+			if te.Object == "Network" {
+				if _, present := mrnes.NetworkByName[te.AttrbValue]; !present {
+					errs = append(errs, fmt.Errorf("timeline event '%s' names unknown network %s", te.Line, te.AttrbValue))
+				}
+			} else if len(tl.intrfcsOf(te)) == 0 {
+				errs = append(errs, fmt.Errorf("timeline event '%s' selects no interface", te.Line))
+			}
+		}
+	}
+	return pces.ReportErrs(errs)
+}
+
+// intrfcKey names an interface of a device
+func intrfcKey(devName string, idx int) string {
+	return devName + "#" + strconv.Itoa(idx)
+}
+
+// intrfcsOf gives the keys of the interfaces a set event on interfaces selects,
+// all those of the device named by devname, or those named by name
+func (tl *Timeline) intrfcsOf(te TimelineEvent) []string {
+	keys := []string{}
+	for devName, dev := range mrnes.TopoDevByName {
+		for idx, intrfc := range dev.DevIntrfcs() {
+			if (te.AttrbName == "devname" && devName == te.AttrbValue) || (te.AttrbName == "name" && intrfc.Name == te.AttrbValue) {
+				keys = append(keys, intrfcKey(devName, idx))
+			}
+		}
+	}
+	return keys
+}
+
+// Schedule schedules every event of the timeline at its time.  The network must have been built
+func (tl *Timeline) Schedule(evtMgr *evtm.EventManager) {
+	for edx := range tl.Events {
+		evtMgr.Schedule(tl, &tl.Events[edx], applyTimelineEvent, vrtime.SecondsToTime(tl.Events[edx].Time))
+	}
+}
+
+// applyTimelineEvent makes the change the event given as data describes to the network
+func applyTimelineEvent(evtMgr *evtm.EventManager, context any, data any) any {
+	tl := context.(*Timeline)
+	te := data.(*TimelineEvent)
+
+	switch te.Action {
+	case "down":
+		takeDown(te.Dev, "timeline", evtMgr.CurrentSeconds())
+	case "restore":
+		restore(evtMgr, te.Dev, "timeline")
+	case "set":
+		if te.Object == "Network" {
+			net := mrnes.NetworkByName[te.AttrbValue]
+			if te.Param == "bandwidth" {
+				net.NetState.Bndwdth = te.Value
+			} else {
+				net.NetState.Latency = te.Value
+			}
+			return nil
+		}
+		tl.setIntrfcs(*te)
+	}
+	return nil
+}
+
+// setIntrfcs gives the value of the set event to the interfaces it selects.  The bandwidth
+// of an interface of a device that is down takes effect when the device is restored
+func (tl *Timeline) setIntrfcs(te TimelineEvent) {
+	selected := make(map[string]bool)
+	for _, key := range tl.intrfcsOf(te) {
+		selected[key] = true
+	}
+
+	for devName, dev := range mrnes.TopoDevByName {
+		for idx, intrfc := range dev.DevIntrfcs() {
+			if !selected[intrfcKey(devName, idx)] {
+				continue
+			}
+			switch te.Param {
+			case "bandwidth":
+				if isDown(devName) {
+					setDownBndwdth(devName, idx, te.Value)
+				} else {
+					intrfc.State.Bndwdth = te.Value
+				}
+			case "latency":
+				intrfc.State.Latency = te.Value
+			case "delay":
+				intrfc.State.Delay = te.Value
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package measure
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseTimelineTime checks the times of timeline events, in seconds, bare times being in milliseconds
+func TestParseTimelineTime(t *testing.T) {
+	tests := []struct {
+		str  string
+		want float64
+		bad  bool
+	}{
+		{"5s", 5.0, false},
+		{"250ms", 0.25, false},
+		{"40us", 4e-5, false},
+		{"300ns", 3e-7, false},
+		{"12", 0.012, false},
+		{"0", 0.0, false},
+		{"-1s", 0.0, true},
+		{"5min", 0.0, true},
+		{"", 0.0, true},
+	}
+	for _, test := range tests {
+		got, err := parseTimelineTime(test.str)
+		if test.bad {
+			if err == nil {
+				t.Errorf("parseTimelineTime(%q) = %g, want an error", test.str, got)
+			}
+			continue
+		}
+		if err != nil || math.Abs(got-test.want) > 1e-15 {
+			t.Errorf("parseTimelineTime(%q) = %g, %v, want %g", test.str, got, err, test.want)
+		}
+	}
+}
+
+// TestParseTimelineEvent checks the events read from the lines of a timeline file
+func TestParseTimelineEvent(t *testing.T) {
+	tests := []struct {
+		line string
+		want TimelineEvent
+	}{
+		{"t=8s down eudSwitch-3", TimelineEvent{Time: 8.0, Action: "down", Dev: "eudSwitch-3"}},
+		{"t=8s take eudSwitch-3 down", TimelineEvent{Time: 8.0, Action: "down", Dev: "eudSwitch-3"}},
+		{"t=12000 restore eudSwitch-3", TimelineEvent{Time: 12.0, Action: "restore", Dev: "eudSwitch-3"}},
+		{"t=12s up eudSwitch-3", TimelineEvent{Time: 12.0, Action: "restore", Dev: "eudSwitch-3"}},
+		{"t=5s set Interface[devname=pvtRtr].bandwidth=10", TimelineEvent{Time: 5.0, Action: "set",
+			Object: "Interface", AttrbName: "devname", AttrbValue: "pvtRtr", Param: "bandwidth", Value: 10.0}},
+		{"t=500ms set Interface[name=pvtRtr-intrfc-1].delay=0.002", TimelineEvent{Time: 0.5, Action: "set",
+			Object: "Interface", AttrbName: "name", AttrbValue: "pvtRtr-intrfc-1", Param: "delay", Value: 0.002}},
+		{"t=1s set Network[name=public].latency=0.01", TimelineEvent{Time: 1.0, Action: "set",
+			Object: "Network", AttrbName: "name", AttrbValue: "public", Param: "latency", Value: 0.01}},
+	}
+	for _, test := range tests {
+		got, err := parseTimelineEvent(test.line)
+		if err != nil {
+			t.Errorf("parseTimelineEvent(%q) gives error %v", test.line, err)
+			continue
+		}
+		test.want.Line = test.line
+		if got != test.want {
+			t.Errorf("parseTimelineEvent(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+// TestParseTimelineEventErrors checks that lines that are malformed, or that set what cannot be set, are rejected
+func TestParseTimelineEventErrors(t *testing.T) {
+	for _, line := range []string{
+		"down eudSwitch-3",
+		"t=8s",
+		"t=-8s down eudSwitch-3",
+		"t=8s halt eudSwitch-3",
+		"t=8s take eudSwitch-3 offline",
+		"t=5s set Interface.bandwidth=10",
+		"t=5s set Interface[devname=pvtRtr]bandwidth=10",
+		"t=5s set Interface[dev=pvtRtr].bandwidth=10",
+		"t=5s set Network[devname=pvtRtr].bandwidth=10",
+		"t=5s set Endpt[name=eud-1].bandwidth=10",
+		"t=5s set Network[name=public].delay=0.01",
+		"t=5s set Interface[devname=pvtRtr].bandwidth=0",
+		"t=5s set Interface[devname=pvtRtr].latency=-1",
+		"t=5s set Interface[devname=pvtRtr].bandwidth=fast",
+	} {
+		if te, err := parseTimelineEvent(line); err == nil {
+			t.Errorf("parseTimelineEvent(%q) = %+v, want an error", line, te)
+		}
+	}
+}
+
+// TestReadTimeline checks that a timeline file is read with its comments skipped and its events put in order of time
+func TestReadTimeline(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.txt")
+	text := "# outages\nt=12s restore eudSwitch-3\n\nt=8s down eudSwitch-3\nt=5s set Network[name=public].latency=0.01\n" +
+		"t=8s down eudSwitch-4\n"
+	if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tl, err := ReadTimeline(filename)
+	if err != nil {
+		t.Fatalf("ReadTimeline gives error %v", err)
+	}
+	want := []string{"set", "down eudSwitch-3", "down eudSwitch-4", "restore eudSwitch-3"}
+	if len(tl.Events) != len(want) {
+		t.Fatalf("ReadTimeline reads %d events, want %d", len(tl.Events), len(want))
+	}
+	for idx, te := range tl.Events {
+		got := te.Action
+		if len(te.Dev) > 0 {
+			got += " " + te.Dev
+		}
+		if got != want[idx] {
+			t.Errorf("event %d read is %s, want %s", idx, got, want[idx])
+		}
+	}
+}
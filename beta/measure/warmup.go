@@ -0,0 +1,119 @@
+package measure
+
+// warmup.go removes the initial transient from the RTT samples of a measurement group
+// before statistics are computed.  Every run starts with empty queues, so the RTTs
+// of the first packets are biased low.  Samples can be deleted by a fixed warm-up time,
+// a fixed number of samples, and/or a point chosen by the MSER-5 rule.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Truncation describes how the initial transient is deleted.  Samples of packets that
+// started before WarmupTime are deleted, then the first WarmupSamples of those remaining,
+// and then, if MSER5 is set, the number chosen by the MSER-5 rule
+type Truncation struct {
+	WarmupTime    float64
+	WarmupSamples int
+	MSER5         bool
+}
+
+// Method describes the truncation in words, for the results
+func (tr Truncation) Method() string {
+	methods := []string{}
+	if tr.WarmupTime > 0.0 {
+		methods = append(methods, fmt.Sprintf("time %g", tr.WarmupTime))
+	}
+	if tr.WarmupSamples > 0 {
+		methods = append(methods, fmt.Sprintf("samples %d", tr.WarmupSamples))
+	}
+	if tr.MSER5 {
+		methods = append(methods, "mser5")
+	}
+	if len(methods) == 0 {
+		return "none"
+	}
+	return strings.Join(methods, ", ")
+}
+
+// mserBatch is the batch size of the MSER-5 rule
+const mserBatch = 5
+
+// mser5 returns the number of leading values of the sequence the MSER-5 rule deletes.
+// The sequence is grouped in batches of 5, and the number d of leading batches deleted is the
+// one minimizing the variance of the mean of the batch means remaining, var/(k-d),
+// for d no larger than half the k batches and leaving at least 2, as the variance of a single batch
+// mean is 0.  A sequence with fewer than 3 batches is therefore not truncated
+func mser5(values []float64) int {
+	k := len(values) / mserBatch
+	if k < 3 {
+		return 0
+	}
+
+	batchMeans := make([]float64, k)
+	for j := 0; j < k; j++ {
+		sum := 0.0
+		for _, value := range values[j*mserBatch : (j+1)*mserBatch] {
+			sum += value
+		}
+		batchMeans[j] = sum / mserBatch
+	}
+
+	bestD := 0
+	bestStat := -1.0
+	for d := 0; d <= k/2 && k-d >= 2; d++ {
+		n := float64(k - d)
+		sum := 0.0
+		for _, z := range batchMeans[d:] {
+			sum += z
+		}
+		mean := sum / n
+
+		sumsq := 0.0
+		for _, z := range batchMeans[d:] {
+			sumsq += (z - mean) * (z - mean)
+		}
+		stat := sumsq / (n * n)
+
+		if bestStat < 0.0 || stat < bestStat {
+			bestStat = stat
+			bestD = d
+		}
+	}
+	return bestD * mserBatch
+}
+
+// truncate returns the samples of a measurement group that remain after the initial transient is
+// deleted, ordered by start time, along with the number deleted and the start time of the
+// first sample kept (the truncation point)
+func truncate(samples []Sample, tr Truncation) ([]Sample, int, float64) {
+	ordered := make([]Sample, len(samples))
+	copy(ordered, samples)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Start < ordered[j].Start })
+
+	first := 0
+	for first < len(ordered) && ordered[first].Start < tr.WarmupTime {
+		first += 1
+	}
+
+	first += tr.WarmupSamples
+	if first > len(ordered) {
+		first = len(ordered)
+	}
+
+	if tr.MSER5 {
+		rtts := make([]float64, len(ordered)-first)
+		for idx, sample := range ordered[first:] {
+			rtts[idx] = sample.RTT
+		}
+		first += mser5(rtts)
+	}
+
+	truncTime := 0.0
+	if first < len(ordered) {
+		truncTime = ordered[first].Start
+	}
+	return ordered[first:], first, truncTime
+}
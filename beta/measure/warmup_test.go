@@ -0,0 +1,98 @@
+package measure
+
+import (
+	"testing"
+)
+
+// batches gives the values of batches of mserBatch values, each batch holding its mean
+func batches(means ...float64) []float64 {
+	values := []float64{}
+	for _, mean := range means {
+		for idx := 0; idx < mserBatch; idx++ {
+			values = append(values, mean)
+		}
+	}
+	return values
+}
+
+// TestMSER5 checks the truncation point MSER-5 chooses against that found by hand, from the
+// statistic sum((z_j - mean)^2)/(k-d)^2 over the batch means z_j kept when d batches are deleted
+func TestMSER5(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   int
+	}{
+		{"too few batches", []float64{100.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0}, 0},
+		{"steady", batches(3.0, 3.0, 3.0, 3.0), 0},
+
+		// d=0 gives 8910/121, d=1 gives 0
+		{"one high batch", batches(100.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0), 5},
+
+		// d=0 gives 2916/100, d=1 gives 320.9/81, d=2 gives 0
+		{"two high batches", batches(50.0, 20.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0), 10},
+
+		// d=0 gives 48/16, d=1 gives 42.67/9, d=2 gives 32/4
+		{"low batch last", batches(9.0, 9.0, 9.0, 1.0), 0},
+
+		// only whole batches count, so the values after the fourth batch are ignored
+		{"partial batch", append(batches(1.0, 1.0, 1.0, 1.0), 500.0, 500.0), 0},
+
+		// the batch means are 3 and 1, and d=1 would leave a single batch, so none is deleted
+		{"mixed batch", []float64{1.0, 2.0, 3.0, 4.0, 5.0, 1.0, 1.0, 1.0, 1.0, 1.0}, 0},
+
+		// the batch means are 3, 1, and 1, so d=0 gives 8/27 and d=1 gives 0, leaving two batches
+		{"mixed batch then steady", []float64{1.0, 2.0, 3.0, 4.0, 5.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0}, 5},
+	}
+	for _, test := range tests {
+		got := mser5(test.values)
+		if got != test.want {
+			t.Errorf("%s: mser5 deletes %d values, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// TestTruncate checks which samples truncate deletes, by start time, by count, and by MSER-5
+func TestTruncate(t *testing.T) {
+	// samples out of order by start time, whose round trip times are 100 for the five that start first
+	samples := []Sample{}
+	for idx := 14; idx >= 0; idx-- {
+		rtt := 1.0
+		if idx < 5 {
+			rtt = 100.0
+		}
+		samples = append(samples, Sample{ExecID: idx, Start: float64(idx), End: float64(idx) + rtt, RTT: rtt})
+	}
+
+	tests := []struct {
+		name      string
+		tr        Truncation
+		deleted   int
+		truncTime float64
+	}{
+		{"nothing", Truncation{}, 0, 0.0},
+		{"by time", Truncation{WarmupTime: 2.5}, 3, 3.0},
+		{"by time then count", Truncation{WarmupTime: 2.5, WarmupSamples: 2}, 5, 5.0},
+		{"everything", Truncation{WarmupSamples: 20}, 15, 0.0},
+
+		// batch means 100, 1, 1: d=0 gives 6534/9 and d=1 gives 0
+		{"mser5", Truncation{MSER5: true}, 5, 5.0},
+
+		// after the first sample there are two whole batches, too few to delete one of
+		{"count then mser5", Truncation{WarmupSamples: 1, MSER5: true}, 1, 1.0},
+	}
+	for _, test := range tests {
+		kept, deleted, truncTime := truncate(samples, test.tr)
+		if deleted != test.deleted || truncTime != test.truncTime || len(kept) != len(samples)-test.deleted {
+			t.Errorf("%s: truncate keeps %d, deletes %d, truncates at %g, want %d deleted at %g",
+				test.name, len(kept), deleted, truncTime, test.deleted, test.truncTime)
+			continue
+		}
+		for idx, sample := range kept {
+			if sample.Start != float64(test.deleted+idx) {
+				t.Errorf("%s: truncate keeps sample starting at %g in place %d", test.name, sample.Start, idx)
+				break
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 module main
 
+replace github.com/iti/measure => ../measure
+
 go 1.22.7
 
 require (
 	github.com/iti/cmdline v0.1.1
+	github.com/iti/measure v0.0.0-00010101000000-000000000000
 	github.com/iti/mrnes v0.0.13
 	github.com/iti/pces v0.0.11
 	github.com/iti/rngstream v0.2.2
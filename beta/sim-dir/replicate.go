@@ -0,0 +1,175 @@
+package main
+
+// replicate.go runs independent replications of a simulation experiment.
+// mrnes and pces build an experiment into package-level state that cannot be
+// rebuilt within one process, so each replication is run by a fresh sim process,
+// with the same arguments as this one except for the rng substream it draws from and the files it writes.
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	"github.com/iti/measure"
+	"github.com/iti/rngstream"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// replFile names the file where replication idx writes output that would
+// otherwise be written to filename, e.g. samples.csv -> samples-rep3.csv
+func replFile(filename string, idx int) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-rep" + strconv.Itoa(idx) + ext
+}
+
+// originalArgs returns the command line arguments this process was given, reading
+// them from the file named by -is when present
+func originalArgs() (string, error) {
+	if len(os.Args) > 2 && os.Args[1] == "-is" {
+		bytes, err := os.ReadFile(os.Args[2])
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	}
+	return strings.Join(os.Args[1:], " "), nil
+}
+
+// noRngSeed stands for the master seed of runs given no -rngseed, whose rng streams start from
+// the default seed of the rngstream package, and whose arrival streams from that of measure
+const noRngSeed int64 = -1
+
+// maxRngSeed bounds the master seed, which with the five integers following it seeds both
+// components of the MRG32k3a generator, and so must be less than the smaller modulus less 6
+const maxRngSeed int64 = 4294944443 - 6
+
+// seedRngStreams sets the package seed of the rng streams, from the master seed unless it is noRngSeed,
+// and then moves it ahead by substream substreams.  Every stream created afterwards starts at the start
+// of its substream-th substream rather than its first, so runs given different substreams draw
+// from disjoint parts of the same streams.  The arrival streams of the Funcs shaping the arrivals
+// are seeded and moved ahead in the same way.  It must be called before any stream is created
+func seedRngStreams(seed int64, substream int) {
+	if seed != noRngSeed {
+		rngstream.SetRngStreamMasterSeed(uint64(seed))
+		measure.SeedArrivalStreams(uint64(seed), substream)
+	} else {
+		measure.SeedArrivalStreams(measure.DefaultArrivalSeed, substream)
+	}
+	if substream == 0 {
+		return
+	}
+
+	// a stream created now starts at the package seed, which it then moves on to the next stream,
+	// so the package seed is put back at the start of the stream's chosen substream
+	rng := rngstream.New("substream")
+	for idx := 0; idx < substream; idx++ {
+		rng.ResetNextSubstream()
+	}
+	rngstream.SetPackageSeed(rng.GetState())
+}
+
+// checkRngFlags checks that -rngseed is a seed the generator accepts and -substream is not negative
+func checkRngFlags(cp *cmdline.CmdParser) {
+	if cp.IsLoaded("rngseed") {
+		seed := cp.GetVar("rngseed").(int64)
+		if seed < 0 || seed >= maxRngSeed {
+			panic(fmt.Errorf("rngseed %d is not between 0 and %d", seed, maxRngSeed-1))
+		}
+	}
+	if cp.IsLoaded("substream") && cp.GetVar("substream").(int) < 0 {
+		panic(fmt.Errorf("substream %d is negative", cp.GetVar("substream").(int)))
+	}
+}
+
+// seedName describes the master seed of a run for the messages sim prints
+func seedName(seed int64) string {
+	if seed == noRngSeed {
+		return "the default rng seed"
+	}
+	return fmt.Sprintf("rng seed %d", seed)
+}
+
+// runReplications runs the number of replications given by -replications, all with the same
+// rng master seed but each drawing from its own substream of every rng stream, and reports the
+// confidence intervals of the statistics they gather
+func runReplications(cp *cmdline.CmdParser) {
+	numRepl := cp.GetVar("replications").(int)
+
+	// replication idx draws from substream idx of the streams of the master seed, or without one,
+	// of the streams a run given no -rngseed draws from
+	seed := noRngSeed
+	if cp.IsLoaded("rngseed") {
+		seed = cp.GetVar("rngseed").(int64)
+	}
+
+	confidence := 0.95
+	if cp.IsLoaded("confidence") {
+		confidence = cp.GetVar("confidence").(float64)
+	}
+	if confidence <= 0.0 || confidence >= 1.0 {
+		panic(fmt.Errorf("confidence level %g is not between 0 and 1", confidence))
+	}
+
+	args, err := originalArgs()
+	if err != nil {
+		panic(err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "sim-repl")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	runs := []*measure.Results{}
+	substreams := []int{}
+	for idx := 0; idx < numRepl; idx++ {
+		resultsFile := filepath.Join(tmpDir, "results-rep"+strconv.Itoa(idx)+".json")
+
+		// flags appearing later on the command line override earlier ones
+		overrides := []string{"-replications 1", fmt.Sprintf("-substream %d", idx), "-results " + resultsFile}
+		for _, flag := range []string{"samples", "trace"} {
+			if cp.IsLoaded(flag) {
+				overrides = append(overrides, "-"+flag+" "+replFile(cp.GetVar(flag).(string), idx))
+			}
+		}
+
+		argsFile := filepath.Join(tmpDir, "args-rep"+strconv.Itoa(idx))
+		err = os.WriteFile(argsFile, []byte(args+"\n"+strings.Join(overrides, "\n")+"\n"), 0644)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("running replication %d of %d with %s, substream %d ...\n", idx+1, numRepl, seedName(seed), idx)
+		cmd := exec.Command(exe, "-is", argsFile)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		if err != nil {
+			panic(fmt.Errorf("replication %d failed: %w", idx, err))
+		}
+
+		rs, err := measure.ReadResults(resultsFile)
+		if err != nil {
+			panic(err)
+		}
+		runs = append(runs, rs)
+		substreams = append(substreams, idx)
+	}
+
+	rr := measure.CombineReplications(runs, seed, substreams, confidence)
+	if cp.IsLoaded("results") {
+		err = rr.WriteToFile(cp.GetVar("results").(string))
+		if err != nil {
+			panic(err)
+		}
+	}
+	rr.Report()
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"fmt"
 	"github.com/iti/cmdline"
+	"github.com/iti/measure"
 	"github.com/iti/mrnes"
 	"github.com/iti/pces"
-	"github.com/iti/rngstream" 
 	"path/filepath"
 )
 
@@ -27,9 +27,23 @@ func cmdlineParams() *cmdline.CmdParser {
 	cp.AddFlag(cmdline.StringFlag, "exp", true)      // name of file used for run-time experiment parameters
 	cp.AddFlag(cmdline.StringFlag, "mdfy", false)    // name of file used to modify exp experiment parameters
 	cp.AddFlag(cmdline.StringFlag, "topo", false)    // name of output file used for topo templates
+	cp.AddFlag(cmdline.StringFlag, "bckgrnd", false) // name of input file listing background flows, as written by bld.go
+	cp.AddFlag(cmdline.StringFlag, "timeline", false) // name of input file of scenario events changing the network during the run
+	cp.AddFlag(cmdline.StringFlag, "failures", false) // name of input file describing device failures and repairs, as written by bld.go
 	cp.AddFlag(cmdline.StringFlag, "trace", false)   // path to output file of trace records
 	cp.AddFlag(cmdline.BoolFlag, "qnetsim", false)   // flag indicating that network sim ought to be 'quick'
+	cp.AddFlag(cmdline.Int64Flag, "rngseed", false)  // master seed of the rng streams
+	cp.AddFlag(cmdline.IntFlag, "substream", false)    // substream of every rng stream the run draws from (0 if absent)
+	cp.AddFlag(cmdline.IntFlag, "replications", false) // number of independent replications to run, each drawing from its own substreams
+	cp.AddFlag(cmdline.FloatFlag, "confidence", false) // confidence level of intervals reported across replications, default 0.95
 	cp.AddFlag(cmdline.FloatFlag, "stop", true)      // run the simulation until this time (in seconds)
+	cp.AddFlag(cmdline.StringFlag, "results", false) // path to output file of measurement statistics, csv if it ends in '.csv', otherwise json
+	cp.AddFlag(cmdline.StringFlag, "percentiles", false) // comma separated list of percentiles reported in results, default "90,95,99,99.9"
+	cp.AddFlag(cmdline.StringFlag, "samples", false) // path to output file of every RTT sample, csv if it ends in '.csv', otherwise json
+	cp.AddFlag(cmdline.FloatFlag, "warmup", false)   // delete from the results samples of packets started before this time (in seconds)
+	cp.AddFlag(cmdline.IntFlag, "warmupSamples", false) // delete from the results this many samples of each measurement group (after -warmup)
+	cp.AddFlag(cmdline.BoolFlag, "mser5", false)     // delete from the results the samples the MSER-5 rule finds in the initial transient
+	cp.AddFlag(cmdline.FloatFlag, "window", false)   // also report in the results the statistics of each window of this many seconds
 
 	return cp
 }
@@ -86,6 +100,45 @@ func main() {
 		panic(err)
 	}
 
+	// background flows are optional, and read from the input directory
+	var bckgrnd *measure.BckgrndFlowList
+	if cp.IsLoaded("bckgrnd") {
+		bckgrndFile := filepath.Join(inputDir, cp.GetVar("bckgrnd").(string))
+		var emptyBytes []byte
+		bckgrnd, err = measure.ReadBckgrndFlowList(bckgrndFile, filepath.Ext(bckgrndFile) != ".json", emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+		err = bckgrnd.Validate()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// the failure model is also read from the input directory, and its devices checked once the network is built
+	var failures *measure.FailureModel
+	if cp.IsLoaded("failures") {
+		failuresFile := filepath.Join(inputDir, cp.GetVar("failures").(string))
+		var emptyBytes []byte
+		failures, err = measure.ReadFailureModel(failuresFile, filepath.Ext(failuresFile) != ".json", emptyBytes)
+		if err != nil {
+			panic(err)
+		}
+		err = failures.Validate()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// the scenario timeline is read now, and checked against the network once that is built
+	var timeline *measure.Timeline
+	if cp.IsLoaded("timeline") {
+		timeline, err = measure.ReadTimeline(filepath.Join(inputDir, cp.GetVar("timeline").(string)))
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	// if we're saving traces check the path
 	var traceFile string
 	useTrace := false
@@ -98,18 +151,90 @@ func main() {
 		useTrace = true
 	}
 
+	// if we're saving results check the path
+	var resultsFile string
+	if cp.IsLoaded("results") {
+		resultsFile = cp.GetVar("results").(string)
+		_, err := pces.CheckOutputFiles([]string{resultsFile})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// percentiles of the RTTs reported in the results
+	pctStr := "90,95,99,99.9"
+	if cp.IsLoaded("percentiles") {
+		pctStr = cp.GetVar("percentiles").(string)
+	}
+	pcts, err := measure.ParsePercentiles(pctStr)
+	if err != nil {
+		panic(err)
+	}
+
+	// describe how the warm-up samples are deleted from the results
+	var truncation measure.Truncation
+	if cp.IsLoaded("warmup") {
+		truncation.WarmupTime = cp.GetVar("warmup").(float64)
+		if truncation.WarmupTime < 0.0 {
+			panic(fmt.Errorf("warmup of %g seconds is negative", truncation.WarmupTime))
+		}
+	}
+	if cp.IsLoaded("warmupSamples") {
+		truncation.WarmupSamples = cp.GetVar("warmupSamples").(int)
+		if truncation.WarmupSamples < 0 {
+			panic(fmt.Errorf("warmupSamples %d is negative", truncation.WarmupSamples))
+		}
+	}
+	if cp.IsLoaded("mser5") {
+		truncation.MSER5 = cp.GetVar("mser5").(bool)
+	}
+
+	// the length of the windows of time whose statistics are also reported, none if zero
+	window := 0.0
+	if cp.IsLoaded("window") {
+		window = cp.GetVar("window").(float64)
+		if window <= 0.0 {
+			panic(fmt.Errorf("window of %g seconds is not positive", window))
+		}
+	}
+
+	// if we're saving every RTT sample check the path
+	var samplesFile string
+	if cp.IsLoaded("samples") {
+		samplesFile = cp.GetVar("samples").(string)
+		_, err := pces.CheckOutputFiles([]string{samplesFile})
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	// if -qnetsim is set we use the 'skip over network devices' version of network simulation
 	if cp.IsLoaded("qnetsim") {
 		syn["qksim"] = "true"
 	}
 
+	checkRngFlags(cp)
+
+	// with more than one replication this process runs the replications
+	// and reports on them, rather than running an experiment itself
+	if cp.IsLoaded("replications") && cp.GetVar("replications").(int) > 1 {
+		runReplications(cp)
+		fmt.Println("Done")
+		return
+	}
+
 	traceMgr := mrnes.CreateTraceManager("experiment", useTrace)
 
-	// if requested, set the rng seed
+	// if requested, set the rng seed, and move the streams on to the substream asked for
+	seed := noRngSeed
 	if cp.IsLoaded("rngseed") {
-		seed := cp.GetVar("rngseed").(int64)
-		rngstream.SetRngStreamMasterSeed(uint64(seed))
+		seed = cp.GetVar("rngseed").(int64)
 	}
+	substream := 0
+	if cp.IsLoaded("substream") {
+		substream = cp.GetVar("substream").(int)
+	}
+	seedRngStreams(seed, substream)
 
 	// build the experiment.  First the network stuff
 	// start the id counter at 1 (value passed is incremented before use)
@@ -121,6 +246,29 @@ func main() {
 		panic(err)
 	}
 
+	// the background flows share the network built above with the computation patterns
+	if bckgrnd != nil {
+		bckgrnd.ScheduleBckgrndFlows(evtMgr)
+	}
+
+	// the scenario's events change that network as the run goes on
+	if timeline != nil {
+		err = timeline.Validate()
+		if err != nil {
+			panic(err)
+		}
+		timeline.Schedule(evtMgr)
+	}
+
+	// and devices fail and are repaired at random through the run
+	if failures != nil {
+		err = failures.CheckDevices()
+		if err != nil {
+			panic(err)
+		}
+		failures.Schedule(evtMgr)
+	}
+
 	termination := cp.GetVar("stop").(float64)
 	evtMgr.Run(termination)
 
@@ -128,6 +276,32 @@ func main() {
 		traceMgr.WriteToFile(traceFile)
 	}
 
+	// devices are only taken out of service by a timeline or the failure model, and what their
+	// outages cost is reported whenever there is one, whether or not the results are written
+	outages := timeline != nil || failures != nil
+	if len(resultsFile) > 0 || outages {
+		results := measure.GatherResults(termination, evtMgr.CurrentSeconds(), pcts, truncation)
+		results.AddWindows(window, pcts)
+
+		if outages {
+			results.AddAvailability(pcts, truncation)
+			results.Availability.Report()
+		}
+		if len(resultsFile) > 0 {
+			err = results.WriteToFile(resultsFile)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	if len(samplesFile) > 0 {
+		err = measure.WriteSamples(samplesFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	pces.ReportStatistics()
 	fmt.Println("Done")
 }
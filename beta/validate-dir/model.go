@@ -0,0 +1,182 @@
+package main
+
+// model.go describes the files the simulator reads, as far as the validator needs to follow
+// the references between them.   The files are read directly rather than through the pces and
+// mrnes readers, because those build the model as they read and panic on the first problem
+// they meet, while the validator means to report every problem it finds.  yaml is a superset
+// of json, so one reader serves whichever form bld wrote
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// cpFunc is a function of a computational pattern
+type cpFunc struct {
+	Class string `yaml:"class"`
+	Label string `yaml:"label"`
+}
+
+// cpEdge connects two functions of the same computational pattern
+type cpEdge struct {
+	SrcLabel   string `yaml:"srclabel"`
+	MsgType    string `yaml:"msgtype"`
+	DstLabel   string `yaml:"dstlabel"`
+	MethodCode string `yaml:"methodcode"`
+}
+
+// cpExtEdge connects functions in different computational patterns
+type cpExtEdge struct {
+	SrcCP      string `yaml:"srccp"`
+	DstCP      string `yaml:"dstcp"`
+	SrcLabel   string `yaml:"srclabel"`
+	DstLabel   string `yaml:"dstlabel"`
+	MsgType    string `yaml:"msgtype"`
+	MethodCode string `yaml:"methodcode"`
+}
+
+// cmpPtn is a computational pattern, as written to the cp file
+type cmpPtn struct {
+	CPType   string                 `yaml:"cptype"`
+	Name     string                 `yaml:"name"`
+	Funcs    []cpFunc               `yaml:"funcs"`
+	Edges    []cpEdge               `yaml:"edges"`
+	ExtEdges map[string][]cpExtEdge `yaml:"extedges"`
+}
+
+// cmpPtnDict is the contents of the cp file
+type cmpPtnDict struct {
+	DictName string            `yaml:"dictname"`
+	Patterns map[string]cmpPtn `yaml:"patterns"`
+}
+
+// cpInitMsg declares a message type a computational pattern uses
+type cpInitMsg struct {
+	MsgType string `yaml:"msgtype"`
+	IsPckt  bool   `yaml:"ispckt"`
+}
+
+// cpInitList holds the cfg of every function of a computational pattern, and its message types
+type cpInitList struct {
+	Name    string            `yaml:"name"`
+	CPType  string            `yaml:"cptype"`
+	UseYAML bool              `yaml:"useyaml"`
+	Cfg     map[string]string `yaml:"cfg"`
+	Msgs    []cpInitMsg       `yaml:"msgs"`
+}
+
+// cpInitDict is the contents of the cpInit file
+type cpInitDict struct {
+	DictName string                `yaml:"dictname"`
+	InitList map[string]cpInitList `yaml:"initlist"`
+}
+
+// cmpPtnMap maps the functions of a computational pattern to devices
+type cmpPtnMap struct {
+	PatternName string            `yaml:"patternname"`
+	FuncMap     map[string]string `yaml:"funcmap"`
+}
+
+// cmpPtnMapDict is the contents of the map file
+type cmpPtnMapDict struct {
+	DictName string               `yaml:"dictname"`
+	Map      map[string]cmpPtnMap `yaml:"map"`
+}
+
+// funcExecDesc is one function timing
+type funcExecDesc struct {
+	Identifier string  `yaml:"identifier"`
+	Param      string  `yaml:"param"`
+	CPUModel   string  `yaml:"CPUModel"`
+	PcktLen    int     `yaml:"pcktlen"`
+	ExecTime   float64 `yaml:"exectime"`
+}
+
+// funcExecList is the contents of the funcExec file
+type funcExecList struct {
+	ListName string                    `yaml:"listname"`
+	Times    map[string][]funcExecDesc `yaml:"times"`
+}
+
+// devExecDesc is one device operation timing
+type devExecDesc struct {
+	DevOp    string  `yaml:"devop"`
+	Model    string  `yaml:"model"`
+	ExecTime float64 `yaml:"exectime"`
+}
+
+// devExecList is the contents of the devExec file
+type devExecList struct {
+	ListName string                   `yaml:"listname"`
+	Times    map[string][]devExecDesc `yaml:"times"`
+}
+
+// topoIntrfc is an interface of a device in the topology
+type topoIntrfc struct {
+	Name      string   `yaml:"name"`
+	Groups    []string `yaml:"groups"`
+	DevType   string   `yaml:"devtype"`
+	MediaType string   `yaml:"mediatype"`
+	Device    string   `yaml:"device"`
+	Cable     string   `yaml:"cable"`
+	Carry     string   `yaml:"carry"`
+	Faces     string   `yaml:"faces"`
+}
+
+// topoDev is a device in the topology
+type topoDev struct {
+	Name       string       `yaml:"name"`
+	Groups     []string     `yaml:"groups"`
+	Model      string       `yaml:"model"`
+	Cores      int          `yaml:"cores"`
+	Interfaces []topoIntrfc `yaml:"interfaces"`
+}
+
+// topoNet is a network in the topology, naming the devices it includes
+type topoNet struct {
+	Name      string   `yaml:"name"`
+	Groups    []string `yaml:"groups"`
+	NetScale  string   `yaml:"netscale"`
+	MediaType string   `yaml:"mediatype"`
+	Endpts    []string `yaml:"endpts"`
+	Routers   []string `yaml:"routers"`
+	Switches  []string `yaml:"switches"`
+}
+
+// topoCfg is the contents of the topo file
+type topoCfg struct {
+	Name     string    `yaml:"name"`
+	Networks []topoNet `yaml:"networks"`
+	Routers  []topoDev `yaml:"routers"`
+	Endpts   []topoDev `yaml:"endpts"`
+	Switches []topoDev `yaml:"switches"`
+}
+
+// expAttrb selects the model objects an experiment parameter applies to
+type expAttrb struct {
+	AttrbName  string `yaml:"attrbname"`
+	AttrbValue string `yaml:"attrbvalue"`
+}
+
+// expParam assigns a value to a parameter of every object its attributes select
+type expParam struct {
+	ParamObj   string     `yaml:"paramObj"`
+	Attributes []expAttrb `yaml:"attributes"`
+	Param      string     `yaml:"param"`
+	Value      string     `yaml:"value"`
+}
+
+// expCfg is the contents of the exp and mdfy files
+type expCfg struct {
+	ExpName    string     `yaml:"expname"`
+	Parameters []expParam `yaml:"parameters"`
+}
+
+// readModelFile deserializes the named file into the structure v points to
+func readModelFile(filename string, v any) error {
+	dict, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(dict, v)
+}
@@ -0,0 +1,623 @@
+package main
+
+// validate checks the references between the files that describe a model to the simulator,
+// before the simulator is run.   pces and mrnes check only that the files are readable, and a
+// reference to something that does not exist shows up as a panic deep inside the model build,
+// or not at all.   validate loads every file and reports every dangling reference it finds,
+// naming the file and the entry where the reference is made
+
+import (
+	"fmt"
+	"github.com/iti/cmdline"
+	_ "github.com/iti/measure"
+	"github.com/iti/pces"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cmdlineParams defines the parameters recognized
+// on the command line.   The file flags are those sim uses
+func cmdlineParams() *cmdline.CmdParser {
+	cp := cmdline.NewCmdParser()
+	cp.AddFlag(cmdline.StringFlag, "inputLib", true) // directory where model parameters are read from
+	cp.AddFlag(cmdline.StringFlag, "cp", true)       // name of file holding the computational patterns
+	cp.AddFlag(cmdline.StringFlag, "cpInit", true)   // name of file holding the cfgs of the computational pattern functions
+	cp.AddFlag(cmdline.StringFlag, "funcExec", true) // name of file holding descriptions of functional timings
+	cp.AddFlag(cmdline.StringFlag, "devExec", true)  // name of file holding descriptions of device timings
+	cp.AddFlag(cmdline.StringFlag, "srdCfg", false)  // name of file holding descriptions of functions that share configuration
+	cp.AddFlag(cmdline.StringFlag, "map", true)      // file with mapping of comp pattern functions to hosts
+	cp.AddFlag(cmdline.StringFlag, "exp", true)      // name of file used for run-time experiment parameters
+	cp.AddFlag(cmdline.StringFlag, "mdfy", false)    // name of file used to modify exp experiment parameters
+	cp.AddFlag(cmdline.StringFlag, "topo", true)     // name of file holding the topology
+	return cp
+}
+
+// validator holds the contents of the model files, and the problems found in them
+type validator struct {
+	files map[string]string // name of the file read for each flag
+
+	cp     cmpPtnDict
+	cpInit cpInitDict
+	maps   cmpPtnMapDict
+	fel    funcExecList
+	del    devExecList
+	topo   topoCfg
+	exp    expCfg
+	mdfy   expCfg
+
+	// loaded notes which files were read successfully, checks that depend
+	// on a file that could not be read are skipped
+	loaded map[string]bool
+
+	// devKind gives the type ("Endpt", "Router", or "Switch") of every device in the topology
+	devKind map[string]string
+
+	// devs and intrfcs index the devices and interfaces of the topology by name
+	devs    map[string]topoDev
+	intrfcs map[string]topoIntrfc
+
+	errs []error
+}
+
+// report notes a problem found in the file read for flag, at the entry described by context
+func (v *validator) report(flag, context string, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s: %s", v.files[flag], context, fmt.Sprintf(format, args...)))
+}
+
+// main gives the entry point
+func main() {
+	// define the command line parameters
+	cp := cmdlineParams()
+
+	// parse the command line
+	cp.Parse()
+
+	// string for the input directory
+	inputDir := cp.GetVar("inputLib").(string)
+
+	v := new(validator)
+	v.files = make(map[string]string)
+	v.loaded = make(map[string]bool)
+
+	targets := map[string]any{"cp": &v.cp, "cpInit": &v.cpInit, "map": &v.maps, "funcExec": &v.fel,
+		"devExec": &v.del, "topo": &v.topo, "exp": &v.exp, "mdfy": &v.mdfy, "srdCfg": &map[string]any{}}
+
+	for _, flag := range []string{"cp", "cpInit", "funcExec", "devExec", "srdCfg", "map", "exp", "mdfy", "topo"} {
+		if !cp.IsLoaded(flag) {
+			continue
+		}
+		v.files[flag] = cp.GetVar(flag).(string)
+		err := readModelFile(filepath.Join(inputDir, v.files[flag]), targets[flag])
+		if err != nil {
+			v.errs = append(v.errs, fmt.Errorf("%s: %w", v.files[flag], err))
+			continue
+		}
+		v.loaded[flag] = true
+	}
+
+	v.indexTopo()
+	v.checkCP()
+	v.checkCPInit()
+	v.checkMap()
+	v.checkTopo()
+	v.checkExp("exp", v.exp)
+	v.checkExp("mdfy", v.mdfy)
+
+	if len(v.errs) > 0 {
+		for _, err := range v.errs {
+			fmt.Println(err)
+		}
+		fmt.Printf("%d problems found in the model in %s\n", len(v.errs), inputDir)
+		os.Exit(1)
+	}
+	fmt.Printf("no problems found in the model in %s\n", inputDir)
+}
+
+// sortedKeys returns the keys of a map in sorted order, so that problems are reported in a repeatable order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// funcClasses returns the class of every function of the named computational pattern, indexed by label.
+// nil is returned if there is no such pattern
+func (v *validator) funcClasses(ptnName string) map[string]string {
+	ptn, present := v.cp.Patterns[ptnName]
+	if !present {
+		return nil
+	}
+	classes := make(map[string]string)
+	for _, cpf := range ptn.Funcs {
+		classes[cpf.Label] = cpf.Class
+	}
+	return classes
+}
+
+// msgDeclared reports whether the cpInit entry of the named pattern declares the message type.
+// Without a cpInit file there is nothing to check against
+func (v *validator) msgDeclared(ptnName, msgType string) bool {
+	if !v.loaded["cpInit"] {
+		return true
+	}
+	for _, msg := range v.cpInit.InitList[ptnName].Msgs {
+		if msg.MsgType == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMethod reports a method code that the class of the function receiving the message does not define
+func (v *validator) checkMethod(flag, context, class, methodCode string) {
+	methods, present := pces.ClassMethods[class]
+	if !present {
+		// the unknown class is reported where the function is declared
+		return
+	}
+	if _, present := methods[methodCode]; !present {
+		v.report(flag, context, "method code %s is not defined by function class %s", methodCode, class)
+	}
+}
+
+// checkCP checks the functions and edges of every computational pattern
+func (v *validator) checkCP() {
+	if !v.loaded["cp"] {
+		return
+	}
+	for _, ptnName := range sortedKeys(v.cp.Patterns) {
+		ptn := v.cp.Patterns[ptnName]
+		if ptn.Name != ptnName {
+			v.report("cp", "pattern "+ptnName, "pattern is named %s", ptn.Name)
+		}
+
+		classes := make(map[string]string)
+		for _, cpf := range ptn.Funcs {
+			context := fmt.Sprintf("pattern %s, function %s", ptnName, cpf.Label)
+			if _, present := classes[cpf.Label]; present {
+				v.report("cp", context, "function label declared more than once")
+			}
+			classes[cpf.Label] = cpf.Class
+			if _, present := pces.ClassMethods[cpf.Class]; !present {
+				v.report("cp", context, "function class %s is not defined", cpf.Class)
+			}
+		}
+
+		for _, edge := range ptn.Edges {
+			context := fmt.Sprintf("pattern %s, edge %s -> %s (%s)", ptnName, edge.SrcLabel, edge.DstLabel, edge.MsgType)
+			if _, present := classes[edge.SrcLabel]; !present {
+				v.report("cp", context, "source function %s is not in the pattern", edge.SrcLabel)
+			}
+			dstClass, present := classes[edge.DstLabel]
+			if !present {
+				v.report("cp", context, "destination function %s is not in the pattern", edge.DstLabel)
+			} else {
+				v.checkMethod("cp", context, dstClass, edge.MethodCode)
+			}
+			if !v.msgDeclared(ptnName, edge.MsgType) {
+				v.report("cp", context, "message type %s is not declared in %s", edge.MsgType, v.files["cpInit"])
+			}
+		}
+
+		for _, dstName := range sortedKeys(ptn.ExtEdges) {
+			for _, edge := range ptn.ExtEdges[dstName] {
+				context := fmt.Sprintf("pattern %s, external edge %s.%s -> %s.%s (%s)", ptnName,
+					edge.SrcCP, edge.SrcLabel, edge.DstCP, edge.DstLabel, edge.MsgType)
+				if edge.SrcCP != ptnName {
+					v.report("cp", context, "edge is listed with pattern %s", ptnName)
+				}
+				if edge.DstCP != dstName {
+					v.report("cp", context, "edge is listed under destination pattern %s", dstName)
+				}
+				if _, present := classes[edge.SrcLabel]; !present {
+					v.report("cp", context, "source function %s is not in pattern %s", edge.SrcLabel, ptnName)
+				}
+
+				dstClasses := v.funcClasses(edge.DstCP)
+				if dstClasses == nil {
+					v.report("cp", context, "destination pattern %s is not defined", edge.DstCP)
+					continue
+				}
+				dstClass, present := dstClasses[edge.DstLabel]
+				if !present {
+					v.report("cp", context, "destination function %s is not in pattern %s", edge.DstLabel, edge.DstCP)
+				} else {
+					v.checkMethod("cp", context, dstClass, edge.MethodCode)
+				}
+				for _, name := range []string{edge.SrcCP, edge.DstCP} {
+					if !v.msgDeclared(name, edge.MsgType) {
+						v.report("cp", context, "message type %s is not declared for pattern %s in %s",
+							edge.MsgType, name, v.files["cpInit"])
+					}
+				}
+			}
+		}
+	}
+}
+
+// checkCPInit checks that every cfg is for a function in the cp file, that every function has a cfg,
+// and that the method codes, patterns, and functions a cfg names exist
+func (v *validator) checkCPInit() {
+	if !v.loaded["cpInit"] || !v.loaded["cp"] {
+		return
+	}
+	for _, ptnName := range sortedKeys(v.cpInit.InitList) {
+		cpil := v.cpInit.InitList[ptnName]
+		classes := v.funcClasses(ptnName)
+		if classes == nil {
+			v.report("cpInit", "pattern "+ptnName, "pattern is not defined in %s", v.files["cp"])
+			continue
+		}
+
+		for _, label := range sortedKeys(cpil.Cfg) {
+			context := fmt.Sprintf("pattern %s, cfg %s", ptnName, label)
+			class, present := classes[label]
+			if !present {
+				v.report("cpInit", context, "function %s is not in the pattern in %s", label, v.files["cp"])
+				continue
+			}
+			v.checkCfg(context, ptnName, label, class, cpil.Cfg[label])
+		}
+
+		for _, label := range sortedKeys(classes) {
+			if _, present := cpil.Cfg[label]; !present {
+				v.report("cpInit", "pattern "+ptnName, "function %s has no cfg", label)
+			}
+		}
+	}
+
+	for _, ptnName := range sortedKeys(v.cp.Patterns) {
+		if _, present := v.cpInit.InitList[ptnName]; !present {
+			v.report("cp", "pattern "+ptnName, "pattern has no entry in %s", v.files["cpInit"])
+		}
+	}
+}
+
+// checkCfg checks the references made by the cfg of function label, of class class, in pattern ptnName.
+// The fields checked are those the beta function classes use: 'route' and 'timingcode' are
+// indexed by method code, 'tgtcp' names patterns, 'tgtlabel' functions, and 'dsts' patterns
+func (v *validator) checkCfg(context, ptnName, label, class, cfgStr string) {
+	cfg := make(map[string]any)
+	err := yaml.Unmarshal([]byte(cfgStr), &cfg)
+	if err != nil {
+		v.report("cpInit", context, "cfg cannot be read: %s", err)
+		return
+	}
+
+	// strMap returns a field of the cfg that is a map of strings
+	strMap := func(field string) map[string]string {
+		m := make(map[string]string)
+		fieldMap, ok := cfg[field].(map[string]any)
+		if !ok {
+			return m
+		}
+		for key, value := range fieldMap {
+			m[key] = fmt.Sprint(value)
+		}
+		return m
+	}
+
+	for _, field := range []string{"route", "timingcode"} {
+		for _, methodCode := range sortedKeys(strMap(field)) {
+			v.checkMethod("cpInit", context+", "+field, class, methodCode)
+		}
+	}
+
+	tgtCP := strMap("tgtcp")
+	for _, methodCode := range sortedKeys(tgtCP) {
+		if v.funcClasses(tgtCP[methodCode]) == nil {
+			v.report("cpInit", context+", tgtcp", "pattern %s is not defined in %s", tgtCP[methodCode], v.files["cp"])
+		}
+	}
+
+	tgtLabel := strMap("tgtlabel")
+	for _, methodCode := range sortedKeys(tgtLabel) {
+		tgtPtn := ptnName
+		if name, present := tgtCP[methodCode]; present {
+			tgtPtn = name
+		}
+		classes := v.funcClasses(tgtPtn)
+		if classes == nil {
+			continue
+		}
+		if _, present := classes[tgtLabel[methodCode]]; !present {
+			v.report("cpInit", context+", tgtlabel", "function %s is not in pattern %s", tgtLabel[methodCode], tgtPtn)
+		}
+	}
+
+	dsts, _ := cfg["dsts"].([]any)
+	for _, dst := range dsts {
+		if v.funcClasses(fmt.Sprint(dst)) == nil {
+			v.report("cpInit", context+", dsts", "pattern %v is not defined in %s", dst, v.files["cp"])
+		}
+	}
+
+	// the timings of the function are looked up on the CPU model of the device it is mapped to
+	if !v.loaded["funcExec"] || !v.loaded["map"] || !v.loaded["topo"] {
+		return
+	}
+	devName, present := v.maps.Map[ptnName].FuncMap[label]
+	if !present {
+		return
+	}
+	dev, present := v.devs[devName]
+	if !present {
+		return
+	}
+	model := dev.Model
+	timingCodes := strMap("timingcode")
+	for _, methodCode := range sortedKeys(timingCodes) {
+		code := timingCodes[methodCode]
+		feds, present := v.fel.Times[code]
+		if !present {
+			v.report("cpInit", context+", timingcode", "timing code %s has no timings in %s", code, v.files["funcExec"])
+			continue
+		}
+		found := false
+		for _, fed := range feds {
+			if fed.CPUModel == model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report("cpInit", context+", timingcode", "timing code %s has no timing in %s for CPU model %s of device %s",
+				code, v.files["funcExec"], model, devName)
+		}
+	}
+}
+
+// checkMap checks that every function is mapped, and mapped to an endpoint of the topology
+func (v *validator) checkMap() {
+	if !v.loaded["map"] {
+		return
+	}
+	for _, ptnName := range sortedKeys(v.maps.Map) {
+		cpm := v.maps.Map[ptnName]
+		if cpm.PatternName != ptnName {
+			v.report("map", "pattern "+ptnName, "map is for pattern %s", cpm.PatternName)
+		}
+
+		classes := v.funcClasses(ptnName)
+		if v.loaded["cp"] && classes == nil {
+			v.report("map", "pattern "+ptnName, "pattern is not defined in %s", v.files["cp"])
+		}
+
+		for _, label := range sortedKeys(cpm.FuncMap) {
+			context := fmt.Sprintf("pattern %s, function %s", ptnName, label)
+			if classes != nil {
+				if _, present := classes[label]; !present {
+					v.report("map", context, "function is not in the pattern in %s", v.files["cp"])
+				}
+			}
+			if !v.loaded["topo"] {
+				continue
+			}
+			devName := cpm.FuncMap[label]
+			kind, present := v.devKind[devName]
+			if !present {
+				v.report("map", context, "device %s is not in %s", devName, v.files["topo"])
+			} else if kind != "Endpt" {
+				v.report("map", context, "device %s is a %s, not an endpoint", devName, kind)
+			}
+		}
+	}
+
+	if !v.loaded["cp"] {
+		return
+	}
+	for _, ptnName := range sortedKeys(v.cp.Patterns) {
+		cpm, present := v.maps.Map[ptnName]
+		if !present {
+			v.report("cp", "pattern "+ptnName, "pattern has no entry in %s", v.files["map"])
+			continue
+		}
+		for _, cpf := range v.cp.Patterns[ptnName].Funcs {
+			if _, present := cpm.FuncMap[cpf.Label]; !present {
+				v.report("map", "pattern "+ptnName, "function %s is not mapped to a device", cpf.Label)
+			}
+		}
+	}
+}
+
+// indexTopo indexes the devices and interfaces of the topology by name
+func (v *validator) indexTopo() {
+	v.devKind = make(map[string]string)
+	v.devs = make(map[string]topoDev)
+	v.intrfcs = make(map[string]topoIntrfc)
+	if !v.loaded["topo"] {
+		return
+	}
+
+	kinds := map[string][]topoDev{"Endpt": v.topo.Endpts, "Router": v.topo.Routers, "Switch": v.topo.Switches}
+	for _, kind := range sortedKeys(kinds) {
+		for _, dev := range kinds[kind] {
+			if _, present := v.devKind[dev.Name]; present {
+				v.report("topo", "device "+dev.Name, "device declared more than once")
+			}
+			v.devKind[dev.Name] = kind
+			v.devs[dev.Name] = dev
+			for _, intrfc := range dev.Interfaces {
+				if _, present := v.intrfcs[intrfc.Name]; present {
+					v.report("topo", "interface "+intrfc.Name, "interface declared more than once")
+				}
+				v.intrfcs[intrfc.Name] = intrfc
+			}
+		}
+	}
+}
+
+// checkTopo checks the references the topology makes among its networks, devices, and interfaces,
+// and that the device timings cover every router and switch
+func (v *validator) checkTopo() {
+	if !v.loaded["topo"] {
+		return
+	}
+
+	nets := make(map[string]bool)
+	for _, net := range v.topo.Networks {
+		nets[net.Name] = true
+		members := map[string][]string{"Endpt": net.Endpts, "Router": net.Routers, "Switch": net.Switches}
+		for _, kind := range sortedKeys(members) {
+			for _, devName := range members[kind] {
+				devKind, present := v.devKind[devName]
+				if !present {
+					v.report("topo", "network "+net.Name, "device %s is not declared", devName)
+				} else if devKind != kind {
+					v.report("topo", "network "+net.Name, "device %s is listed as a %s but is a %s", devName, kind, devKind)
+				}
+			}
+		}
+	}
+
+	for _, devName := range sortedKeys(v.devs) {
+		dev := v.devs[devName]
+		for _, intrfc := range dev.Interfaces {
+			context := fmt.Sprintf("device %s, interface %s", devName, intrfc.Name)
+			if intrfc.Device != devName {
+				v.report("topo", context, "interface names device %s", intrfc.Device)
+			}
+			if len(intrfc.Faces) > 0 && !nets[intrfc.Faces] {
+				v.report("topo", context, "interface faces undeclared network %s", intrfc.Faces)
+			}
+			for _, peerName := range []string{intrfc.Cable, intrfc.Carry} {
+				if len(peerName) == 0 {
+					continue
+				}
+				peer, present := v.intrfcs[peerName]
+				if !present {
+					v.report("topo", context, "interface connects to undeclared interface %s", peerName)
+				} else if peerName == intrfc.Cable && peer.Cable != intrfc.Name {
+					v.report("topo", context, "cable to %s is not matched by a cable back", peerName)
+				}
+			}
+		}
+	}
+
+	// mrnes times the operation of every router and switch
+	if !v.loaded["devExec"] {
+		return
+	}
+	devOps := map[string]string{"Router": "route", "Switch": "switch"}
+	for _, devName := range sortedKeys(v.devs) {
+		devOp, present := devOps[v.devKind[devName]]
+		if !present {
+			continue
+		}
+		model := v.devs[devName].Model
+		found := false
+		for _, ded := range v.del.Times[devOp] {
+			if ded.Model == model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report("topo", "device "+devName, "model %s has no %s timing in %s", model, devOp, v.files["devExec"])
+		}
+	}
+}
+
+// attrbValues returns the values each attribute takes on for every object of the given type
+// an experiment parameter may select, along with the attributes recognized for that type.
+// An interface is selected by group if either it or its device belongs to the group
+func (v *validator) attrbValues(paramObj string) ([]map[string][]string, map[string]bool) {
+	objs := []map[string][]string{}
+	var attrbs map[string]bool
+
+	switch paramObj {
+	case "Network":
+		attrbs = map[string]bool{"name": true, "group": true, "media": true, "scale": true}
+		for _, net := range v.topo.Networks {
+			objs = append(objs, map[string][]string{"name": {net.Name}, "group": net.Groups,
+				"media": {net.MediaType}, "scale": {net.NetScale}})
+		}
+	case "Endpt", "Router", "Switch":
+		attrbs = map[string]bool{"name": true, "group": true, "model": true}
+		for _, devName := range sortedKeys(v.devs) {
+			if v.devKind[devName] != paramObj {
+				continue
+			}
+			dev := v.devs[devName]
+			objs = append(objs, map[string][]string{"name": {dev.Name}, "group": dev.Groups, "model": {dev.Model}})
+		}
+	case "Interface":
+		attrbs = map[string]bool{"name": true, "group": true, "devname": true, "devtype": true,
+			"media": true, "network": true}
+		for _, intrfcName := range sortedKeys(v.intrfcs) {
+			intrfc := v.intrfcs[intrfcName]
+			groups := append(append([]string{}, intrfc.Groups...), v.devs[intrfc.Device].Groups...)
+			objs = append(objs, map[string][]string{"name": {intrfc.Name}, "group": groups,
+				"devname": {intrfc.Device}, "devtype": {intrfc.DevType}, "media": {intrfc.MediaType},
+				"network": {intrfc.Faces}})
+		}
+	}
+	return objs, attrbs
+}
+
+// checkExp checks that the attributes of every parameter of the exp (or mdfy) file select
+// at least one object of the topology
+func (v *validator) checkExp(flag string, ec expCfg) {
+	if !v.loaded[flag] || !v.loaded["topo"] {
+		return
+	}
+	for idx, param := range ec.Parameters {
+		selectors := []string{}
+		for _, attrb := range param.Attributes {
+			selectors = append(selectors, attrb.AttrbName+"="+attrb.AttrbValue)
+		}
+		context := fmt.Sprintf("parameter %d (%s %s, %s)", idx, param.ParamObj, param.Param, strings.Join(selectors, " "))
+
+		objs, attrbs := v.attrbValues(param.ParamObj)
+		if attrbs == nil {
+			v.report(flag, context, "%s is not a parameter object", param.ParamObj)
+			continue
+		}
+
+		recognized := true
+		for _, attrb := range param.Attributes {
+			if attrb.AttrbName != "*" && !attrbs[attrb.AttrbName] {
+				v.report(flag, context, "%s does not select %s objects", attrb.AttrbName, param.ParamObj)
+				recognized = false
+			}
+		}
+		if !recognized {
+			continue
+		}
+
+		// an object is selected if it matches every attribute
+		matched := false
+		for _, obj := range objs {
+			matchesAll := true
+			for _, attrb := range param.Attributes {
+				if attrb.AttrbName == "*" {
+					continue
+				}
+				matches := false
+				for _, value := range obj[attrb.AttrbName] {
+					if value == attrb.AttrbValue {
+						matches = true
+						break
+					}
+				}
+				if !matches {
+					matchesAll = false
+					break
+				}
+			}
+			if matchesAll {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(flag, context, "attributes select no %s in %s", param.ParamObj, v.files["topo"])
+		}
+	}
+}